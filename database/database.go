@@ -9,18 +9,23 @@ import (
 	"github.com/zorchenhimer/MoviePolls/models"
 )
 
-type constructor func(string, *logger.Logger) (Database, error)
+// The master key encrypts secrets before they are saved, it may be nil.
+type constructor func(connStr string, masterKey []byte, l *logger.Logger) (Database, error)
 
 var registeredDatabases map[string]constructor
 var ErrNoValue = errors.New("No value for key")
 
-func GetDatabase(backend, connectionString string, l *logger.Logger) (Database, error) {
+func GetDatabase(backend, connectionString string, masterKey []byte, l *logger.Logger) (Database, error) {
 	dc, ok := registeredDatabases[backend]
 	if !ok {
 		return nil, fmt.Errorf("Backend %s is not available", backend)
 	}
 
-	return dc(connectionString, l)
+	db, err := dc(connectionString, masterKey, l)
+	if err != nil {
+		return nil, err
+	}
+	return &timedDatabase{db: db}, nil
 }
 
 func register(backend string, initFunc constructor) {
@@ -46,6 +51,11 @@ type Database interface {
 	AddAuthMethod(authMethod *models.AuthMethod) (int, error)
 	AddLink(link *models.Link) (int, error)
 	AddVote(userId, movieId int) error
+	AddBan(ban *models.Ban) (int, error)
+	AddRole(role *models.Role) (int, error)
+	AddAuditEntry(entry *models.AuditEntry) error
+	AddNotification(notification *models.Notification) (int, error)
+	AddSession(session *models.Session) (int, error)
 
 	// ######################
 	// ##### READ (get) #####
@@ -55,6 +65,8 @@ type Database interface {
 	GetCurrentCycle() (*models.Cycle, error) // Return nil when no cycle is active.
 	GetMovie(id int) (*models.Movie, error)
 	GetActiveMovies() ([]*models.Movie, error)
+	// Every movie, including removed, denied and watched ones.
+	GetAllMovies() ([]*models.Movie, error)
 	GetUser(id int) (*models.User, error)
 	GetUsers(start, count int) ([]*models.User, error)
 	GetUserVotes(userId int) ([]*models.Movie, error)
@@ -64,6 +76,19 @@ type Database interface {
 	GetTag(id int) *models.Tag
 	GetAuthMethod(id int) *models.AuthMethod
 	GetLink(id int) *models.Link
+	GetLinks() ([]*models.Link, error)
+	GetTags() ([]*models.Tag, error)
+	GetBans() ([]*models.Ban, error)
+	GetRole(id int) (*models.Role, error)
+	GetRoles() ([]*models.Role, error)
+	// Audit entries are returned oldest first.
+	GetAuditEntries() ([]*models.AuditEntry, error)
+	// Notifications are returned newest first.
+	GetUserNotifications(userId int) ([]*models.Notification, error)
+	// Return ErrNoValue if there is no session with the token hash.
+	GetSession(tokenHash string) (*models.Session, error)
+	// Sessions are returned most recently used first.
+	GetUserSessions(userId int) ([]*models.Session, error)
 	// Return a list of past cycles.  Start and end are an offset from
 	// the current.  Ie, a start of 0 and an end of 5 will get the last
 	// finished cycle and the four preceding it.  Currently active cycle will
@@ -77,6 +102,7 @@ type Database interface {
 	// ##### READ (find) #####
 	// #######################
 
+	// Returns ErrNoValue if there is no tag with the name.
 	FindTag(name string) (int, error)
 	FindLink(url string) (int, error)
 
@@ -85,9 +111,14 @@ type Database interface {
 	// ##################
 
 	UpdateUser(user *models.User) error
+	// Returns ErrNoValue if there is no movie with the ID.
 	UpdateMovie(movie *models.Movie) error
 	UpdateCycle(cycle *models.Cycle) error
 	UpdateAuthMethod(authMethod *models.AuthMethod) error
+	UpdateTag(tag *models.Tag) error
+	UpdateRole(role *models.Role) error
+	MarkNotificationsRead(userId int) error
+	UpdateSession(session *models.Session) error
 
 	// ##################
 	// ##### DELETE #####
@@ -97,6 +128,16 @@ type Database interface {
 	DeleteTag(tagId int)
 	DeleteAuthMethod(authMethodId int)
 	DeleteLink(linkId int)
+	// Delete many links and tags at once, the cleanup can remove hundreds.
+	DeleteLinksAndTags(linkIds, tagIds []int) error
+	DeleteBan(banId int) error
+	// Delete a role and unassign it from all users.
+	DeleteRole(roleId int) error
+	DeleteSession(sessionId int) error
+	DeleteUserSessions(userId int) error
+	// Delete every session that hasn't been used since lastSeen and return
+	// how many were deleted.
+	DeleteSessionsBefore(lastSeen time.Time) (int, error)
 	RemoveMovie(movieId int) error
 	// Delete a user and their associated votes.  Should this include votes for
 	// past cycles or just the current? (currently removes all)
@@ -115,8 +156,6 @@ type Database interface {
 
 	CheckOauthUsage(id string, authtype models.AuthType) bool
 
-	SearchMovieTitles(query string) ([]*models.Movie, error)
-
 	CheckMovieExists(title string) (bool, error)
 	CheckUserExists(name string) (bool, error)
 
@@ -134,6 +173,17 @@ type Database interface {
 	SetCfgBool(key string, value bool) error
 
 	DeleteCfgKey(key string) error
+
+	// Settings with these keys hold credentials and are encrypted if a
+	// master key was given.
+	SetSecretSettings(keys []string) error
+
+	// Ping returns an error if the database doesn't answer.
+	Ping() error
+
+	// Close writes out anything that is pending.  Changes made after Close
+	// are not saved.
+	Close() error
 }
 
 type TestableDatabase interface {
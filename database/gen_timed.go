@@ -0,0 +1,123 @@
+//go:build ignore
+// +build ignore
+
+// gen_timed.go writes timed.go, the methods of timedDatabase.  Every method
+// of the Database interface gets one that records its duration and calls
+// the wrapped database.  Run `go generate ./database` after changing the
+// interface.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+func main() {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "database.go", nil, 0)
+	if err != nil {
+		fail(err)
+	}
+
+	var iface *ast.InterfaceType
+	ast.Inspect(file, func(n ast.Node) bool {
+		if spec, ok := n.(*ast.TypeSpec); ok && spec.Name.Name == "Database" {
+			iface, _ = spec.Type.(*ast.InterfaceType)
+			return false
+		}
+		return true
+	})
+	if iface == nil {
+		fail(fmt.Errorf("Database interface not found in database.go"))
+	}
+
+	expr := func(e ast.Expr) string {
+		buf := &bytes.Buffer{}
+		printer.Fprint(buf, fset, e)
+		return buf.String()
+	}
+
+	out := &bytes.Buffer{}
+	fmt.Fprintln(out, "// Code generated by gen_timed.go; DO NOT EDIT.")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "package database")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "import (")
+	fmt.Fprintln(out, "\t\"time\"")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "\t\"github.com/zorchenhimer/MoviePolls/models\"")
+	fmt.Fprintln(out, ")")
+
+	for _, method := range iface.Methods.List {
+		fn, ok := method.Type.(*ast.FuncType)
+		if !ok || len(method.Names) == 0 {
+			fail(fmt.Errorf("Only methods are supported in the Database interface"))
+		}
+		name := method.Names[0].Name
+
+		params := []string{}
+		args := []string{}
+		for i, field := range fn.Params.List {
+			names := []string{}
+			for _, n := range field.Names {
+				names = append(names, n.Name)
+			}
+			if len(names) == 0 {
+				names = []string{fmt.Sprintf("p%d", i)}
+			}
+
+			typ := expr(field.Type)
+			params = append(params, strings.Join(names, ", ")+" "+typ)
+			for _, n := range names {
+				if _, variadic := field.Type.(*ast.Ellipsis); variadic {
+					n += "..."
+				}
+				args = append(args, n)
+			}
+		}
+
+		results := ""
+		if fn.Results != nil {
+			types := []string{}
+			for _, field := range fn.Results.List {
+				types = append(types, expr(field.Type))
+			}
+			results = strings.Join(types, ", ")
+			if len(types) > 1 {
+				results = "(" + results + ")"
+			}
+		}
+
+		call := fmt.Sprintf("t.db.%s(%s)", name, strings.Join(args, ", "))
+		if results != "" {
+			call = "return " + call
+		}
+
+		fmt.Fprintf(out, "\nfunc (t *timedDatabase) %s(%s) %s {\n", name, strings.Join(params, ", "), results)
+		fmt.Fprintf(out, "\tdefer observeOperation(%q, time.Now())\n", name)
+		fmt.Fprintf(out, "\t%s\n", call)
+		fmt.Fprintln(out, "}")
+	}
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		fail(err)
+	}
+
+	if err := ioutil.WriteFile("timed.go", src, 0644); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "gen_timed: %v\n", err)
+	os.Exit(1)
+}
@@ -23,7 +23,7 @@ var testConnectors = map[string]func() (TestableDatabase, error){
 	//	return TestableDataConnector(dc), err
 	//},
 	"json": func() (TestableDatabase, error) {
-		dc, err := newJsonConnector("test.json", l)
+		dc, err := newJsonConnector("test.json", nil, l)
 		return TestableDatabase(dc), err
 	},
 }
@@ -14,11 +14,13 @@ import (
 
 	"github.com/zorchenhimer/MoviePolls/logger"
 	mpm "github.com/zorchenhimer/MoviePolls/models"
+	"github.com/zorchenhimer/MoviePolls/secrets"
 )
 
 type jsonMovie struct {
 	Id             int
 	Name           string
+	OriginalTitle  string
 	Links          []int
 	Description    string
 	Remarks        string
@@ -28,7 +30,11 @@ type jsonMovie struct {
 	CycleWatchedId int
 	Removed        bool
 	Approved       bool
+	Pending        bool
+	Denied         bool
+	DenyReason     string
 	Poster         string
+	PosterSource   string
 	AddedBy        int
 	Tags           []int
 }
@@ -64,6 +70,7 @@ func (j *jsonConnector) newJsonMovie(movie *mpm.Movie) jsonMovie {
 	jm := jsonMovie{
 		Id:             id,
 		Name:           movie.Name,
+		OriginalTitle:  movie.OriginalTitle,
 		Links:          links,
 		Description:    movie.Description,
 		Remarks:        movie.Remarks,
@@ -73,7 +80,11 @@ func (j *jsonConnector) newJsonMovie(movie *mpm.Movie) jsonMovie {
 		CycleWatchedId: cycleWatched,
 		Removed:        movie.Removed,
 		Approved:       movie.Approved,
+		Pending:        movie.Pending,
+		Denied:         movie.Denied,
+		DenyReason:     movie.DenyReason,
 		Poster:         movie.Poster,
+		PosterSource:   movie.PosterSource,
 		Tags:           tags,
 	}
 
@@ -108,6 +119,7 @@ type jsonUser struct {
 	NotifyCycleEnd      bool
 	NotifyVoteSelection bool
 	Privilege           int
+	RoleId              int
 
 	AuthMethods []int
 }
@@ -132,6 +144,10 @@ func (j *jsonConnector) newJsonUser(user *mpm.User) jsonUser {
 		AuthMethods:         authMethods,
 	}
 
+	if user.Role != nil {
+		ju.RoleId = user.Role.Id
+	}
+
 	return ju
 }
 
@@ -174,29 +190,41 @@ func (j *jsonConnector) newJsonCycle(cycle *mpm.Cycle) jsonCycle {
 type jsonConnector struct {
 	filename string `json:"-"`
 	lock     *sync.RWMutex
-
-	Cycles      map[int]jsonCycle
-	Movies      map[int]jsonMovie
-	Users       map[int]jsonUser
-	Votes       []jsonVote
-	Tags        map[int]*mpm.Tag
-	Links       map[int]*mpm.Link
-	AuthMethods map[int]*mpm.AuthMethod
+	closed   bool // set by Close, nothing is saved afterwards
+
+	Cycles        map[int]jsonCycle
+	Movies        map[int]jsonMovie
+	Users         map[int]jsonUser
+	Votes         []jsonVote
+	Tags          map[int]*mpm.Tag
+	Links         map[int]*mpm.Link
+	AuthMethods   map[int]*mpm.AuthMethod
+	Bans          map[int]*mpm.Ban
+	Roles         map[int]*mpm.Role
+	AuditLog      []*mpm.AuditEntry
+	Notifications map[int]*mpm.Notification
+	Sessions      map[int]*mpm.Session
 
 	//Settings Configurator
 	Settings map[string]configValue
 
+	// The data key encrypting the secrets, itself encrypted with the master
+	// key.  Empty if secrets are saved in plain text.
+	DataKey        string
+	secrets        *secrets.Box
+	secretSettings map[string]bool
+
 	l *logger.Logger
 }
 
 func init() {
-	register("json", func(connStr string, l *logger.Logger) (Database, error) {
-		db, err := newJsonConnector(connStr, l)
+	register("json", func(connStr string, masterKey []byte, l *logger.Logger) (Database, error) {
+		db, err := newJsonConnector(connStr, masterKey, l)
 		return Database(db), err
 	})
 }
 
-func newJsonConnector(filename string, l *logger.Logger) (*jsonConnector, error) {
+func newJsonConnector(filename string, masterKey []byte, l *logger.Logger) (*jsonConnector, error) {
 
 	if !mpm.FileExists(filepath.Dir("db/")) {
 		err := os.Mkdir(filepath.Dir("db/"), 0755)
@@ -207,7 +235,7 @@ func newJsonConnector(filename string, l *logger.Logger) (*jsonConnector, error)
 	}
 
 	if mpm.FileExists(filename) {
-		return loadJson(filename, l)
+		return loadJson(filename, masterKey, l)
 	}
 
 	j := &jsonConnector{
@@ -215,19 +243,35 @@ func newJsonConnector(filename string, l *logger.Logger) (*jsonConnector, error)
 		lock:     &sync.RWMutex{},
 		Settings: map[string]configValue{},
 
-		Cycles:      map[int]jsonCycle{},
-		Movies:      map[int]jsonMovie{},
-		Users:       map[int]jsonUser{},
-		Tags:        map[int]*mpm.Tag{},
-		Links:       map[int]*mpm.Link{},
-		AuthMethods: map[int]*mpm.AuthMethod{},
-		l:           l,
+		Cycles:        map[int]jsonCycle{},
+		Movies:        map[int]jsonMovie{},
+		Users:         map[int]jsonUser{},
+		Tags:          map[int]*mpm.Tag{},
+		Links:         map[int]*mpm.Link{},
+		AuthMethods:   map[int]*mpm.AuthMethod{},
+		Bans:          map[int]*mpm.Ban{},
+		Roles:         map[int]*mpm.Role{},
+		AuditLog:      []*mpm.AuditEntry{},
+		Notifications: map[int]*mpm.Notification{},
+		Sessions:      map[int]*mpm.Session{},
+		l:             l,
+
+		secretSettings: map[string]bool{},
+	}
+
+	if masterKey != nil {
+		box, err := secrets.NewBox(masterKey, "")
+		if err != nil {
+			return nil, err
+		}
+		j.secrets = box
+		j.DataKey = box.DataKey()
 	}
 
 	return j, j.save()
 }
 
-func loadJson(filename string, l *logger.Logger) (*jsonConnector, error) {
+func loadJson(filename string, masterKey []byte, l *logger.Logger) (*jsonConnector, error) {
 	raw, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, err
@@ -242,6 +286,7 @@ func loadJson(filename string, l *logger.Logger) (*jsonConnector, error) {
 	data.filename = filename
 	data.lock = &sync.RWMutex{}
 	data.l = l
+	data.secretSettings = map[string]bool{}
 
 	if data.Settings == nil {
 		data.Settings = make(map[string]configValue)
@@ -275,23 +320,115 @@ func loadJson(filename string, l *logger.Logger) (*jsonConnector, error) {
 		data.AuthMethods = make(map[int]*mpm.AuthMethod)
 	}
 
+	if data.Bans == nil {
+		data.Bans = make(map[int]*mpm.Ban)
+	}
+
+	if data.Roles == nil {
+		data.Roles = make(map[int]*mpm.Role)
+	}
+
+	if data.AuditLog == nil {
+		data.AuditLog = []*mpm.AuditEntry{}
+	}
+
+	if data.Notifications == nil {
+		data.Notifications = make(map[int]*mpm.Notification)
+	}
+
+	if data.Sessions == nil {
+		data.Sessions = make(map[int]*mpm.Session)
+	}
+
+	if masterKey != nil {
+		box, err := secrets.NewBox(masterKey, data.DataKey)
+		if err != nil {
+			return nil, err
+		}
+		data.secrets = box
+		data.DataKey = box.DataKey()
+	}
+
+	plain, err := data.openSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	if data.secrets == nil {
+		data.DataKey = ""
+	} else if plain > 0 {
+		// Don't leave them in plain text until the next change.
+		l.Info("Encrypting %d secrets saved in plain text", plain)
+		if err := data.save(); err != nil {
+			return nil, err
+		}
+	}
+
 	return data, nil
 }
 
+// save writes the data to a temporary file and moves it over the old one,
+// so a crash while saving doesn't leave a truncated file behind.  The caller
+// must hold the lock.
 func (j *jsonConnector) save() error {
-	raw, err := json.MarshalIndent(j, "", " ")
+	if j.closed {
+		return fmt.Errorf("Unable to save JSON data: the database is closed")
+	}
+
+	defer metricJsonSave.ObserveSince(time.Now())
+
+	out, err := j.sealSecrets()
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.MarshalIndent(out, "", " ")
 	if err != nil {
 		return fmt.Errorf("Unable to marshal JSON data: %v", err)
 	}
 
-	err = ioutil.WriteFile(j.filename, raw, 0777)
+	tmp := j.filename + ".tmp"
+	err = ioutil.WriteFile(tmp, raw, 0777)
 	if err != nil {
 		return fmt.Errorf("Unable to write JSON data: %v", err)
 	}
 
+	err = os.Rename(tmp, j.filename)
+	if err != nil {
+		return fmt.Errorf("Unable to replace JSON data: %v", err)
+	}
+
 	return nil
 }
 
+func (j *jsonConnector) Ping() error {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	if j.closed {
+		return fmt.Errorf("The database is closed")
+	}
+
+	if _, err := os.Stat(j.filename); err != nil {
+		return fmt.Errorf("Unable to find JSON data: %v", err)
+	}
+	return nil
+}
+
+// Close waits for running saves and saves one last time.
+func (j *jsonConnector) Close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.closed {
+		return nil
+	}
+
+	err := j.save()
+	j.closed = true
+	return err
+}
+
 /*
    On determining the current cycle.
 
@@ -517,6 +654,19 @@ func (s sortableCycle) Len() int { return len(s) }
 func (s sortableCycle) Less(i, j int) bool { return s[i].Id > s[j].Id }
 func (s sortableCycle) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 
+func (j *jsonConnector) GetAllMovies() ([]*mpm.Movie, error) {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	movies := []*mpm.Movie{}
+	for _, m := range j.Movies {
+		movies = append(movies, j.findMovie(m.Id))
+	}
+
+	sort.Slice(movies, func(i, k int) bool { return movies[i].Id < movies[k].Id })
+	return movies, nil
+}
+
 func (j *jsonConnector) GetPastCycles(start, end int) ([]*mpm.Cycle, error) {
 	j.lock.RLock()
 	defer j.lock.RUnlock()
@@ -571,20 +721,25 @@ func (j *jsonConnector) movieFromJson(jMovie jsonMovie) *mpm.Movie {
 	}
 
 	movie := &mpm.Movie{
-		Id:          jMovie.Id,
-		Name:        jMovie.Name,
-		Description: jMovie.Description,
-		Duration:    jMovie.Duration,
-		Rating:      jMovie.Rating,
-		Remarks:     jMovie.Remarks,
-		Removed:     jMovie.Removed,
-		Approved:    jMovie.Approved,
+		Id:            jMovie.Id,
+		Name:          jMovie.Name,
+		OriginalTitle: jMovie.OriginalTitle,
+		Description:   jMovie.Description,
+		Duration:      jMovie.Duration,
+		Rating:        jMovie.Rating,
+		Remarks:       jMovie.Remarks,
+		Removed:       jMovie.Removed,
+		Approved:      jMovie.Approved,
+		Pending:       jMovie.Pending,
+		Denied:        jMovie.Denied,
+		DenyReason:    jMovie.DenyReason,
 		//CycleAdded:   j.findCycle(jMovie.CycleAddedId),
 		//CycleWatched: j.findCycle(jMovie.CycleWatchedId),
-		Links:   links,
-		Poster:  jMovie.Poster,
-		AddedBy: user,
-		Tags:    tags,
+		Links:        links,
+		Poster:       mpm.PosterKey(jMovie.Poster),
+		PosterSource: jMovie.PosterSource,
+		AddedBy:      user,
+		Tags:         tags,
 	}
 
 	return movie
@@ -610,6 +765,7 @@ func (j *jsonConnector) userFromJson(jUser jsonUser) *mpm.User {
 		NotifyVoteSelection: jUser.NotifyVoteSelection,
 		AuthMethods:         authMethods,
 		Privilege:           mpm.PrivilegeLevel(jUser.Privilege),
+		Role:                j.Roles[jUser.RoleId],
 	}
 
 	return user
@@ -985,7 +1141,7 @@ func (j *jsonConnector) FindTag(name string) (int, error) {
 			return id, nil
 		}
 	}
-	return 0, fmt.Errorf("No tag found with name: %s", name)
+	return 0, fmt.Errorf("No tag found with name %s: %w", name, ErrNoValue)
 }
 
 func (j *jsonConnector) GetTag(id int) *mpm.Tag {
@@ -994,6 +1150,19 @@ func (j *jsonConnector) GetTag(id int) *mpm.Tag {
 	return j.Tags[id]
 }
 
+func (j *jsonConnector) GetTags() ([]*mpm.Tag, error) {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	tags := []*mpm.Tag{}
+	for _, tag := range j.Tags {
+		tags = append(tags, tag)
+	}
+
+	sort.Slice(tags, func(i, k int) bool { return tags[i].Id < tags[k].Id })
+	return tags, nil
+}
+
 func (j *jsonConnector) GetAuthMethod(id int) *mpm.AuthMethod {
 	j.lock.RLock()
 	defer j.lock.RUnlock()
@@ -1015,11 +1184,25 @@ func (j *jsonConnector) UpdateAuthMethod(authMethod *mpm.AuthMethod) error {
 	j.AuthMethods[authMethod.Id] = authMethod
 	return j.save()
 }
+func (j *jsonConnector) UpdateTag(tag *mpm.Tag) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if _, ok := j.Tags[tag.Id]; !ok {
+		return fmt.Errorf("Tag with ID %d does not exist", tag.Id)
+	}
+
+	j.Tags[tag.Id] = tag
+	return j.save()
+}
+
 func (j *jsonConnector) DeleteTag(id int) {
 	j.lock.Lock()
 	defer j.lock.Unlock()
 
 	delete(j.Tags, id)
+
+	j.save()
 }
 
 func (j *jsonConnector) DeleteAuthMethod(id int) {
@@ -1099,11 +1282,42 @@ func (j *jsonConnector) GetLink(id int) *mpm.Link {
 	return j.Links[id]
 }
 
+func (j *jsonConnector) GetLinks() ([]*mpm.Link, error) {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	links := []*mpm.Link{}
+	for _, link := range j.Links {
+		links = append(links, link)
+	}
+
+	sort.Slice(links, func(i, k int) bool { return links[i].Id < links[k].Id })
+	return links, nil
+}
+
 func (j *jsonConnector) DeleteLink(id int) {
 	j.lock.Lock()
 	defer j.lock.Unlock()
 
 	delete(j.Links, id)
+
+	j.save()
+}
+
+// DeleteLinksAndTags saves only once, instead of once per item.
+func (j *jsonConnector) DeleteLinksAndTags(linkIds, tagIds []int) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	for _, id := range linkIds {
+		delete(j.Links, id)
+	}
+
+	for _, id := range tagIds {
+		delete(j.Tags, id)
+	}
+
+	return j.save()
 }
 
 func (j *jsonConnector) nextLinkId() int {
@@ -1266,8 +1480,17 @@ func (j *jsonConnector) UpdateMovie(movie *mpm.Movie) error {
 	j.lock.Lock()
 	defer j.lock.Unlock()
 
+	// A movie that has been deleted in the meantime isn't added back.
+	old, ok := j.Movies[movie.Id]
+	if !ok {
+		return fmt.Errorf("Movie with ID %d not found: %w", movie.Id, ErrNoValue)
+	}
+
 	m := j.newJsonMovie(movie)
 	m.Id = movie.Id
+
+	// Keep the cycle the movie was originally added in.
+	m.CycleAddedId = old.CycleAddedId
 	j.Movies[m.Id] = m
 
 	return j.save()
@@ -1465,6 +1688,12 @@ func (j *jsonConnector) PurgeUser(userId int) error {
 		delete(j.AuthMethods, auth.Id)
 	}
 
+	for id, session := range j.Sessions {
+		if session.UserId == userId {
+			delete(j.Sessions, id)
+		}
+	}
+
 	j.Votes = newVotes
 	j.l.Info("Purged %d votes", count)
 
@@ -1472,33 +1701,6 @@ func (j *jsonConnector) PurgeUser(userId int) error {
 	return j.save()
 }
 
-func (j *jsonConnector) SearchMovieTitles(query string) ([]*mpm.Movie, error) {
-	j.lock.RLock()
-	defer j.lock.RUnlock()
-
-	found := []*mpm.Movie{}
-	query = strings.ToLower(query)
-	words := strings.Split(query, " ")
-
-	for _, movie := range j.Movies {
-		ok := true
-		for _, word := range words {
-			if !strings.Contains(strings.ToLower(movie.Name), word) {
-				ok = false
-				break
-			}
-		}
-
-		if ok {
-			m := j.findMovie(movie.Id)
-			m.Votes = j.findVotes(m)
-			found = append(found, m)
-		}
-	}
-
-	return found, nil
-}
-
 func (j *jsonConnector) DeleteCycle(cycleId int) error {
 	j.lock.Lock()
 	defer j.lock.Unlock()
@@ -1606,3 +1808,321 @@ func (j *jsonConnector) GetUsersWithAuth(auth mpm.AuthType, exclusive bool) ([]*
 	}
 	return res, nil
 }
+
+func (j *jsonConnector) AddBan(ban *mpm.Ban) (int, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	id := j.nextBanId()
+	ban.Id = id
+
+	j.Bans[id] = ban
+	return id, j.save()
+}
+
+func (j *jsonConnector) GetBans() ([]*mpm.Ban, error) {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	bans := []*mpm.Ban{}
+	for _, ban := range j.Bans {
+		bans = append(bans, ban)
+	}
+
+	sort.Slice(bans, func(i, k int) bool { return bans[i].Id < bans[k].Id })
+	return bans, nil
+}
+
+func (j *jsonConnector) DeleteBan(id int) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if _, ok := j.Bans[id]; !ok {
+		return fmt.Errorf("No ban with Id %d found.", id)
+	}
+
+	delete(j.Bans, id)
+	return j.save()
+}
+
+func (j *jsonConnector) nextBanId() int {
+	highest := 0
+	for _, b := range j.Bans {
+		if b.Id >= highest {
+			highest = b.Id
+		}
+	}
+	return highest + 1
+}
+
+func (j *jsonConnector) AddRole(role *mpm.Role) (int, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if role.Name == "" {
+		return 0, fmt.Errorf("Name cannot be empty")
+	}
+
+	for _, r := range j.Roles {
+		if strings.ToLower(r.Name) == strings.ToLower(role.Name) {
+			return 0, fmt.Errorf("Role already exists with name %s", role.Name)
+		}
+	}
+
+	id := j.nextRoleId()
+	role.Id = id
+
+	j.Roles[id] = role
+	return id, j.save()
+}
+
+func (j *jsonConnector) GetRole(id int) (*mpm.Role, error) {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	role, ok := j.Roles[id]
+	if !ok {
+		return nil, fmt.Errorf("Role with ID %d not found", id)
+	}
+	return role, nil
+}
+
+func (j *jsonConnector) GetRoles() ([]*mpm.Role, error) {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	roles := []*mpm.Role{}
+	for _, role := range j.Roles {
+		roles = append(roles, role)
+	}
+
+	sort.Slice(roles, func(i, k int) bool { return roles[i].Id < roles[k].Id })
+	return roles, nil
+}
+
+func (j *jsonConnector) UpdateRole(role *mpm.Role) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if _, ok := j.Roles[role.Id]; !ok {
+		return fmt.Errorf("Role with ID %d not found", role.Id)
+	}
+
+	for _, r := range j.Roles {
+		if r.Id != role.Id && strings.ToLower(r.Name) == strings.ToLower(role.Name) {
+			return fmt.Errorf("Role already exists with name %s", role.Name)
+		}
+	}
+
+	j.Roles[role.Id] = role
+	return j.save()
+}
+
+// DeleteRole removes the role and unassigns it from all users.
+func (j *jsonConnector) DeleteRole(id int) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if _, ok := j.Roles[id]; !ok {
+		return fmt.Errorf("Role with ID %d not found", id)
+	}
+
+	for uid, user := range j.Users {
+		if user.RoleId == id {
+			user.RoleId = 0
+			j.Users[uid] = user
+		}
+	}
+
+	delete(j.Roles, id)
+	return j.save()
+}
+
+func (j *jsonConnector) nextRoleId() int {
+	highest := 0
+	for _, r := range j.Roles {
+		if r.Id >= highest {
+			highest = r.Id
+		}
+	}
+	return highest + 1
+}
+
+func (j *jsonConnector) AddAuditEntry(entry *mpm.AuditEntry) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	entry.Id = 1
+	if len(j.AuditLog) > 0 {
+		entry.Id = j.AuditLog[len(j.AuditLog)-1].Id + 1
+	}
+
+	j.AuditLog = append(j.AuditLog, entry)
+	return j.save()
+}
+
+func (j *jsonConnector) GetAuditEntries() ([]*mpm.AuditEntry, error) {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	entries := make([]*mpm.AuditEntry, len(j.AuditLog))
+	copy(entries, j.AuditLog)
+	return entries, nil
+}
+
+func (j *jsonConnector) AddNotification(notification *mpm.Notification) (int, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	id := j.nextNotificationId()
+	notification.Id = id
+
+	j.Notifications[id] = notification
+	return id, j.save()
+}
+
+func (j *jsonConnector) GetUserNotifications(userId int) ([]*mpm.Notification, error) {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	notifications := []*mpm.Notification{}
+	for _, n := range j.Notifications {
+		if n.UserId == userId {
+			notifications = append(notifications, n)
+		}
+	}
+
+	sort.Slice(notifications, func(i, k int) bool { return notifications[i].Id > notifications[k].Id })
+	return notifications, nil
+}
+
+func (j *jsonConnector) MarkNotificationsRead(userId int) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	for _, n := range j.Notifications {
+		if n.UserId == userId {
+			n.Read = true
+		}
+	}
+	return j.save()
+}
+
+func (j *jsonConnector) nextNotificationId() int {
+	highest := 0
+	for _, n := range j.Notifications {
+		if n.Id >= highest {
+			highest = n.Id
+		}
+	}
+	return highest + 1
+}
+
+// Sessions are copied in and out, so the LastSeen updates of concurrent
+// requests don't change the stored data behind the lock's back.
+
+func (j *jsonConnector) AddSession(session *mpm.Session) (int, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	id := j.nextSessionId()
+	session.Id = id
+
+	stored := *session
+	j.Sessions[id] = &stored
+	return id, j.save()
+}
+
+func (j *jsonConnector) GetSession(tokenHash string) (*mpm.Session, error) {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	for _, session := range j.Sessions {
+		if session.TokenHash == tokenHash {
+			found := *session
+			return &found, nil
+		}
+	}
+	return nil, ErrNoValue
+}
+
+func (j *jsonConnector) GetUserSessions(userId int) ([]*mpm.Session, error) {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	sessions := []*mpm.Session{}
+	for _, session := range j.Sessions {
+		if session.UserId == userId {
+			found := *session
+			sessions = append(sessions, &found)
+		}
+	}
+
+	sort.Slice(sessions, func(i, k int) bool { return sessions[i].LastSeen.After(sessions[k].LastSeen) })
+	return sessions, nil
+}
+
+func (j *jsonConnector) UpdateSession(session *mpm.Session) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if _, ok := j.Sessions[session.Id]; !ok {
+		return fmt.Errorf("Session with ID %d not found", session.Id)
+	}
+
+	stored := *session
+	j.Sessions[session.Id] = &stored
+	return j.save()
+}
+
+func (j *jsonConnector) DeleteSession(id int) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if _, ok := j.Sessions[id]; !ok {
+		return fmt.Errorf("Session with ID %d not found", id)
+	}
+
+	delete(j.Sessions, id)
+	return j.save()
+}
+
+func (j *jsonConnector) DeleteUserSessions(userId int) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	for id, session := range j.Sessions {
+		if session.UserId == userId {
+			delete(j.Sessions, id)
+		}
+	}
+	return j.save()
+}
+
+func (j *jsonConnector) DeleteSessionsBefore(lastSeen time.Time) (int, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	count := 0
+	for id, session := range j.Sessions {
+		if session.LastSeen.Before(lastSeen) {
+			delete(j.Sessions, id)
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+	return count, j.save()
+}
+
+func (j *jsonConnector) nextSessionId() int {
+	highest := 0
+	for _, s := range j.Sessions {
+		if s.Id >= highest {
+			highest = s.Id
+		}
+	}
+	return highest + 1
+}
@@ -0,0 +1,31 @@
+package database
+
+import (
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/metrics"
+)
+
+var metricOperationDuration = metrics.NewHistogram(
+	"moviepolls_database_operation_duration_seconds",
+	"Duration of database operations.",
+	[]float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	"operation")
+
+var metricJsonSave = metrics.NewHistogram(
+	"moviepolls_json_save_duration_seconds",
+	"Duration of writing the JSON database to disk.",
+	metrics.DefaultBuckets)
+
+//go:generate go run gen_timed.go
+
+// timedDatabase records the duration of every call to the database it
+// wraps.  Its methods are generated from the Database interface, see
+// gen_timed.go.
+type timedDatabase struct {
+	db Database
+}
+
+func observeOperation(operation string, start time.Time) {
+	metricOperationDuration.ObserveSince(start, operation)
+}
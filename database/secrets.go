@@ -0,0 +1,158 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/zorchenhimer/MoviePolls/logger"
+	mpm "github.com/zorchenhimer/MoviePolls/models"
+	"github.com/zorchenhimer/MoviePolls/secrets"
+)
+
+// Secrets are encrypted with a data key before they are written to disk.
+// The data key is saved next to them, encrypted with the master key that is
+// only given to the server on start.  In memory everything is in plain text,
+// the connector encrypts on save and decrypts on load.
+//
+// OAuth tokens are always secret.  Which settings are is up to the caller,
+// see SetSecretSettings.  Settings that were saved encrypted stay encrypted.
+
+// openSecrets decrypts the OAuth tokens and secret settings after loading and
+// remembers the encrypted settings as secret.  It returns the number of
+// secrets that were saved in plain text.
+func (j *jsonConnector) openSecrets() (int, error) {
+	plain := 0
+	label := ""
+
+	decrypt := func(value string) (string, error) {
+		if !secrets.IsSealed(value) {
+			if value != "" {
+				plain++
+			}
+			return value, nil
+		}
+		if j.secrets == nil {
+			return "", fmt.Errorf("%s is encrypted, but no master key was given", label)
+		}
+		return j.secrets.Open(label, value)
+	}
+
+	var err error
+	for _, auth := range j.AuthMethods {
+		label = "AuthMethod.AuthToken"
+		if auth.AuthToken, err = decrypt(auth.AuthToken); err != nil {
+			return 0, err
+		}
+		label = "AuthMethod.RefreshToken"
+		if auth.RefreshToken, err = decrypt(auth.RefreshToken); err != nil {
+			return 0, err
+		}
+	}
+
+	for key, val := range j.Settings {
+		str, ok := val.Value.(string)
+		if val.Type != CVT_STRING || !ok || !secrets.IsSealed(str) {
+			continue
+		}
+
+		label = "Settings." + key
+		if val.Value, err = decrypt(str); err != nil {
+			return 0, err
+		}
+		j.Settings[key] = val
+		j.secretSettings[key] = true
+	}
+
+	return plain, nil
+}
+
+// SetSecretSettings marks the settings with the given keys as secret, they
+// are encrypted from now on.  Secret settings still in plain text are
+// encrypted right away.
+func (j *jsonConnector) SetSecretSettings(keys []string) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	// Settings that were secret already have been saved encrypted.
+	plain := 0
+	for _, key := range keys {
+		if j.secretSettings[key] {
+			continue
+		}
+		j.secretSettings[key] = true
+
+		if str, ok := j.Settings[key].Value.(string); ok && str != "" {
+			plain++
+		}
+	}
+
+	if j.secrets == nil || plain == 0 {
+		return nil
+	}
+
+	j.l.Info("Encrypting %d settings saved in plain text", plain)
+	return j.save()
+}
+
+// sealSecrets returns a copy of j to save, with the OAuth tokens and secret
+// settings encrypted.  Without a master key j itself is returned.
+func (j *jsonConnector) sealSecrets() (*jsonConnector, error) {
+	if j.secrets == nil {
+		return j, nil
+	}
+
+	out := *j
+	var err error
+
+	out.AuthMethods = make(map[int]*mpm.AuthMethod, len(j.AuthMethods))
+	for id, auth := range j.AuthMethods {
+		sealed := *auth
+		if sealed.AuthToken, err = j.secrets.Seal("AuthMethod.AuthToken", auth.AuthToken); err != nil {
+			return nil, err
+		}
+		if sealed.RefreshToken, err = j.secrets.Seal("AuthMethod.RefreshToken", auth.RefreshToken); err != nil {
+			return nil, err
+		}
+		out.AuthMethods[id] = &sealed
+	}
+
+	out.Settings = make(map[string]configValue, len(j.Settings))
+	for key, val := range j.Settings {
+		if str, ok := val.Value.(string); ok && val.Type == CVT_STRING && j.secretSettings[key] {
+			if val.Value, err = j.secrets.Seal("Settings."+key, str); err != nil {
+				return nil, err
+			}
+		}
+		out.Settings[key] = val
+	}
+
+	return &out, nil
+}
+
+// Reencrypt loads the data with the old master key and saves it again with
+// the new one, eg after the old one leaked.  A new data key is made as well.
+// Either key may be nil, to turn encryption on or off.  The server must not
+// be running.
+func Reencrypt(backend, connectionString string, oldKey, newKey []byte, l *logger.Logger) error {
+	if backend != "json" {
+		return fmt.Errorf("Backend %s doesn't support encryption", backend)
+	}
+
+	j, err := loadJson(connectionString, oldKey, l)
+	if err != nil {
+		return err
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.secrets = nil
+	j.DataKey = ""
+	if newKey != nil {
+		if j.secrets, err = secrets.NewBox(newKey, ""); err != nil {
+			return err
+		}
+		j.DataKey = j.secrets.DataKey()
+	}
+
+	return j.save()
+}
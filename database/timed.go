@@ -0,0 +1,399 @@
+// Code generated by gen_timed.go; DO NOT EDIT.
+
+package database
+
+import (
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+func (t *timedDatabase) AddCycle(plannedEnd *time.Time) (int, error) {
+	defer observeOperation("AddCycle", time.Now())
+	return t.db.AddCycle(plannedEnd)
+}
+
+func (t *timedDatabase) AddOldCycle(cycle *models.Cycle) (int, error) {
+	defer observeOperation("AddOldCycle", time.Now())
+	return t.db.AddOldCycle(cycle)
+}
+
+func (t *timedDatabase) AddMovie(movie *models.Movie) (int, error) {
+	defer observeOperation("AddMovie", time.Now())
+	return t.db.AddMovie(movie)
+}
+
+func (t *timedDatabase) AddUser(user *models.User) (int, error) {
+	defer observeOperation("AddUser", time.Now())
+	return t.db.AddUser(user)
+}
+
+func (t *timedDatabase) AddTag(tag *models.Tag) (int, error) {
+	defer observeOperation("AddTag", time.Now())
+	return t.db.AddTag(tag)
+}
+
+func (t *timedDatabase) AddAuthMethod(authMethod *models.AuthMethod) (int, error) {
+	defer observeOperation("AddAuthMethod", time.Now())
+	return t.db.AddAuthMethod(authMethod)
+}
+
+func (t *timedDatabase) AddLink(link *models.Link) (int, error) {
+	defer observeOperation("AddLink", time.Now())
+	return t.db.AddLink(link)
+}
+
+func (t *timedDatabase) AddVote(userId, movieId int) error {
+	defer observeOperation("AddVote", time.Now())
+	return t.db.AddVote(userId, movieId)
+}
+
+func (t *timedDatabase) AddBan(ban *models.Ban) (int, error) {
+	defer observeOperation("AddBan", time.Now())
+	return t.db.AddBan(ban)
+}
+
+func (t *timedDatabase) AddRole(role *models.Role) (int, error) {
+	defer observeOperation("AddRole", time.Now())
+	return t.db.AddRole(role)
+}
+
+func (t *timedDatabase) AddAuditEntry(entry *models.AuditEntry) error {
+	defer observeOperation("AddAuditEntry", time.Now())
+	return t.db.AddAuditEntry(entry)
+}
+
+func (t *timedDatabase) AddNotification(notification *models.Notification) (int, error) {
+	defer observeOperation("AddNotification", time.Now())
+	return t.db.AddNotification(notification)
+}
+
+func (t *timedDatabase) AddSession(session *models.Session) (int, error) {
+	defer observeOperation("AddSession", time.Now())
+	return t.db.AddSession(session)
+}
+
+func (t *timedDatabase) GetCycle(id int) (*models.Cycle, error) {
+	defer observeOperation("GetCycle", time.Now())
+	return t.db.GetCycle(id)
+}
+
+func (t *timedDatabase) GetCurrentCycle() (*models.Cycle, error) {
+	defer observeOperation("GetCurrentCycle", time.Now())
+	return t.db.GetCurrentCycle()
+}
+
+func (t *timedDatabase) GetMovie(id int) (*models.Movie, error) {
+	defer observeOperation("GetMovie", time.Now())
+	return t.db.GetMovie(id)
+}
+
+func (t *timedDatabase) GetActiveMovies() ([]*models.Movie, error) {
+	defer observeOperation("GetActiveMovies", time.Now())
+	return t.db.GetActiveMovies()
+}
+
+func (t *timedDatabase) GetAllMovies() ([]*models.Movie, error) {
+	defer observeOperation("GetAllMovies", time.Now())
+	return t.db.GetAllMovies()
+}
+
+func (t *timedDatabase) GetUser(id int) (*models.User, error) {
+	defer observeOperation("GetUser", time.Now())
+	return t.db.GetUser(id)
+}
+
+func (t *timedDatabase) GetUsers(start, count int) ([]*models.User, error) {
+	defer observeOperation("GetUsers", time.Now())
+	return t.db.GetUsers(start, count)
+}
+
+func (t *timedDatabase) GetUserVotes(userId int) ([]*models.Movie, error) {
+	defer observeOperation("GetUserVotes", time.Now())
+	return t.db.GetUserVotes(userId)
+}
+
+func (t *timedDatabase) GetUserMovies(userId int) ([]*models.Movie, error) {
+	defer observeOperation("GetUserMovies", time.Now())
+	return t.db.GetUserMovies(userId)
+}
+
+func (t *timedDatabase) GetUsersWithAuth(auth models.AuthType, exclusive bool) ([]*models.User, error) {
+	defer observeOperation("GetUsersWithAuth", time.Now())
+	return t.db.GetUsersWithAuth(auth, exclusive)
+}
+
+func (t *timedDatabase) GetTag(id int) *models.Tag {
+	defer observeOperation("GetTag", time.Now())
+	return t.db.GetTag(id)
+}
+
+func (t *timedDatabase) GetAuthMethod(id int) *models.AuthMethod {
+	defer observeOperation("GetAuthMethod", time.Now())
+	return t.db.GetAuthMethod(id)
+}
+
+func (t *timedDatabase) GetLink(id int) *models.Link {
+	defer observeOperation("GetLink", time.Now())
+	return t.db.GetLink(id)
+}
+
+func (t *timedDatabase) GetLinks() ([]*models.Link, error) {
+	defer observeOperation("GetLinks", time.Now())
+	return t.db.GetLinks()
+}
+
+func (t *timedDatabase) GetTags() ([]*models.Tag, error) {
+	defer observeOperation("GetTags", time.Now())
+	return t.db.GetTags()
+}
+
+func (t *timedDatabase) GetBans() ([]*models.Ban, error) {
+	defer observeOperation("GetBans", time.Now())
+	return t.db.GetBans()
+}
+
+func (t *timedDatabase) GetRole(id int) (*models.Role, error) {
+	defer observeOperation("GetRole", time.Now())
+	return t.db.GetRole(id)
+}
+
+func (t *timedDatabase) GetRoles() ([]*models.Role, error) {
+	defer observeOperation("GetRoles", time.Now())
+	return t.db.GetRoles()
+}
+
+func (t *timedDatabase) GetAuditEntries() ([]*models.AuditEntry, error) {
+	defer observeOperation("GetAuditEntries", time.Now())
+	return t.db.GetAuditEntries()
+}
+
+func (t *timedDatabase) GetUserNotifications(userId int) ([]*models.Notification, error) {
+	defer observeOperation("GetUserNotifications", time.Now())
+	return t.db.GetUserNotifications(userId)
+}
+
+func (t *timedDatabase) GetSession(tokenHash string) (*models.Session, error) {
+	defer observeOperation("GetSession", time.Now())
+	return t.db.GetSession(tokenHash)
+}
+
+func (t *timedDatabase) GetUserSessions(userId int) ([]*models.Session, error) {
+	defer observeOperation("GetUserSessions", time.Now())
+	return t.db.GetUserSessions(userId)
+}
+
+func (t *timedDatabase) GetPastCycles(start, count int) ([]*models.Cycle, error) {
+	defer observeOperation("GetPastCycles", time.Now())
+	return t.db.GetPastCycles(start, count)
+}
+
+func (t *timedDatabase) GetMoviesFromCycle(id int) ([]*models.Movie, error) {
+	defer observeOperation("GetMoviesFromCycle", time.Now())
+	return t.db.GetMoviesFromCycle(id)
+}
+
+func (t *timedDatabase) FindTag(name string) (int, error) {
+	defer observeOperation("FindTag", time.Now())
+	return t.db.FindTag(name)
+}
+
+func (t *timedDatabase) FindLink(url string) (int, error) {
+	defer observeOperation("FindLink", time.Now())
+	return t.db.FindLink(url)
+}
+
+func (t *timedDatabase) UpdateUser(user *models.User) error {
+	defer observeOperation("UpdateUser", time.Now())
+	return t.db.UpdateUser(user)
+}
+
+func (t *timedDatabase) UpdateMovie(movie *models.Movie) error {
+	defer observeOperation("UpdateMovie", time.Now())
+	return t.db.UpdateMovie(movie)
+}
+
+func (t *timedDatabase) UpdateCycle(cycle *models.Cycle) error {
+	defer observeOperation("UpdateCycle", time.Now())
+	return t.db.UpdateCycle(cycle)
+}
+
+func (t *timedDatabase) UpdateAuthMethod(authMethod *models.AuthMethod) error {
+	defer observeOperation("UpdateAuthMethod", time.Now())
+	return t.db.UpdateAuthMethod(authMethod)
+}
+
+func (t *timedDatabase) UpdateTag(tag *models.Tag) error {
+	defer observeOperation("UpdateTag", time.Now())
+	return t.db.UpdateTag(tag)
+}
+
+func (t *timedDatabase) UpdateRole(role *models.Role) error {
+	defer observeOperation("UpdateRole", time.Now())
+	return t.db.UpdateRole(role)
+}
+
+func (t *timedDatabase) MarkNotificationsRead(userId int) error {
+	defer observeOperation("MarkNotificationsRead", time.Now())
+	return t.db.MarkNotificationsRead(userId)
+}
+
+func (t *timedDatabase) UpdateSession(session *models.Session) error {
+	defer observeOperation("UpdateSession", time.Now())
+	return t.db.UpdateSession(session)
+}
+
+func (t *timedDatabase) DeleteVote(userId, movieId int) error {
+	defer observeOperation("DeleteVote", time.Now())
+	return t.db.DeleteVote(userId, movieId)
+}
+
+func (t *timedDatabase) DeleteTag(tagId int) {
+	defer observeOperation("DeleteTag", time.Now())
+	t.db.DeleteTag(tagId)
+}
+
+func (t *timedDatabase) DeleteAuthMethod(authMethodId int) {
+	defer observeOperation("DeleteAuthMethod", time.Now())
+	t.db.DeleteAuthMethod(authMethodId)
+}
+
+func (t *timedDatabase) DeleteLink(linkId int) {
+	defer observeOperation("DeleteLink", time.Now())
+	t.db.DeleteLink(linkId)
+}
+
+func (t *timedDatabase) DeleteLinksAndTags(linkIds, tagIds []int) error {
+	defer observeOperation("DeleteLinksAndTags", time.Now())
+	return t.db.DeleteLinksAndTags(linkIds, tagIds)
+}
+
+func (t *timedDatabase) DeleteBan(banId int) error {
+	defer observeOperation("DeleteBan", time.Now())
+	return t.db.DeleteBan(banId)
+}
+
+func (t *timedDatabase) DeleteRole(roleId int) error {
+	defer observeOperation("DeleteRole", time.Now())
+	return t.db.DeleteRole(roleId)
+}
+
+func (t *timedDatabase) DeleteSession(sessionId int) error {
+	defer observeOperation("DeleteSession", time.Now())
+	return t.db.DeleteSession(sessionId)
+}
+
+func (t *timedDatabase) DeleteUserSessions(userId int) error {
+	defer observeOperation("DeleteUserSessions", time.Now())
+	return t.db.DeleteUserSessions(userId)
+}
+
+func (t *timedDatabase) DeleteSessionsBefore(lastSeen time.Time) (int, error) {
+	defer observeOperation("DeleteSessionsBefore", time.Now())
+	return t.db.DeleteSessionsBefore(lastSeen)
+}
+
+func (t *timedDatabase) RemoveMovie(movieId int) error {
+	defer observeOperation("RemoveMovie", time.Now())
+	return t.db.RemoveMovie(movieId)
+}
+
+func (t *timedDatabase) PurgeUser(userId int) error {
+	defer observeOperation("PurgeUser", time.Now())
+	return t.db.PurgeUser(userId)
+}
+
+func (t *timedDatabase) DecayVotes(age int) error {
+	defer observeOperation("DecayVotes", time.Now())
+	return t.db.DecayVotes(age)
+}
+
+func (t *timedDatabase) UserLocalLogin(name, hashedPw string) (*models.User, error) {
+	defer observeOperation("UserLocalLogin", time.Now())
+	return t.db.UserLocalLogin(name, hashedPw)
+}
+
+func (t *timedDatabase) UserDiscordLogin(extid string) (*models.User, error) {
+	defer observeOperation("UserDiscordLogin", time.Now())
+	return t.db.UserDiscordLogin(extid)
+}
+
+func (t *timedDatabase) UserTwitchLogin(extid string) (*models.User, error) {
+	defer observeOperation("UserTwitchLogin", time.Now())
+	return t.db.UserTwitchLogin(extid)
+}
+
+func (t *timedDatabase) UserPatreonLogin(extid string) (*models.User, error) {
+	defer observeOperation("UserPatreonLogin", time.Now())
+	return t.db.UserPatreonLogin(extid)
+}
+
+func (t *timedDatabase) CheckOauthUsage(id string, authtype models.AuthType) bool {
+	defer observeOperation("CheckOauthUsage", time.Now())
+	return t.db.CheckOauthUsage(id, authtype)
+}
+
+func (t *timedDatabase) CheckMovieExists(title string) (bool, error) {
+	defer observeOperation("CheckMovieExists", time.Now())
+	return t.db.CheckMovieExists(title)
+}
+
+func (t *timedDatabase) CheckUserExists(name string) (bool, error) {
+	defer observeOperation("CheckUserExists", time.Now())
+	return t.db.CheckUserExists(name)
+}
+
+func (t *timedDatabase) UserVotedForMovie(userId, movieId int) (bool, error) {
+	defer observeOperation("UserVotedForMovie", time.Now())
+	return t.db.UserVotedForMovie(userId, movieId)
+}
+
+func (t *timedDatabase) GetCfgString(key, value string) (string, error) {
+	defer observeOperation("GetCfgString", time.Now())
+	return t.db.GetCfgString(key, value)
+}
+
+func (t *timedDatabase) GetCfgInt(key string, value int) (int, error) {
+	defer observeOperation("GetCfgInt", time.Now())
+	return t.db.GetCfgInt(key, value)
+}
+
+func (t *timedDatabase) GetCfgBool(key string, value bool) (bool, error) {
+	defer observeOperation("GetCfgBool", time.Now())
+	return t.db.GetCfgBool(key, value)
+}
+
+func (t *timedDatabase) SetCfgString(key, value string) error {
+	defer observeOperation("SetCfgString", time.Now())
+	return t.db.SetCfgString(key, value)
+}
+
+func (t *timedDatabase) SetCfgInt(key string, value int) error {
+	defer observeOperation("SetCfgInt", time.Now())
+	return t.db.SetCfgInt(key, value)
+}
+
+func (t *timedDatabase) SetCfgBool(key string, value bool) error {
+	defer observeOperation("SetCfgBool", time.Now())
+	return t.db.SetCfgBool(key, value)
+}
+
+func (t *timedDatabase) DeleteCfgKey(key string) error {
+	defer observeOperation("DeleteCfgKey", time.Now())
+	return t.db.DeleteCfgKey(key)
+}
+
+func (t *timedDatabase) SetSecretSettings(keys []string) error {
+	defer observeOperation("SetSecretSettings", time.Now())
+	return t.db.SetSecretSettings(keys)
+}
+
+func (t *timedDatabase) Ping() error {
+	defer observeOperation("Ping", time.Now())
+	return t.db.Ping()
+}
+
+func (t *timedDatabase) Close() error {
+	defer observeOperation("Close", time.Now())
+	return t.db.Close()
+}
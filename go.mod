@@ -8,5 +8,9 @@ require (
 	github.com/mitchellh/mapstructure v1.3.3
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
 	github.com/rivo/uniseg v0.1.0
+	golang.org/x/crypto v0.14.0
+	golang.org/x/image v0.0.0-20200927104501-e162460cd6b5
+	golang.org/x/net v0.17.0 // indirect
 	golang.org/x/oauth2 v0.0.0-20200902213428-5d25da1a8d43
+	golang.org/x/text v0.13.0 // indirect
 )
@@ -1,12 +1,17 @@
 package logger
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type LogLevel string
@@ -24,104 +29,422 @@ const (
 	logPrefixDebug string = "[DEBUG] "
 )
 
-type Logger struct {
-	lInfo  *log.Logger
-	lError *log.Logger
-	lDebug *log.Logger
-}
+// Format of the log entries, the same for the console and the file.
+type Format string
 
-func (l *Logger) Info(s string, v ...interface{}) {
-	if l.lInfo == nil {
-		return
+const (
+	FormatText Format = "text" // [INFO] 2006/01/02 15:04:05 message key=value
+	FormatJSON Format = "json" // one JSON object per line
+)
+
+// RootSubsystem is the subsystem of the logger returned by New.  Loggers for
+// the other subsystems are made with Sub.
+const RootSubsystem string = "server"
+
+// ParseLevel checks that the given string is a known level.
+func ParseLevel(level string) (LogLevel, error) {
+	l := LogLevel(strings.ToLower(level))
+	if _, ok := l.rank(); !ok {
+		return "", fmt.Errorf("Invalid log level: %q", level)
 	}
+	return l, nil
+}
 
-	l.lInfo.Printf(s+"\n", v...)
+// rank orders the levels.  An entry is written if its rank is at least the
+// rank of the subsystem's level.
+func (l LogLevel) rank() (int, bool) {
+	switch l {
+	case LLDebug:
+		return 0, true
+	case LLInfo:
+		return 1, true
+	case LLError:
+		return 2, true
+	case LLSilent:
+		return 3, true
+	}
+	return 0, false
 }
 
-func (l *Logger) Error(s string, v ...interface{}) {
-	if l.lError == nil {
-		return
+func (l LogLevel) prefix() string {
+	switch l {
+	case LLError:
+		return logPrefixError
+	case LLInfo:
+		return logPrefixInfo
 	}
+	return logPrefixDebug
+}
 
-	l.lError.Printf(s+"\n", v...)
+type Options struct {
+	Level  LogLevel            // level of every subsystem that isn't in Levels
+	Levels map[string]LogLevel // eg, {"database": LLError}
+	Format Format              // defaults to FormatText
+	File   string              // also log to this file, if not empty
+	Rotate RotateOptions       // how the file is rotated
 }
 
-func (l *Logger) Debug(s string, v ...interface{}) {
-	if l.lDebug == nil {
-		return
-	}
+// Logger writes leveled entries for a subsystem.  The zero value discards
+// everything.
+type Logger struct {
+	out       *output
+	subsystem string
+	fields    []field
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// output is shared by a logger and every logger derived from it.
+type output struct {
+	format Format
+	stdout io.Writer
+	stderr io.Writer
+	file   io.WriteCloser // nil without a log file
 
-	l.lDebug.Printf(s+"\n", v...)
+	writeLock sync.Mutex
+
+	levelLock    sync.RWMutex
+	defaultLevel LogLevel
+	levels       map[string]LogLevel
 }
 
+// NewLogger returns a text logger without rotation.
 func NewLogger(level LogLevel, file string) (*Logger, error) {
-	l := &Logger{}
-	baseDir := filepath.Dir(file)
-	err := os.MkdirAll(baseDir, 0755)
+	return New(Options{Level: level, File: file})
+}
+
+func New(opts Options) (*Logger, error) {
+	var file io.WriteCloser
+	if opts.File != "" {
+		baseDir := filepath.Dir(opts.File)
+		err := os.MkdirAll(baseDir, 0755)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to create log directory %q: %w", baseDir, err)
+		}
+
+		f, err := openRotatingFile(opts.File, opts.Rotate)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to open log file for writing: %s", err)
+		}
+		file = f
+	}
+
+	l, err := newLogger(opts, os.Stdout, os.Stderr, file)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to create log directory %q: %w", baseDir, err)
+		if file != nil {
+			file.Close()
+		}
+		return nil, err
 	}
 
-	switch LogLevel(strings.ToLower(string(level))) {
+	switch l.out.defaultLevel {
 	case LLSilent:
 		fmt.Println("[SILENT] Nothing to see here, please leave the area!")
 		return l, nil
+	default:
+		fmt.Println(strings.TrimSpace(l.out.defaultLevel.prefix()) + " Logging enabled")
+	}
 
-	case LLError:
-		fmt.Println(logPrefixError + "Logging enabled")
-		if file != "" {
-			f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				return nil, fmt.Errorf("Unable to open log file for writing: %s", err)
-			}
-
-			l.lError = log.New(io.MultiWriter(os.Stderr, f), logPrefixError, log.LstdFlags)
-		} else {
-			l.lError = log.New(os.Stderr, logPrefixError, log.LstdFlags)
+	if opts.File != "" {
+		l.Info("Logging to file " + opts.File)
+	} else {
+		l.Info("Logging to console only")
+	}
+
+	return l, nil
+}
+
+func newLogger(opts Options, stdout, stderr io.Writer, file io.WriteCloser) (*Logger, error) {
+	level, err := ParseLevel(string(opts.Level))
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts.Format {
+	case "":
+		opts.Format = FormatText
+	case FormatText, FormatJSON:
+	default:
+		return nil, fmt.Errorf("Invalid log format: %q", opts.Format)
+	}
+
+	out := &output{
+		format:       opts.Format,
+		stdout:       stdout,
+		stderr:       stderr,
+		file:         file,
+		defaultLevel: level,
+		levels:       map[string]LogLevel{RootSubsystem: level},
+	}
+
+	for sub, lvl := range opts.Levels {
+		parsed, err := ParseLevel(string(lvl))
+		if err != nil {
+			return nil, fmt.Errorf("Level of %s: %w", sub, err)
 		}
+		out.levels[sub] = parsed
+	}
 
-	case LLInfo:
-		fmt.Println(logPrefixInfo + "Logging enabled")
-		if file != "" {
-			f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				return nil, fmt.Errorf("Unable to open log file for writing: %s", err)
-			}
-
-			l.lError = log.New(io.MultiWriter(os.Stderr, f), logPrefixError, log.LstdFlags)
-			l.lInfo = log.New(io.MultiWriter(os.Stdout, f), logPrefixInfo, log.LstdFlags)
-		} else {
-			l.lError = log.New(os.Stderr, logPrefixError, log.LstdFlags)
-			l.lInfo = log.New(os.Stdout, logPrefixInfo, log.LstdFlags)
+	return &Logger{out: out, subsystem: RootSubsystem}, nil
+}
+
+// ParseLevels parses a list of subsystem levels, eg "database=error,oauth=debug".
+func ParseLevels(list string) (map[string]LogLevel, error) {
+	levels := map[string]LogLevel{}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
 		}
 
-	case LLDebug:
-		fmt.Println(logPrefixDebug + "Logging enabled")
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("Invalid subsystem level %q, expected subsystem=level", item)
+		}
+
+		level, err := ParseLevel(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, err
+		}
+		levels[strings.TrimSpace(parts[0])] = level
+	}
+	return levels, nil
+}
 
-		if file != "" {
-			f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				return nil, fmt.Errorf("Unable to open log file for writing: %s", err)
-			}
+// Sub returns a logger for the given subsystem, eg "database".  Its level
+// can be changed on its own with SetLevel.
+func (l *Logger) Sub(subsystem string) *Logger {
+	if l.out != nil {
+		l.out.levelLock.Lock()
+		if _, ok := l.out.levels[subsystem]; !ok {
+			l.out.levels[subsystem] = l.out.defaultLevel
+		}
+		l.out.levelLock.Unlock()
+	}
 
-			l.lError = log.New(io.MultiWriter(os.Stderr, f), logPrefixError, log.LstdFlags)
-			l.lInfo = log.New(io.MultiWriter(os.Stdout, f), logPrefixInfo, log.LstdFlags)
-			l.lDebug = log.New(io.MultiWriter(os.Stdout, f), logPrefixDebug, log.LstdFlags)
+	return &Logger{out: l.out, subsystem: subsystem, fields: l.fields}
+}
+
+// With returns a logger that adds the given key/value pairs to every entry,
+// eg l.With("user", id, "movie", movieId).Info("Vote added").
+func (l *Logger) With(keyValues ...interface{}) *Logger {
+	fields := make([]field, len(l.fields), len(l.fields)+len(keyValues)/2+1)
+	copy(fields, l.fields)
+
+	for i := 0; i < len(keyValues); i += 2 {
+		key := fmt.Sprint(keyValues[i])
+		if i+1 < len(keyValues) {
+			fields = append(fields, field{key, keyValues[i+1]})
 		} else {
-			l.lError = log.New(os.Stderr, logPrefixError, log.LstdFlags)
-			l.lInfo = log.New(os.Stdout, logPrefixInfo, log.LstdFlags)
-			l.lDebug = log.New(os.Stdout, logPrefixDebug, log.LstdFlags)
+			fields = append(fields, field{key, "!MISSING"})
 		}
+	}
 
-	default:
-		return nil, fmt.Errorf("Invalid log level: %q", level)
+	return &Logger{out: l.out, subsystem: l.subsystem, fields: fields}
+}
+
+// SetLevel changes the level of a subsystem while the server is running.
+func (l *Logger) SetLevel(subsystem string, level LogLevel) error {
+	if l.out == nil {
+		return nil
+	}
+
+	parsed, err := ParseLevel(string(level))
+	if err != nil {
+		return err
+	}
+
+	l.out.levelLock.Lock()
+	defer l.out.levelLock.Unlock()
+
+	if _, ok := l.out.levels[subsystem]; !ok {
+		return fmt.Errorf("Unknown log subsystem: %q", subsystem)
+	}
+	l.out.levels[subsystem] = parsed
+	return nil
+}
+
+// Levels returns the current level of every subsystem.
+func (l *Logger) Levels() map[string]LogLevel {
+	levels := map[string]LogLevel{}
+	if l.out == nil {
+		return levels
+	}
+
+	l.out.levelLock.RLock()
+	defer l.out.levelLock.RUnlock()
+
+	for sub, level := range l.out.levels {
+		levels[sub] = level
+	}
+	return levels
+}
+
+// Subsystems returns the names of every subsystem, sorted.
+func (l *Logger) Subsystems() []string {
+	subs := []string{}
+	for sub := range l.Levels() {
+		subs = append(subs, sub)
+	}
+	sort.Strings(subs)
+	return subs
+}
+
+// Close closes the log file.  Entries are still written to the console
+// afterwards.
+func (l *Logger) Close() error {
+	if l.out == nil || l.out.file == nil {
+		return nil
+	}
+
+	l.out.writeLock.Lock()
+	defer l.out.writeLock.Unlock()
+
+	err := l.out.file.Close()
+	l.out.file = nil
+	return err
+}
+
+func (l *Logger) Info(s string, v ...interface{}) {
+	l.log(LLInfo, s, v)
+}
+
+func (l *Logger) Error(s string, v ...interface{}) {
+	l.log(LLError, s, v)
+}
+
+func (l *Logger) Debug(s string, v ...interface{}) {
+	l.log(LLDebug, s, v)
+}
+
+func (l *Logger) log(level LogLevel, s string, v []interface{}) {
+	if l == nil || l.out == nil || !l.out.enabled(l.subsystem, level) {
+		return
 	}
 
-	if file != "" {
-		l.Info("Logging to file " + file)
+	msg := s
+	if len(v) > 0 {
+		msg = fmt.Sprintf(s, v...)
+	}
+
+	l.out.write(level, l.subsystem, msg, l.fields, time.Now())
+}
+
+func (o *output) enabled(subsystem string, level LogLevel) bool {
+	o.levelLock.RLock()
+	current, ok := o.levels[subsystem]
+	o.levelLock.RUnlock()
+	if !ok {
+		current = o.defaultLevel
+	}
+
+	want, _ := level.rank()
+	have, _ := current.rank()
+	return want >= have
+}
+
+func (o *output) write(level LogLevel, subsystem, msg string, fields []field, t time.Time) {
+	var line []byte
+	if o.format == FormatJSON {
+		line = formatJSON(level, subsystem, msg, fields, t)
 	} else {
-		l.Info("Logging to console only")
+		line = formatText(level, subsystem, msg, fields, t)
 	}
 
-	return l, nil
+	o.writeLock.Lock()
+	defer o.writeLock.Unlock()
+
+	console := o.stdout
+	if level == LLError {
+		console = o.stderr
+	}
+	console.Write(line)
+
+	if o.file != nil {
+		o.file.Write(line)
+	}
+}
+
+// formatText keeps the format of the old log.Logger based lines and adds
+// the subsystem and the fields.
+func formatText(level LogLevel, subsystem, msg string, fields []field, t time.Time) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString(level.prefix())
+	buf.WriteString(t.Format("2006/01/02 15:04:05 "))
+	if subsystem != RootSubsystem {
+		buf.WriteString("[" + subsystem + "] ")
+	}
+	buf.WriteString(msg)
+
+	for _, f := range fields {
+		buf.WriteString(" " + f.key + "=")
+		buf.WriteString(quoteValue(textValue(f.value)))
+	}
+
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func textValue(v interface{}) string {
+	switch val := v.(type) {
+	case error:
+		return val.Error()
+	case fmt.Stringer:
+		return val.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// quoteValue quotes values that would be ambiguous in a key=value list.
+func quoteValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func formatJSON(level LogLevel, subsystem, msg string, fields []field, t time.Time) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString(`{"time":`)
+	writeJSON(buf, t.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(buf, string(level))
+	buf.WriteString(`,"subsystem":`)
+	writeJSON(buf, subsystem)
+	buf.WriteString(`,"msg":`)
+	writeJSON(buf, msg)
+
+	for _, f := range fields {
+		buf.WriteByte(',')
+		writeJSON(buf, f.key)
+		buf.WriteByte(':')
+
+		switch val := f.value.(type) {
+		case error:
+			writeJSON(buf, val.Error())
+		case fmt.Stringer:
+			writeJSON(buf, val.String())
+		default:
+			writeJSON(buf, val)
+		}
+	}
+
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// writeJSON writes v without escaping HTML, log lines aren't embedded in
+// pages.
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	b := &bytes.Buffer{}
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		b.Reset()
+		enc.Encode(fmt.Sprint(v))
+	}
+	buf.Write(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
 }
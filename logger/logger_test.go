@@ -0,0 +1,163 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testLogger(t *testing.T, opts Options) (*Logger, *bytes.Buffer, *bytes.Buffer) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	l, err := newLogger(opts, stdout, stderr, nil)
+	if err != nil {
+		t.Fatalf("newLogger() returned an error: %v", err)
+	}
+	return l, stdout, stderr
+}
+
+func TestFormatText(t *testing.T) {
+	ts := time.Date(2020, 10, 19, 8, 9, 43, 0, time.Local)
+	fields := []field{{"id", "abc"}, {"path", "/a b"}, {"took", 10 * time.Millisecond}, {"err", errors.New("boom")}}
+
+	line := string(formatText(LLInfo, "access", "request", fields, ts))
+	expected := `[INFO] 2020/10/19 08:09:43 [access] request id=abc path="/a b" took=10ms err=boom` + "\n"
+	if line != expected {
+		t.Errorf("formatText() returned %q, expected %q", line, expected)
+	}
+
+	// The root logger looks like it did before subsystems existed.
+	line = string(formatText(LLError, RootSubsystem, "Oops", nil, ts))
+	if line != "[ERROR] 2020/10/19 08:09:43 Oops\n" {
+		t.Errorf("formatText() returned %q", line)
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	ts := time.Date(2020, 10, 19, 8, 9, 43, 0, time.UTC)
+	fields := []field{{"status", 404}, {"took", 10 * time.Millisecond}, {"err", errors.New("boom")}}
+
+	line := formatJSON(LLDebug, "oauth", "say \"hi\" -> <b>", fields, ts)
+	if !bytes.HasSuffix(line, []byte("}\n")) || bytes.Contains(line, []byte(`\u003e`)) {
+		t.Fatalf("formatJSON() returned %q", line)
+	}
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(line, &entry); err != nil {
+		t.Fatalf("formatJSON() returned invalid JSON %q: %v", line, err)
+	}
+
+	expected := map[string]interface{}{
+		"time":      "2020-10-19T08:09:43Z",
+		"level":     "debug",
+		"subsystem": "oauth",
+		"msg":       "say \"hi\" -> <b>",
+		"status":    float64(404),
+		"took":      "10ms",
+		"err":       "boom",
+	}
+	if !reflect.DeepEqual(entry, expected) {
+		t.Errorf("formatJSON() returned %v, expected %v", entry, expected)
+	}
+}
+
+func TestLevels(t *testing.T) {
+	l, stdout, stderr := testLogger(t, Options{
+		Level:  LLInfo,
+		Levels: map[string]LogLevel{"database": LLError},
+	})
+	db := l.Sub("database")
+	oauth := l.Sub("oauth")
+
+	l.Debug("root debug")
+	l.Info("root info")
+	db.Info("database info")
+	db.Error("database error")
+	oauth.Info("oauth %s", "info")
+
+	out := stdout.String()
+	if strings.Contains(out, "root debug") || strings.Contains(out, "database info") {
+		t.Errorf("Entries below the level were written: %q", out)
+	}
+	if !strings.Contains(out, "root info") || !strings.Contains(out, "[oauth] oauth info") {
+		t.Errorf("Missing entries: %q", out)
+	}
+	if !strings.Contains(stderr.String(), "[database] database error") {
+		t.Errorf("Errors should go to stderr, got %q", stderr.String())
+	}
+
+	if err := l.SetLevel("database", LLDebug); err != nil {
+		t.Fatalf("SetLevel() returned an error: %v", err)
+	}
+	db.Debug("database debug")
+	if !strings.Contains(stdout.String(), "database debug") {
+		t.Errorf("SetLevel() didn't change the level")
+	}
+
+	if err := l.SetLevel("nope", LLDebug); err == nil {
+		t.Errorf("SetLevel() accepted an unknown subsystem")
+	}
+	if err := l.SetLevel("oauth", "loud"); err == nil {
+		t.Errorf("SetLevel() accepted an unknown level")
+	}
+
+	expected := map[string]LogLevel{RootSubsystem: LLInfo, "database": LLDebug, "oauth": LLInfo}
+	if levels := l.Levels(); !reflect.DeepEqual(levels, expected) {
+		t.Errorf("Levels() returned %v, expected %v", levels, expected)
+	}
+}
+
+func TestWith(t *testing.T) {
+	l, stdout, _ := testLogger(t, Options{Level: LLDebug})
+
+	vote := l.With("user", 2)
+	vote.With("movie", 5).Info("Vote added")
+	vote.Info("Vote removed")
+	l.With("odd").Info("Odd")
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %q", lines)
+	}
+	if !strings.HasSuffix(lines[0], "Vote added user=2 movie=5") {
+		t.Errorf("Unexpected line %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "Vote removed user=2") {
+		t.Errorf("With() changed the parent logger: %q", lines[1])
+	}
+	if !strings.HasSuffix(lines[2], "Odd odd=!MISSING") {
+		t.Errorf("Unexpected line %q", lines[2])
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	levels, err := ParseLevels(" database=error, oauth=DEBUG,")
+	if err != nil {
+		t.Fatalf("ParseLevels() returned an error: %v", err)
+	}
+
+	expected := map[string]LogLevel{"database": LLError, "oauth": LLDebug}
+	if !reflect.DeepEqual(levels, expected) {
+		t.Errorf("ParseLevels() returned %v, expected %v", levels, expected)
+	}
+
+	for _, list := range []string{"database", "=error", "database=loud"} {
+		if _, err := ParseLevels(list); err == nil {
+			t.Errorf("ParseLevels(%q) didn't return an error", list)
+		}
+	}
+}
+
+func TestZeroLogger(t *testing.T) {
+	// Used by tests that don't care about the log.
+	l := &Logger{}
+	l.Info("nothing")
+	l.Sub("database").With("a", 1).Error("nothing")
+	if err := l.SetLevel("database", LLDebug); err != nil {
+		t.Errorf("SetLevel() returned an error: %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timestamp added to the name of rotated files.  It sorts by age.
+const rotateTimeFormat string = "20060102-150405.000"
+
+type RotateOptions struct {
+	MaxSize    int64         // rotate when the file would grow past this many bytes, 0 disables
+	Interval   time.Duration // rotate when a new interval starts (in UTC), eg 24h for daily, 0 disables
+	MaxBackups int           // number of rotated files to keep, 0 keeps all of them
+	Compress   bool          // gzip the rotated files
+}
+
+// rotatingFile is a log file that is renamed to server-<time>.log and
+// replaced by an empty file once it gets too big or too old.
+type rotatingFile struct {
+	lock sync.Mutex
+
+	name   string
+	opts   RotateOptions
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	now func() time.Time // replaced in tests
+}
+
+func openRotatingFile(name string, opts RotateOptions) (*rotatingFile, error) {
+	f := &rotatingFile{
+		name: name,
+		opts: opts,
+		now:  time.Now,
+	}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.opened = f.now()
+
+	// A file left over from the last run is as old as its last entry.
+	if f.size > 0 {
+		f.opened = info.ModTime()
+	}
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.shouldRotate(len(p)) {
+		if err := f.rotate(); err != nil {
+			// Keep the entry instead of losing it with the file.
+			fmt.Fprintf(os.Stderr, "%sUnable to rotate log file: %v\n", logPrefixError, err)
+			if f.file == nil {
+				return 0, err
+			}
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) shouldRotate(next int) bool {
+	if f.size == 0 {
+		return false
+	}
+
+	if f.opts.MaxSize > 0 && f.size+int64(next) > f.opts.MaxSize {
+		return true
+	}
+
+	if f.opts.Interval > 0 {
+		now := f.now().UTC().Truncate(f.opts.Interval)
+		opened := f.opened.UTC().Truncate(f.opts.Interval)
+		if now.After(opened) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	backup := f.backupName(f.now())
+	renameErr := os.Rename(f.name, backup)
+
+	// Reopen even if the rename failed, so logging goes on.
+	if err := f.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+
+	if f.opts.Compress {
+		if err := compressFile(backup); err != nil {
+			return err
+		}
+	}
+
+	return f.removeOldBackups()
+}
+
+// backupName returns an unused name for the rotated file, eg
+// logs/server-20201019-080943.000.log.
+func (f *rotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.name)
+	base := strings.TrimSuffix(f.name, ext) + "-" + t.Format(rotateTimeFormat)
+
+	name := base + ext
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+	return name
+}
+
+// backups returns the rotated files, oldest first.
+func (f *rotatingFile) backups() ([]string, error) {
+	ext := filepath.Ext(f.name)
+	prefix := filepath.Base(strings.TrimSuffix(f.name, ext)) + "-"
+
+	entries, err := ioutil.ReadDir(filepath.Dir(f.name))
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if strings.HasSuffix(name, ext) || strings.HasSuffix(name, ext+".gz") {
+			names = append(names, filepath.Join(filepath.Dir(f.name), name))
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (f *rotatingFile) removeOldBackups() error {
+	if f.opts.MaxBackups <= 0 {
+		return nil
+	}
+
+	names, err := f.backups()
+	if err != nil {
+		return err
+	}
+
+	for len(names) > f.opts.MaxBackups {
+		if err := os.Remove(names[0]); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+func (f *rotatingFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// compressFile replaces name with name.gz.
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+
+	src.Close()
+	return os.Remove(name)
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
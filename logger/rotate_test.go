@@ -0,0 +1,132 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRotatingFile(t *testing.T, opts RotateOptions) (*rotatingFile, string, *time.Time) {
+	dir, err := ioutil.TempDir("", "mp-logs")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+
+	now := time.Date(2020, 10, 19, 8, 0, 0, 0, time.UTC)
+	f := &rotatingFile{
+		name: filepath.Join(dir, "server.log"),
+		opts: opts,
+		now:  func() time.Time { return now },
+	}
+
+	if err := f.open(); err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Unable to open log file: %v", err)
+	}
+	return f, dir, &now
+}
+
+func readLog(t *testing.T, name string) string {
+	t.Helper()
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("Unable to open %s: %v", name, err)
+	}
+	defer file.Close()
+
+	var content []byte
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("Unable to read %s: %v", name, err)
+		}
+		content, err = ioutil.ReadAll(gz)
+	} else {
+		content, err = ioutil.ReadAll(file)
+	}
+	if err != nil {
+		t.Fatalf("Unable to read %s: %v", name, err)
+	}
+	return string(content)
+}
+
+func TestRotateSize(t *testing.T) {
+	f, dir, now := testRotatingFile(t, RotateOptions{MaxSize: 10, MaxBackups: 2})
+	defer os.RemoveAll(dir)
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() returned an error: %v", err)
+		}
+		*now = now.Add(time.Second)
+	}
+
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatalf("backups() returned an error: %v", err)
+	}
+
+	// "first" was rotated out and removed with the third backup.
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %v", backups)
+	}
+	if content := readLog(t, backups[0]); content != "second\n" {
+		t.Errorf("Unexpected content of the older backup: %q", content)
+	}
+	if content := readLog(t, backups[1]); content != "third\n" {
+		t.Errorf("Unexpected content of the newer backup: %q", content)
+	}
+	if content := readLog(t, f.name); content != "fourth\n" {
+		t.Errorf("Unexpected content of the log: %q", content)
+	}
+}
+
+func TestRotateInterval(t *testing.T) {
+	f, dir, now := testRotatingFile(t, RotateOptions{Interval: 24 * time.Hour, Compress: true})
+	defer os.RemoveAll(dir)
+	defer f.Close()
+
+	f.Write([]byte("monday\n"))
+	*now = now.Add(10 * time.Hour) // still the same day
+	f.Write([]byte("monday night\n"))
+	*now = now.Add(10 * time.Hour)
+	f.Write([]byte("tuesday\n"))
+
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatalf("backups() returned an error: %v", err)
+	}
+
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], "server-20201020-040000.000.log.gz") {
+		t.Fatalf("Expected a single compressed backup, got %v", backups)
+	}
+	if content := readLog(t, backups[0]); content != "monday\nmonday night\n" {
+		t.Errorf("Unexpected content of the backup: %q", content)
+	}
+	if content := readLog(t, f.name); content != "tuesday\n" {
+		t.Errorf("Unexpected content of the log: %q", content)
+	}
+}
+
+func TestBackupName(t *testing.T) {
+	f, dir, now := testRotatingFile(t, RotateOptions{})
+	defer os.RemoveAll(dir)
+	defer f.Close()
+
+	name := f.backupName(*now)
+	if filepath.Base(name) != "server-20201019-080000.000.log" {
+		t.Fatalf("Unexpected backup name %q", name)
+	}
+
+	if err := ioutil.WriteFile(name+".gz", nil, 0644); err != nil {
+		t.Fatalf("Unable to write file: %v", err)
+	}
+	if next := f.backupName(*now); filepath.Base(next) != "server-20201019-080000.000-1.log" {
+		t.Errorf("backupName() didn't avoid the existing file: %q", next)
+	}
+}
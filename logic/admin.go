@@ -1,40 +1,125 @@
 package logic
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/zorchenhimer/MoviePolls/database"
 	"github.com/zorchenhimer/MoviePolls/models"
 )
 
 // Purge removes the account entirely, including all of the account's votes.
 // Should this add the user to the banlist?  Maybe add an option?
-func (b *backend) AdminPurgeUser(user *models.User) error {
+func (b *backend) AdminPurgeUser(admin *models.User, user *models.User) error {
 	b.l.Info("Purging user %s", user)
 	err := b.data.PurgeUser(user.Id)
 	if err != nil {
 		return err
 	}
+	b.audit(admin, models.AUDIT_USER_PURGE, "User", user.Id, user.Name, user.String(), "")
 	return nil
 }
 
-// Ban deletes a user and adds them to a ban list.  Users on this list can view
-// the site but cannot create an account.
-func (b *backend) AdminBanUser(user *models.User) error {
-	return fmt.Errorf("not implemented")
+// Ban adds a user to the ban list.  Users on this list can view the site but
+// cannot vote, add movies or create a new account.  The ban covers the user's
+// ID, email and all of their current OAuth logins.  A nil expires makes the
+// ban permanent.
+func (b *backend) AdminBanUser(admin *models.User, user *models.User, reason string, expires *time.Time) error {
+	if user.IsAdmin() {
+		return fmt.Errorf("Admins cannot be banned")
+	}
+
+	if expires != nil && expires.Before(time.Now()) {
+		return fmt.Errorf("Expiry date must be in the future")
+	}
+
+	if ban, _ := b.GetUserBan(user); ban != nil {
+		return fmt.Errorf("User %s is already banned", user.Name)
+	}
+
+	ban := &models.Ban{
+		UserId:  user.Id,
+		Email:   strings.ToLower(strings.TrimSpace(user.Email)),
+		ExtIds:  []models.BanExtId{},
+		Reason:  reason,
+		Created: time.Now(),
+		Expires: expires,
+	}
+
+	if admin != nil {
+		ban.BannedBy = admin.Id
+	}
+
+	for _, auth := range user.AuthMethods {
+		if auth.Type == models.AUTH_LOCAL || auth.ExtId == "" {
+			continue
+		}
+		ban.ExtIds = append(ban.ExtIds, models.BanExtId{Type: auth.Type, ExtId: auth.ExtId})
+	}
+
+	b.l.Info("Banning user %s", user)
+	_, err := b.data.AddBan(ban)
+	if err != nil {
+		return err
+	}
+
+	b.audit(admin, models.AUDIT_USER_BAN, "User", user.Id, user.Name, "", ban.Summary())
+
+	// Log the user out everywhere, so the ban is noticed right away.  The
+	// ban itself is in place even if this fails.
+	if err := b.data.DeleteUserSessions(user.Id); err != nil {
+		b.l.Error("Unable to revoke the sessions of banned user %s: %v", user, err)
+	}
+	return nil
 }
 
-func (s *backend) CheckAdminRights(user *models.User) bool {
-	ok := true
-	if user == nil || user.Privilege < models.PRIV_MOD {
-		ok = false
+// Unban removes every ban that matches the user, expired or not.
+func (b *backend) AdminUnbanUser(admin *models.User, user *models.User) error {
+	bans, err := b.data.GetBans()
+	if err != nil {
+		return err
+	}
+
+	b.l.Info("Unbanning user %s", user)
+	for _, ban := range bans {
+		if ban.UserId != user.Id {
+			continue
+		}
+		if err := b.data.DeleteBan(ban.Id); err != nil {
+			return err
+		}
+		b.audit(admin, models.AUDIT_USER_UNBAN, "User", user.Id, user.Name, ban.Summary(), "")
 	}
-	return ok
+	return nil
+}
+
+// Generate a password reset UrlKey for the user.  The caller is responsible
+// for storing it with SetUrlKey.
+func (b *backend) AdminPasswordReset(admin *models.User, user *models.User) (*models.UrlKey, error) {
+	urlKey, err := b.NewPasswordResetKey(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	b.audit(admin, models.AUDIT_USER_PASSWORD, "User", user.Id, user.Name, "", "")
+	return urlKey, nil
+}
+
+// CheckAdminRights returns true if the user may access the admin pages at
+// all.  Individual pages check for their permission with CheckPermission.
+func (s *backend) CheckAdminRights(user *models.User) bool {
+	return user != nil && user.HasAnyPermission()
 }
 
 // "deletes" a user.  The account will still exist along with the votes, but
 // the name, password, email, and notification settings will all be removed.
-func (s *backend) AdminDeleteUser(user *models.User) error {
+func (s *backend) AdminDeleteUser(admin *models.User, user *models.User) error {
 	s.l.Info("Deleting user %s", user)
+	before := user.String()
+	origName := user.Name
 	user.Name = "[deleted]"
 	for _, auth := range user.AuthMethods {
 		s.data.DeleteAuthMethod(auth.Id)
@@ -45,6 +130,72 @@ func (s *backend) AdminDeleteUser(user *models.User) error {
 	user.NotifyVoteSelection = false
 	user.Privilege = 0
 
-	return s.data.UpdateUser(user)
+	if err := s.data.UpdateUser(user); err != nil {
+		return err
+	}
 
+	s.audit(admin, models.AUDIT_USER_DELETE, "User", user.Id, origName, before, user.String())
+	return nil
+}
+
+// Set a config value and record the change in the audit log.  The value must
+// match the type of the ConfigValue.  Private values are not written to the
+// audit log.
+func (b *backend) AdminSetConfigValue(admin *models.User, key string, value interface{}) error {
+	config, ok := ConfigValues[key]
+	if !ok {
+		return fmt.Errorf("Could not find ConfigValue named %s", key)
+	}
+
+	var before, after string
+	var err error
+
+	switch config.Type {
+	case ConfigString, ConfigStringPriv:
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("Value for %s must be a string", key)
+		}
+		before, err = b.data.GetCfgString(key, config.Default.(string))
+		after = str
+		if err == nil || errors.Is(err, database.ErrNoValue) {
+			err = b.data.SetCfgString(key, str)
+		}
+	case ConfigInt:
+		val, ok := value.(int)
+		if !ok {
+			return fmt.Errorf("Value for %s must be an int", key)
+		}
+		var old int
+		old, err = b.data.GetCfgInt(key, config.Default.(int))
+		before, after = strconv.Itoa(old), strconv.Itoa(val)
+		if err == nil || errors.Is(err, database.ErrNoValue) {
+			err = b.data.SetCfgInt(key, val)
+		}
+	case ConfigBool:
+		val, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("Value for %s must be a bool", key)
+		}
+		var old bool
+		old, err = b.data.GetCfgBool(key, config.Default.(bool))
+		before, after = strconv.FormatBool(old), strconv.FormatBool(val)
+		if err == nil || errors.Is(err, database.ErrNoValue) {
+			err = b.data.SetCfgBool(key, val)
+		}
+	default:
+		return fmt.Errorf("Unknown config value type for %s: %v", key, config.Type)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if before != after {
+		if config.Type == ConfigStringPriv {
+			before, after = "[hidden]", "[hidden]"
+		}
+		b.audit(admin, models.AUDIT_CONFIG_CHANGE, "Config", 0, key, before, after)
+	}
+	return nil
 }
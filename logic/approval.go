@@ -0,0 +1,69 @@
+package logic
+
+import (
+	"fmt"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+// GetPendingMovies returns the movies waiting in the approval queue, oldest
+// first.
+func (b *backend) GetPendingMovies() ([]*models.Movie, error) {
+	movies, err := b.data.GetActiveMovies()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []*models.Movie{}
+	for _, m := range movies {
+		if m.Pending {
+			pending = append(pending, m)
+		}
+	}
+
+	return models.SortMoviesById(pending), nil
+}
+
+func (b *backend) AdminApproveMovie(admin *models.User, movie *models.Movie) error {
+	if !movie.Pending {
+		return fmt.Errorf("Movie %q is not awaiting approval", movie.Name)
+	}
+
+	movie.Pending = false
+	movie.Approved = true
+
+	b.l.Info("Approving movie %s", movie.Name)
+	if err := b.data.UpdateMovie(movie); err != nil {
+		return err
+	}
+
+	b.audit(admin, models.AUDIT_MOVIE_APPROVE, "Movie", movie.Id, movie.Name, "Pending", "Approved")
+	b.notify(movie.AddedBy,
+		fmt.Sprintf("Your movie %q has been approved.", movie.Name),
+		fmt.Sprintf("/movie/%d", movie.Id))
+	return nil
+}
+
+func (b *backend) AdminDenyMovie(admin *models.User, movie *models.Movie, reason string) error {
+	if !movie.Pending {
+		return fmt.Errorf("Movie %q is not awaiting approval", movie.Name)
+	}
+
+	movie.Pending = false
+	movie.Denied = true
+	movie.DenyReason = reason
+
+	b.l.Info("Denying movie %s", movie.Name)
+	if err := b.data.UpdateMovie(movie); err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("Your movie %q has been denied.", movie.Name)
+	if reason != "" {
+		msg += " Reason: " + reason
+	}
+
+	b.audit(admin, models.AUDIT_MOVIE_DENY, "Movie", movie.Id, movie.Name, "Pending", "Denied: "+reason)
+	b.notify(movie.AddedBy, msg, fmt.Sprintf("/movie/%d", movie.Id))
+	return nil
+}
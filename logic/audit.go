@@ -0,0 +1,47 @@
+package logic
+
+import (
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+// audit writes an entry to the audit log.  A failure to write the entry is
+// logged, but does not fail the action it describes.
+func (b *backend) audit(actor *models.User, action models.AuditAction, targetType string, targetId int, targetName, before, after string) {
+	entry := &models.AuditEntry{
+		Timestamp:  time.Now(),
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId,
+		TargetName: targetName,
+		Before:     before,
+		After:      after,
+	}
+
+	if actor != nil {
+		entry.ActorId = actor.Id
+		entry.ActorName = actor.Name
+	}
+
+	b.l.Info("[audit] %s: %s %s %q -> %q", entry.ActorName, action, entry.Target(), before, after)
+	if err := b.data.AddAuditEntry(entry); err != nil {
+		b.l.Error("Unable to write audit entry: %v", err)
+	}
+}
+
+// GetAuditLog returns the audit entries matching the filter, newest first.
+func (b *backend) GetAuditLog(filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	entries, err := b.data.GetAuditEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	found := []*models.AuditEntry{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if filter.Matches(entries[i]) {
+			found = append(found, entries[i])
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,50 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+func TestAudit(t *testing.T) {
+	b, cleanup := newTestBackend(t)
+	defer cleanup()
+
+	admin := &models.User{Id: 1, Name: "admin"}
+	b.audit(admin, models.AUDIT_MOVIE_REMOVE, "Movie", 5, "Alien", "Active", "Removed")
+	b.audit(nil, models.AUDIT_CLEANUP, "Poster", 0, "", "3", "")
+	b.audit(admin, models.AUDIT_USER_BAN, "User", 2, "bobby", "", "Banned")
+
+	entries, err := b.GetAuditLog(models.AuditFilter{})
+	if err != nil {
+		t.Fatalf("GetAuditLog() returned an error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("GetAuditLog() returned %d entries, expected 3", len(entries))
+	}
+
+	// Newest first.
+	if entries[0].Action != models.AUDIT_USER_BAN || entries[2].Action != models.AUDIT_MOVIE_REMOVE {
+		t.Errorf("Entries are in the wrong order: %s, %s, %s", entries[0].Action, entries[1].Action, entries[2].Action)
+	}
+
+	removed := entries[2]
+	if removed.ActorId != 1 || removed.ActorName != "admin" || removed.TargetType != "Movie" ||
+		removed.TargetId != 5 || removed.TargetName != "Alien" || removed.Before != "Active" ||
+		removed.After != "Removed" || removed.Timestamp.IsZero() {
+		t.Errorf("Unexpected entry %+v", removed)
+	}
+
+	// Jobs run without an actor.
+	if entries[1].ActorId != 0 || entries[1].ActorName != "" {
+		t.Errorf("Unexpected actor %d %q for a job", entries[1].ActorId, entries[1].ActorName)
+	}
+
+	filtered, err := b.GetAuditLog(models.AuditFilter{Actor: "admin", Action: models.AUDIT_USER_BAN})
+	if err != nil {
+		t.Fatalf("GetAuditLog() returned an error: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].TargetName != "bobby" {
+		t.Errorf("GetAuditLog() returned %v for a filter", filtered)
+	}
+}
@@ -0,0 +1,66 @@
+package logic
+
+import (
+	"strings"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+func (b *backend) GetBans() ([]*models.Ban, error) {
+	return b.data.GetBans()
+}
+
+// GetUserBan returns the active ban for the given user, or nil if the user is
+// not banned.
+func (b *backend) GetUserBan(user *models.User) (*models.Ban, error) {
+	if user == nil {
+		return nil, nil
+	}
+
+	return b.findBan(func(ban *models.Ban) bool {
+		if ban.UserId == user.Id {
+			return true
+		}
+		for _, auth := range user.AuthMethods {
+			if ban.MatchesOauth(auth.ExtId, auth.Type) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// GetOauthBan returns the active ban covering the given external OAuth ID,
+// or nil if there is none.
+func (b *backend) GetOauthBan(extId string, authType models.AuthType) (*models.Ban, error) {
+	return b.findBan(func(ban *models.Ban) bool {
+		return ban.MatchesOauth(extId, authType)
+	})
+}
+
+// GetEmailBan returns the active ban covering the given email address, or nil
+// if there is none.
+func (b *backend) GetEmailBan(email string) (*models.Ban, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil, nil
+	}
+
+	return b.findBan(func(ban *models.Ban) bool {
+		return ban.Email == email
+	})
+}
+
+func (b *backend) findBan(match func(*models.Ban) bool) (*models.Ban, error) {
+	bans, err := b.data.GetBans()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ban := range bans {
+		if ban.Active() && match(ban) {
+			return ban, nil
+		}
+	}
+	return nil, nil
+}
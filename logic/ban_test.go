@@ -0,0 +1,95 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+func TestGetUserBan(t *testing.T) {
+	b, cleanup := newTestBackend(t)
+	defer cleanup()
+
+	past := time.Now().Add(-time.Hour)
+	bans := []*models.Ban{
+		{UserId: 1, Reason: "by id"},
+		{UserId: 99, ExtIds: []models.BanExtId{{Type: models.AUTH_DISCORD, ExtId: "1234"}}, Reason: "by oauth"},
+		{UserId: 3, Reason: "expired", Expires: &past},
+	}
+	for _, ban := range bans {
+		if _, err := b.data.AddBan(ban); err != nil {
+			t.Fatalf("AddBan() returned an error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		user   *models.User
+		reason string
+	}{
+		{"banned user", &models.User{Id: 1}, "by id"},
+		{"new account with a banned Discord login", &models.User{Id: 2, AuthMethods: []*models.AuthMethod{
+			{Type: models.AUTH_LOCAL},
+			{Type: models.AUTH_DISCORD, ExtId: "1234"},
+		}}, "by oauth"},
+		{"same ID on another site", &models.User{Id: 2, AuthMethods: []*models.AuthMethod{
+			{Type: models.AUTH_TWITCH, ExtId: "1234"},
+		}}, ""},
+		{"expired ban", &models.User{Id: 3}, ""},
+		{"not banned", &models.User{Id: 4}, ""},
+		{"nobody", nil, ""},
+	}
+
+	for _, tt := range tests {
+		ban, err := b.GetUserBan(tt.user)
+		if err != nil {
+			t.Errorf("%s: GetUserBan() returned an error: %v", tt.name, err)
+			continue
+		}
+
+		reason := ""
+		if ban != nil {
+			reason = ban.Reason
+		}
+		if reason != tt.reason {
+			t.Errorf("%s: GetUserBan() returned the ban %q, expected %q", tt.name, reason, tt.reason)
+		}
+	}
+}
+
+func TestAdminBanUserRevokesSessions(t *testing.T) {
+	b, cleanup := newTestBackend(t)
+	defer cleanup()
+
+	user := &models.User{Name: "bobby", Email: "Bobby@example.com"}
+	id, err := b.data.AddUser(user)
+	if err != nil {
+		t.Fatalf("AddUser() returned an error: %v", err)
+	}
+	user.Id = id
+
+	for _, hash := range []string{"phone", "desktop"} {
+		if _, err := b.data.AddSession(&models.Session{UserId: user.Id, TokenHash: hash}); err != nil {
+			t.Fatalf("AddSession() returned an error: %v", err)
+		}
+	}
+
+	if err := b.AdminBanUser(&models.User{Id: 100, Name: "admin"}, user, "spam", nil); err != nil {
+		t.Fatalf("AdminBanUser() returned an error: %v", err)
+	}
+
+	sessions, err := b.data.GetUserSessions(user.Id)
+	if err != nil || len(sessions) != 0 {
+		t.Errorf("Banned user still has sessions %v, %v", sessions, err)
+	}
+
+	ban, err := b.GetEmailBan(" bobby@EXAMPLE.com")
+	if err != nil || ban == nil || ban.BannedBy != 100 {
+		t.Errorf("GetEmailBan() returned %v, %v", ban, err)
+	}
+
+	if err := b.AdminBanUser(nil, user, "again", nil); err == nil {
+		t.Errorf("AdminBanUser() banned a user twice")
+	}
+}
@@ -0,0 +1,257 @@
+package logic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+	"github.com/zorchenhimer/MoviePolls/storage"
+)
+
+// Posters, links and tags younger than this are never deleted.  They are
+// saved before the movie that uses them, so a new one is unused for a moment.
+const cleanupMinAge = time.Hour
+
+// recentItems remembers when posters, links and tags were last handed out
+// for a movie that is about to be saved.  Links and tags have no date in the
+// database, and existing ones are reused for new movies, so their age alone
+// doesn't tell if a movie is about to use them.
+type recentItems struct {
+	lock    sync.Mutex
+	posters map[string]time.Time
+	links   map[int]time.Time
+	tags    map[int]time.Time
+}
+
+func newRecentItems() *recentItems {
+	return &recentItems{
+		posters: map[string]time.Time{},
+		links:   map[int]time.Time{},
+		tags:    map[int]time.Time{},
+	}
+}
+
+func (r *recentItems) usePoster(key string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.posters[key] = time.Now()
+}
+
+func (r *recentItems) useLink(id int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.links[id] = time.Now()
+}
+
+func (r *recentItems) useTag(id int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.tags[id] = time.Now()
+}
+
+// keep returns the items used less than cleanupMinAge ago and forgets the
+// older ones.
+func (r *recentItems) keep(now time.Time) (map[string]bool, map[int]bool, map[int]bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	posters := map[string]bool{}
+	for key, used := range r.posters {
+		if now.Sub(used) < cleanupMinAge {
+			posters[key] = true
+		} else {
+			delete(r.posters, key)
+		}
+	}
+
+	links := map[int]bool{}
+	for id, used := range r.links {
+		if now.Sub(used) < cleanupMinAge {
+			links[id] = true
+		} else {
+			delete(r.links, id)
+		}
+	}
+
+	tags := map[int]bool{}
+	for id, used := range r.tags {
+		if now.Sub(used) < cleanupMinAge {
+			tags[id] = true
+		} else {
+			delete(r.tags, id)
+		}
+	}
+
+	return posters, links, tags
+}
+
+// A CleanupReport lists the posters, links and tags that are not used by any
+// movie.  For a dry run nothing has been deleted yet.
+type CleanupReport struct {
+	DryRun  bool
+	Posters []string
+	Links   []*models.Link
+	Tags    []*models.Tag
+
+	// Things that could not be deleted.
+	Errors []string
+}
+
+func (r *CleanupReport) Empty() bool {
+	return len(r.Posters) == 0 && len(r.Links) == 0 && len(r.Tags) == 0
+}
+
+// findGarbage returns everything in links, tags and posters that none of the
+// movies refer to.  The default poster and recently used items are always
+// kept.
+func findGarbage(movies []*models.Movie, links []*models.Link, tags []*models.Tag, posters []*storage.PosterInfo, recent *recentItems, now time.Time) *CleanupReport {
+	usedPosters, usedLinks, usedTags := recent.keep(now)
+	usedPosters[defaultPosterKey] = true
+
+	for _, movie := range movies {
+		usedPosters[movie.Poster] = true
+		usedPosters[movie.Thumbnail()] = true
+
+		for _, link := range movie.Links {
+			usedLinks[link.Id] = true
+		}
+
+		for _, tag := range movie.Tags {
+			usedTags[tag.Id] = true
+		}
+	}
+
+	report := &CleanupReport{
+		Posters: []string{},
+		Links:   []*models.Link{},
+		Tags:    []*models.Tag{},
+		Errors:  []string{},
+	}
+
+	for _, poster := range posters {
+		if !usedPosters[poster.Key] && now.Sub(poster.Modified) >= cleanupMinAge {
+			report.Posters = append(report.Posters, poster.Key)
+		}
+	}
+	sort.Strings(report.Posters)
+
+	for _, link := range links {
+		if !usedLinks[link.Id] {
+			report.Links = append(report.Links, link)
+		}
+	}
+
+	for _, tag := range tags {
+		if !usedTags[tag.Id] {
+			report.Tags = append(report.Tags, tag)
+		}
+	}
+
+	return report
+}
+
+// Cleanup finds the posters, links and tags that are no longer used by any
+// movie and deletes them, unless dryRun is set.  A nil admin is the
+// scheduled cleanup.
+func (b *backend) Cleanup(admin *models.User, dryRun bool) (*CleanupReport, error) {
+	movies, err := b.data.GetAllMovies()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get movies: %v", err)
+	}
+
+	links, err := b.data.GetLinks()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get links: %v", err)
+	}
+
+	tags, err := b.data.GetTags()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get tags: %v", err)
+	}
+
+	posters, err := b.posters.List()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list posters: %v", err)
+	}
+
+	// Items handed out after the lists were read are kept by recent, those
+	// handed out before are either in the lists or used by a movie already.
+	report := findGarbage(movies, links, tags, posters, b.recent, time.Now())
+	report.DryRun = dryRun
+
+	if dryRun || report.Empty() {
+		return report, nil
+	}
+
+	deleted := []string{}
+	for _, key := range report.Posters {
+		if err := b.posters.Delete(key); err != nil {
+			b.l.Error("Unable to delete poster %q: %v", key, err)
+			report.Errors = append(report.Errors, fmt.Sprintf("Unable to delete poster %s", key))
+			continue
+		}
+		deleted = append(deleted, "Poster: "+key)
+	}
+
+	linkIds := []int{}
+	for _, link := range report.Links {
+		linkIds = append(linkIds, link.Id)
+	}
+
+	tagIds := []int{}
+	for _, tag := range report.Tags {
+		tagIds = append(tagIds, tag.Id)
+	}
+
+	if err := b.data.DeleteLinksAndTags(linkIds, tagIds); err != nil {
+		b.l.Error("Unable to delete links and tags: %v", err)
+		report.Errors = append(report.Errors, "Unable to delete the links and tags")
+	} else {
+		for _, link := range report.Links {
+			deleted = append(deleted, "Link: "+link.Url)
+		}
+		for _, tag := range report.Tags {
+			deleted = append(deleted, "Tag: "+tag.Name)
+		}
+	}
+
+	b.l.Info("Cleanup deleted %d of %d unused posters, links and tags",
+		len(deleted), len(report.Posters)+len(report.Links)+len(report.Tags))
+	b.audit(admin, models.AUDIT_CLEANUP, "Storage", 0, "", strings.Join(deleted, "\n"), "")
+
+	return report, nil
+}
+
+// cleanupLoop runs Cleanup every CleanupInterval hours.  Like the metadata
+// refresh, the setting is checked every minute.
+func (b *backend) cleanupLoop() {
+	last := time.Now()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+
+		hours, err := b.GetCleanupInterval()
+		if err != nil {
+			b.l.Error("Unable to get config value %s: %v", ConfigCleanupInterval, err)
+			continue
+		}
+
+		if hours <= 0 || time.Since(last) < time.Duration(hours)*time.Hour {
+			continue
+		}
+
+		last = time.Now()
+		if _, err := b.Cleanup(nil, false); err != nil {
+			b.l.Error("Cleanup failed: %v", err)
+		}
+	}
+}
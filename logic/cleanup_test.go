@@ -0,0 +1,95 @@
+package logic
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+	"github.com/zorchenhimer/MoviePolls/storage"
+)
+
+func TestFindGarbage(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-2 * cleanupMinAge)
+
+	imdb := &models.Link{Id: 1, Url: "https://www.imdb.com/title/tt0133093/"}
+	tmdb := &models.Link{Id: 2, Url: "https://www.themoviedb.org/movie/603"}
+	orphanLink := &models.Link{Id: 3, Url: "https://www.imdb.com/title/tt0234215/"}
+
+	scifi := &models.Tag{Id: 1, Name: "Sci-Fi"}
+	orphanTag := &models.Tag{Id: 2, Name: "Western"}
+
+	hashed := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef.jpg"
+
+	movies := []*models.Movie{
+		{Id: 1, Poster: hashed, Links: []*models.Link{imdb}, Tags: []*models.Tag{scifi}},
+		// Removed movies stay in the history and keep their poster and links.
+		{Id: 2, Poster: "imdb-tt0133093.jpg", Links: []*models.Link{tmdb}, Removed: true},
+	}
+
+	posters := []*storage.PosterInfo{
+		{Key: hashed, Modified: old},
+		{Key: models.ThumbnailKey(hashed), Modified: old},
+		{Key: "imdb-tt0133093.jpg", Modified: old},
+		{Key: defaultPosterKey, Modified: old},
+		{Key: "orphan.jpg", Modified: old},
+		{Key: "orphan-thumb.jpg", Modified: old},
+		// Just uploaded, the movie may not be saved yet.
+		{Key: "new.jpg", Modified: now},
+	}
+
+	report := findGarbage(movies,
+		[]*models.Link{imdb, tmdb, orphanLink},
+		[]*models.Tag{scifi, orphanTag},
+		posters, newRecentItems(), now)
+
+	if expected := []string{"orphan-thumb.jpg", "orphan.jpg"}; !reflect.DeepEqual(report.Posters, expected) {
+		t.Errorf("Unused posters: %v, expected %v", report.Posters, expected)
+	}
+
+	if len(report.Links) != 1 || report.Links[0] != orphanLink {
+		t.Errorf("Unused links: %v, expected %v", report.Links, orphanLink)
+	}
+
+	if len(report.Tags) != 1 || report.Tags[0] != orphanTag {
+		t.Errorf("Unused tags: %v, expected %v", report.Tags, orphanTag)
+	}
+
+	if report.Empty() {
+		t.Errorf("Report is empty")
+	}
+
+	if empty := findGarbage(movies, nil, nil, nil, newRecentItems(), now); !empty.Empty() {
+		t.Errorf("Expected an empty report, got %v", empty)
+	}
+}
+
+func TestFindGarbageRecent(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-2 * cleanupMinAge)
+
+	link := &models.Link{Id: 1, Url: "https://www.imdb.com/title/tt0133093/"}
+	tag := &models.Tag{Id: 1, Name: "Sci-Fi"}
+	poster := &storage.PosterInfo{Key: "reused.jpg", Modified: old}
+
+	// Handed out for a movie that isn't saved yet.
+	recent := newRecentItems()
+	recent.useLink(link.Id)
+	recent.useTag(tag.Id)
+	recent.usePoster(poster.Key)
+
+	report := findGarbage(nil, []*models.Link{link}, []*models.Tag{tag}, []*storage.PosterInfo{poster}, recent, now)
+	if !report.Empty() {
+		t.Errorf("Recently used items would be deleted: %v %v %v", report.Posters, report.Links, report.Tags)
+	}
+
+	// Once the movie had plenty of time to be saved, they are garbage.
+	report = findGarbage(nil, []*models.Link{link}, []*models.Tag{tag}, []*storage.PosterInfo{poster}, recent, now.Add(cleanupMinAge+time.Minute))
+	if len(report.Posters) != 1 || len(report.Links) != 1 || len(report.Tags) != 1 {
+		t.Errorf("Unused items were kept: %v %v %v", report.Posters, report.Links, report.Tags)
+	}
+	if len(recent.links) != 0 || len(recent.tags) != 0 || len(recent.posters) != 0 {
+		t.Errorf("Old items were not forgotten")
+	}
+}
@@ -49,8 +49,14 @@ const ConfigJikanBannedTypes string = "JikanBannedTypes"
 const ConfigJikanMaxEpisodes string = "JikanMaxEpisodes"
 const ConfigTmdbEnabled string = "TmdbEnabled"
 const ConfigTmdbToken string = "TmdbToken"
+const ConfigLetterboxdEnabled string = "LetterboxdEnabled"
+const ConfigAnilistEnabled string = "AnilistEnabled"
+const ConfigKitsuEnabled string = "KitsuEnabled"
+const ConfigTvdbEnabled string = "TvdbEnabled"
+const ConfigTvdbToken string = "TvdbToken"
 const ConfigMaxMultEpLength string = "MaxMultEpLength"
 const ConfigMaxPosterSize string = "MaxPosterSize"
+const ConfigMetadataRefreshInterval string = "MetadataRefreshInterval"
 
 const Authentication string = "Authentication Settings"
 const ConfigLocalSignupEnabled string = "LocalSignupEnabled"
@@ -62,6 +68,9 @@ const ConfigDiscordOauthEnabled string = "DiscordOauthEnabled"
 const ConfigDiscordOauthSignupEnabled string = "DiscordOauthSignupEnabled"
 const ConfigDiscordOauthClientID string = "DiscordOauthClientID"
 const ConfigDiscordOauthClientSecret string = "DiscordOauthClientSecret"
+const ConfigDiscordGuildID string = "DiscordGuildID"
+const ConfigDiscordRequiredRoles string = "DiscordRequiredRoles"
+const ConfigDiscordModRoles string = "DiscordModRoles"
 const ConfigPatreonOauthEnabled string = "PatreonOauthEnabled"
 const ConfigPatreonOauthSignupEnabled string = "PatreonOauthSignupEnabled"
 const ConfigPatreonOauthClientID string = "PatreonOauthClientID"
@@ -72,6 +81,8 @@ const ConfigMaxUserVotes string = "MaxUserVotes"
 const ConfigVotingEnabled string = "VotingEnabled"
 const ConfigEntriesRequireApproval string = "EntriesRequireApproval"
 const ConfigUnlimitedVotes string = "UnlimitedVotes"
+const ConfigCleanupInterval string = "CleanupInterval"
+const ConfigSessionKeyRotation string = "SessionKeyRotation"
 
 func (b *backend) setupConfig() {
 	// General Settings
@@ -96,8 +107,14 @@ func (b *backend) setupConfig() {
 	ConfigValues[ConfigJikanMaxEpisodes] = ConfigValue{Section: MovieInput, Default: 1, Type: ConfigInt}
 	ConfigValues[ConfigTmdbEnabled] = ConfigValue{Section: MovieInput, Default: false, Type: ConfigBool}
 	ConfigValues[ConfigTmdbToken] = ConfigValue{Section: MovieInput, Default: "", Type: ConfigStringPriv}
+	ConfigValues[ConfigLetterboxdEnabled] = ConfigValue{Section: MovieInput, Default: false, Type: ConfigBool}
+	ConfigValues[ConfigAnilistEnabled] = ConfigValue{Section: MovieInput, Default: false, Type: ConfigBool}
+	ConfigValues[ConfigKitsuEnabled] = ConfigValue{Section: MovieInput, Default: false, Type: ConfigBool}
+	ConfigValues[ConfigTvdbEnabled] = ConfigValue{Section: MovieInput, Default: false, Type: ConfigBool}
+	ConfigValues[ConfigTvdbToken] = ConfigValue{Section: MovieInput, Default: "", Type: ConfigStringPriv}
 	ConfigValues[ConfigMaxMultEpLength] = ConfigValue{Section: MovieInput, Default: 120, Type: ConfigInt}
 	ConfigValues[ConfigMaxPosterSize] = ConfigValue{Section: MovieInput, Default: 50000, Type: ConfigInt}
+	ConfigValues[ConfigMetadataRefreshInterval] = ConfigValue{Section: MovieInput, Default: 0, Type: ConfigInt}
 
 	// Authentication
 	ConfigSections = append(ConfigSections, Authentication)
@@ -110,6 +127,9 @@ func (b *backend) setupConfig() {
 	ConfigValues[ConfigDiscordOauthSignupEnabled] = ConfigValue{Section: Authentication, Default: false, Type: ConfigBool}
 	ConfigValues[ConfigDiscordOauthClientID] = ConfigValue{Section: Authentication, Default: "", Type: ConfigStringPriv}
 	ConfigValues[ConfigDiscordOauthClientSecret] = ConfigValue{Section: Authentication, Default: "", Type: ConfigStringPriv}
+	ConfigValues[ConfigDiscordGuildID] = ConfigValue{Section: Authentication, Default: "", Type: ConfigString}
+	ConfigValues[ConfigDiscordRequiredRoles] = ConfigValue{Section: Authentication, Default: "", Type: ConfigString}
+	ConfigValues[ConfigDiscordModRoles] = ConfigValue{Section: Authentication, Default: "", Type: ConfigString}
 	ConfigValues[ConfigPatreonOauthEnabled] = ConfigValue{Section: Authentication, Default: false, Type: ConfigBool}
 	ConfigValues[ConfigPatreonOauthSignupEnabled] = ConfigValue{Section: Authentication, Default: false, Type: ConfigBool}
 	ConfigValues[ConfigPatreonOauthClientID] = ConfigValue{Section: Authentication, Default: "", Type: ConfigStringPriv}
@@ -121,6 +141,8 @@ func (b *backend) setupConfig() {
 	ConfigValues[ConfigVotingEnabled] = ConfigValue{Section: Administration, Default: false, Type: ConfigBool}
 	ConfigValues[ConfigEntriesRequireApproval] = ConfigValue{Section: Administration, Default: false, Type: ConfigBool}
 	ConfigValues[ConfigUnlimitedVotes] = ConfigValue{Section: Administration, Default: false, Type: ConfigBool}
+	ConfigValues[ConfigCleanupInterval] = ConfigValue{Section: Administration, Default: 24, Type: ConfigInt}
+	ConfigValues[ConfigSessionKeyRotation] = ConfigValue{Section: Administration, Default: 0, Type: ConfigInt}
 }
 
 func (b *backend) LoadDefaultsIfNotSet() error {
@@ -221,6 +243,96 @@ func (b *backend) GetTmdbToken() (string, error) {
 	return val, err
 }
 
+func (b *backend) GetLetterboxdEnabled() (bool, error) {
+	key := ConfigLetterboxdEnabled
+	config, ok := ConfigValues[key]
+	if !ok {
+		return false, fmt.Errorf("Could not find ConfigValue named %s", key)
+	}
+	val, err := b.data.GetCfgBool(key, config.Default.(bool))
+	if errors.Is(err, database.ErrNoValue) {
+		err = b.data.SetCfgBool(key, config.Default.(bool))
+		if err != nil {
+			b.l.Error("Unable to set default value for %s: %v", key, err)
+		}
+		return val, nil
+	}
+
+	return val, err
+}
+
+func (b *backend) GetAnilistEnabled() (bool, error) {
+	key := ConfigAnilistEnabled
+	config, ok := ConfigValues[key]
+	if !ok {
+		return false, fmt.Errorf("Could not find ConfigValue named %s", key)
+	}
+	val, err := b.data.GetCfgBool(key, config.Default.(bool))
+	if errors.Is(err, database.ErrNoValue) {
+		err = b.data.SetCfgBool(key, config.Default.(bool))
+		if err != nil {
+			b.l.Error("Unable to set default value for %s: %v", key, err)
+		}
+		return val, nil
+	}
+
+	return val, err
+}
+
+func (b *backend) GetKitsuEnabled() (bool, error) {
+	key := ConfigKitsuEnabled
+	config, ok := ConfigValues[key]
+	if !ok {
+		return false, fmt.Errorf("Could not find ConfigValue named %s", key)
+	}
+	val, err := b.data.GetCfgBool(key, config.Default.(bool))
+	if errors.Is(err, database.ErrNoValue) {
+		err = b.data.SetCfgBool(key, config.Default.(bool))
+		if err != nil {
+			b.l.Error("Unable to set default value for %s: %v", key, err)
+		}
+		return val, nil
+	}
+
+	return val, err
+}
+
+func (b *backend) GetTvdbEnabled() (bool, error) {
+	key := ConfigTvdbEnabled
+	config, ok := ConfigValues[key]
+	if !ok {
+		return false, fmt.Errorf("Could not find ConfigValue named %s", key)
+	}
+	val, err := b.data.GetCfgBool(key, config.Default.(bool))
+	if errors.Is(err, database.ErrNoValue) {
+		err = b.data.SetCfgBool(key, config.Default.(bool))
+		if err != nil {
+			b.l.Error("Unable to set default value for %s: %v", key, err)
+		}
+		return val, nil
+	}
+
+	return val, err
+}
+
+func (b *backend) GetTvdbToken() (string, error) {
+	key := ConfigTvdbToken
+	config, ok := ConfigValues[key]
+	if !ok {
+		return "", fmt.Errorf("Could not find ConfigValue named %s", key)
+	}
+	val, err := b.data.GetCfgString(key, config.Default.(string))
+	if errors.Is(err, database.ErrNoValue) {
+		err = b.data.SetCfgString(key, config.Default.(string))
+		if err != nil {
+			b.l.Error("Unable to set default value for %s: %v", key, err)
+		}
+		return val, nil
+	}
+
+	return val, err
+}
+
 func (b *backend) GetFormFillEnabled() (bool, error) {
 	key := ConfigFormfillEnabled
 	config, ok := ConfigValues[key]
@@ -329,6 +441,26 @@ func (b *backend) GetMaxUploadlimit() (int, error) {
 	return val, err
 }
 
+// GetMetadataRefreshInterval returns the number of hours between automatic
+// metadata refreshes.  Zero disables the refresh job.
+func (b *backend) GetMetadataRefreshInterval() (int, error) {
+	key := ConfigMetadataRefreshInterval
+	config, ok := ConfigValues[key]
+	if !ok {
+		return 0, fmt.Errorf("Could not find ConfigValue named %s", key)
+	}
+	val, err := b.data.GetCfgInt(key, config.Default.(int))
+	if errors.Is(err, database.ErrNoValue) {
+		err = b.data.SetCfgInt(key, config.Default.(int))
+		if err != nil {
+			b.l.Error("Unable to set default value for %s: %v", key, err)
+		}
+		return val, nil
+	}
+
+	return val, err
+}
+
 func (b *backend) GetMaxLinkLength() (int, error) {
 	key := ConfigMaxLinkLength
 	config, ok := ConfigValues[key]
@@ -421,7 +553,24 @@ func (b *backend) GetMaxDescriptionLength() (int, error) {
 }
 
 func (b *backend) AddMovieToDB(movie *models.Movie) (int, error) {
-	return b.data.AddMovie(movie)
+	approval, err := b.GetEntriesRequireApproval()
+	if err != nil {
+		b.l.Error("Unable to get EntriesRequireApproval: %v", err)
+	}
+
+	// Movies added by users that can approve them skip the queue.
+	movie.Pending = approval && !b.CheckPermission(movie.AddedBy, models.PERM_APPROVE_MOVIES)
+	movie.Approved = !movie.Pending
+
+	id, err := b.data.AddMovie(movie)
+	if err != nil {
+		return id, err
+	}
+
+	movie.Id = id
+	b.search.add(movie)
+	metricMoviesAdded.Inc()
+	return id, nil
 }
 
 // Oauth
@@ -641,6 +790,75 @@ func (b *backend) GetDiscordOauthClientSecret() (string, error) {
 	return val, err
 }
 
+func (b *backend) GetDiscordGuildID() (string, error) {
+	key := ConfigDiscordGuildID
+	config, ok := ConfigValues[key]
+	if !ok {
+		return "", fmt.Errorf("Could not find ConfigValue named %s", key)
+	}
+	val, err := b.data.GetCfgString(key, config.Default.(string))
+	if errors.Is(err, database.ErrNoValue) {
+		err = b.data.SetCfgString(key, config.Default.(string))
+		if err != nil {
+			b.l.Error("Unable to set default value for %s: %v", key, err)
+		}
+		return strings.TrimSpace(val), nil
+	}
+
+	return strings.TrimSpace(val), err
+}
+
+// Role IDs of which a Discord user needs to hold at least one to be allowed
+// to sign up or log in.  An empty list allows any member of the guild.
+func (b *backend) GetDiscordRequiredRoles() ([]string, error) {
+	key := ConfigDiscordRequiredRoles
+	config, ok := ConfigValues[key]
+	if !ok {
+		return nil, fmt.Errorf("Could not find ConfigValue named %s", key)
+	}
+	val, err := b.data.GetCfgString(key, config.Default.(string))
+	if errors.Is(err, database.ErrNoValue) {
+		err = b.data.SetCfgString(key, config.Default.(string))
+		if err != nil {
+			b.l.Error("Unable to set default value for %s: %v", key, err)
+		}
+		return splitList(val), nil
+	}
+
+	return splitList(val), err
+}
+
+// Role IDs that automatically grant PRIV_MOD to a Discord user on login.
+func (b *backend) GetDiscordModRoles() ([]string, error) {
+	key := ConfigDiscordModRoles
+	config, ok := ConfigValues[key]
+	if !ok {
+		return nil, fmt.Errorf("Could not find ConfigValue named %s", key)
+	}
+	val, err := b.data.GetCfgString(key, config.Default.(string))
+	if errors.Is(err, database.ErrNoValue) {
+		err = b.data.SetCfgString(key, config.Default.(string))
+		if err != nil {
+			b.l.Error("Unable to set default value for %s: %v", key, err)
+		}
+		return splitList(val), nil
+	}
+
+	return splitList(val), err
+}
+
+// splitList splits a comma separated config value, dropping empty entries.
+func splitList(val string) []string {
+	list := []string{}
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func (b *backend) GetPatreonOauthClientID() (string, error) {
 	key := ConfigPatreonOauthClientID
 	config, ok := ConfigValues[key]
@@ -685,7 +903,12 @@ func (b *backend) UpdateUser(user *models.User) error {
 	return b.data.UpdateUser(user)
 }
 
+// CheckOauthUsage returns true if the external ID is already linked to a user
+// or if it is covered by an active ban.
 func (b *backend) CheckOauthUsage(id string, authType models.AuthType) bool {
+	if ban, err := b.GetOauthBan(id, authType); err != nil || ban != nil {
+		return true
+	}
 	return b.data.CheckOauthUsage(id, authType)
 }
 
@@ -769,14 +992,42 @@ func (b *backend) GetEntriesRequireApproval() (bool, error) {
 	return val, err
 }
 
-func (b *backend) GetAutofillEnabled() (bool, error) {
-	jikan, err := b.GetJikanEnabled()
-	if err != nil {
-		return false, err
+// GetCleanupInterval returns the number of hours between automatic cleanups
+// of unused posters, links and tags.  Zero disables the cleanup job.
+func (b *backend) GetCleanupInterval() (int, error) {
+	key := ConfigCleanupInterval
+	config, ok := ConfigValues[key]
+	if !ok {
+		return 0, fmt.Errorf("Could not find ConfigValue named %s", key)
 	}
-	tmdb, err := b.GetTmdbEnabled()
-	if err != nil {
-		return false, err
+	val, err := b.data.GetCfgInt(key, config.Default.(int))
+	if errors.Is(err, database.ErrNoValue) {
+		err = b.data.SetCfgInt(key, config.Default.(int))
+		if err != nil {
+			b.l.Error("Unable to set default value for %s: %v", key, err)
+		}
+		return val, nil
+	}
+
+	return val, err
+}
+
+// GetSessionKeyRotation returns the number of days between automatic session
+// key rotations.  Zero disables them.
+func (b *backend) GetSessionKeyRotation() (int, error) {
+	key := ConfigSessionKeyRotation
+	config, ok := ConfigValues[key]
+	if !ok {
+		return 0, fmt.Errorf("Could not find ConfigValue named %s", key)
 	}
-	return jikan || tmdb, nil
+	val, err := b.data.GetCfgInt(key, config.Default.(int))
+	if errors.Is(err, database.ErrNoValue) {
+		err = b.data.SetCfgInt(key, config.Default.(int))
+		if err != nil {
+			b.l.Error("Unable to set default value for %s: %v", key, err)
+		}
+		return val, nil
+	}
+
+	return val, err
 }
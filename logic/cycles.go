@@ -2,6 +2,7 @@ package logic
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/zorchenhimer/MoviePolls/models"
@@ -38,3 +39,57 @@ func (b *backend) UpdateCycle(cycle *models.Cycle) error {
 func (b *backend) EndCycle(cid int) error {
 	return b.SetCfgString("CycleEnding", fmt.Sprintf("%v", cid))
 }
+
+// Start a new cycle and re-enable voting.
+func (b *backend) AdminStartCycle(admin *models.User, plannedEnd *time.Time) (int, error) {
+	id, err := b.data.AddCycle(plannedEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	b.audit(admin, models.AUDIT_CYCLE_START, "Cycle", id, "", "", formatCycleEnd(plannedEnd))
+	return id, b.EnableVoting()
+}
+
+func (b *backend) AdminSetCyclePlannedEnd(admin *models.User, cycle *models.Cycle, plannedEnd *time.Time) error {
+	before := formatCycleEnd(cycle.PlannedEnd)
+	cycle.PlannedEnd = plannedEnd
+
+	if err := b.data.UpdateCycle(cycle); err != nil {
+		return err
+	}
+
+	b.audit(admin, models.AUDIT_CYCLE_UPDATE, "Cycle", cycle.Id, "", before, formatCycleEnd(plannedEnd))
+	return nil
+}
+
+// End the cycle and mark the given movies as watched in it.
+func (b *backend) AdminEndCycle(admin *models.User, cycle *models.Cycle, watched []*models.Movie, ended time.Time) error {
+	names := []string{}
+	for _, movie := range watched {
+		if movie == nil {
+			continue
+		}
+		movie.CycleWatched = cycle
+		if err := b.data.UpdateMovie(movie); err != nil {
+			b.l.Error("Unable to update movie with ID %d: %v", movie.Id, err)
+			continue
+		}
+		names = append(names, movie.Name)
+	}
+
+	cycle.Ended = &ended
+	if err := b.data.UpdateCycle(cycle); err != nil {
+		return err
+	}
+
+	b.audit(admin, models.AUDIT_CYCLE_END, "Cycle", cycle.Id, "", "", "Watched: "+strings.Join(names, ", "))
+	return nil
+}
+
+func formatCycleEnd(t *time.Time) string {
+	if t == nil {
+		return "no planned end"
+	}
+	return t.Format("2006-01-02")
+}
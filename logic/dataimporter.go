@@ -1,430 +1,302 @@
 package logic
 
 import (
+	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"image/jpeg"
+	"html"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"regexp"
-	"strconv"
 	"strings"
-
-	"github.com/nfnt/resize"
-	"github.com/zorchenhimer/MoviePolls/logger"
+	"time"
 )
 
-const defaultPosterPath = "posters/unknown.jpg"
-
-type dataapi interface {
-	getTitle() (string, error)
-	getDesc() (string, error)
-	getPoster() (string, error) //path to the file  (from root)
-	getDuration() (string, error)
-	getRating() (string, error) //returns the rating as string i.e. 8.69
-	getTags() (string, error)   //returns the tags as comma seperated string
-	requestResults() error
-}
+const (
+	defaultPosterKey  = "unknown.jpg"
+	defaultPosterFile = "posters/unknown.jpg" // bundled with the release
+)
 
-type tmdb struct {
-	l           *logger.Logger
-	id          string
-	token       string
-	resp        *map[string]interface{}
-	uploadlimit int
+// MovieMetadata is everything a MetadataProvider knows about a movie.
+type MovieMetadata struct {
+	Title         string
+	OriginalTitle string // title in the original language, empty if it is the same
+	Description   string
+	PosterUrl     string // remote URL of the poster, empty if there is none
+	Duration      string
+	Rating        float32
+	Tags          []string
 }
 
-type jikan struct {
-	l             *logger.Logger
-	id            string
-	excludedTypes []string
-	resp          *map[string]interface{}
-	maxEpisodes   int
-	maxDuration   int
-	uploadlimit   int
+// A MetadataProvider looks up movie information on an external site.
+type MetadataProvider interface {
+	// Fetch returns the metadata for an ID matched from a link by one of the
+	// provider's patterns.  The returned error is shown to the user.
+	Fetch(id string) (*MovieMetadata, error)
 }
 
-func getMovieData(api dataapi) ([]string, error) {
-	var slice []string
-
-	err := api.requestResults()
-
-	if err != nil {
-		return nil, err
-	}
-
-	val, err := api.getTitle()
-	if err != nil {
-		return nil, err
-	}
-	slice = append(slice, val)
-
-	val, err = api.getDesc()
-	if err != nil {
-		return nil, err
-	}
-	slice = append(slice, val)
-
-	val, err = api.getPoster()
-	if err != nil {
-		return nil, err
-	}
-	slice = append(slice, val)
-
-	val, err = api.getDuration()
-	if err != nil {
-		return nil, err
-	}
-	slice = append(slice, val)
-
-	val, err = api.getRating()
-	if err != nil {
-		return nil, err
-	}
-	slice = append(slice, val)
-
-	val, err = api.getTags()
-	if err != nil {
-		return nil, err
-	}
-	slice = append(slice, val)
-
-	return slice, nil
+// A MetadataSearcher is a MetadataProvider that can also search by title.
+type MetadataSearcher interface {
+	Search(query string) ([]*SearchResult, error)
 }
 
-func (t *tmdb) requestResults() error {
-	url := fmt.Sprintf("https://api.themoviedb.org/3/find/%v?api_key=%v&language=en-US&external_source=imdb_id", t.id, t.token)
-	resp, err := http.Get(url)
-
-	if err != nil || resp.StatusCode != 200 {
-		return fmt.Errorf("Tried to access API - Response Code: %v\nMaybe check your tmdb api token", resp.Status)
-	}
-
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-
-	if err != nil {
-		return err
-	}
-
-	var tmp map[string][]map[string]interface{}
-
-	if err := json.Unmarshal(body, &tmp); err != nil {
-		return errors.New("Error while unmarshalling json response")
-	}
-
-	if len(tmp["movie_results"]) == 0 {
-		return errors.New("JSON Result did not return a movie, make sure the imdb link is for a movie")
-	}
-
-	movieId := tmp["movie_results"][0]["id"]
-
-	url = fmt.Sprintf("https://api.themoviedb.org/3/movie/%v?api_key=%v", movieId, t.token)
-
-	resp, err = http.Get(url)
-	if err != nil || resp.StatusCode != 200 {
-		return fmt.Errorf("Tried to access API - Response Code: %v\nMaybe check your tmdb api token", resp.Status)
-	}
-	defer resp.Body.Close()
-
-	body, err = ioutil.ReadAll(resp.Body)
-
-	if err != nil {
-		return err
-	}
-
-	var dat map[string]interface{}
-
-	if err := json.Unmarshal(body, &dat); err != nil {
-		return errors.New("Error while unmarshalling json response")
-	}
-
-	t.resp = &dat
-	return nil
+// SearchResult is a candidate returned by a title search.
+type SearchResult struct {
+	Provider  string
+	Title     string
+	Year      string
+	PosterUrl string
+	Link      string // a link handled by the provider that returned the result
 }
 
-func (t *tmdb) getTitle() (string, error) {
+// maxSearchResults is the number of results kept from each provider.
+const maxSearchResults = 10
 
-	title := ""
+// providerDef describes a registered MetadataProvider.
+type providerDef struct {
+	Name string
 
-	dat := t.resp
+	// Enabled returns the provider's enable setting.
+	Enabled func(b *backend) (bool, error)
 
-	title = (*dat)["title"].(string)
-	release := (*dat)["release_date"].(string)
+	// URL patterns handled by this provider.  The first submatch of each
+	// pattern must be the ID passed to Fetch().
+	Patterns []*regexp.Regexp
 
-	release = release[0:4]
-
-	title = title + " (" + release + ")"
-
-	return title, nil
+	// New returns a provider configured from the site settings.  An error is
+	// returned if the provider is disabled or not configured correctly.
+	New func(b *backend) (MetadataProvider, error)
 }
 
-func (t *tmdb) getDesc() (string, error) {
-
-	desc := ""
-
-	dat := t.resp
-
-	desc = (*dat)["overview"].(string)
+var metadataProviders = []providerDef{}
 
-	return desc, nil
+// registerProvider adds a metadata provider.  It should only be called from
+// init() functions.
+func registerProvider(def providerDef) {
+	metadataProviders = append(metadataProviders, def)
 }
 
-func (t *tmdb) getPoster() (string, error) {
-
-	external_path := ""
-
-	dat := t.resp
-
-	external_path = (*dat)["poster_path"].(string)
-
-	if external_path == "" {
-		return "unknown.jpg", nil
-	}
-
-	fileurl := "https://image.tmdb.org/t/p/original" + external_path
-
-	path := "posters/" + t.id + ".jpg"
-
-	err := DownloadFile(path, fileurl, t.uploadlimit)
-
-	if err != nil {
-		return "unknown.jpg", fmt.Errorf("Error while downloading file, using unknown.jpg. Error: %v", err.Error())
+// matchProvider returns the first provider with a pattern matching the link,
+// along with the ID found in the link.  Nil is returned if no provider
+// handles the link.
+func matchProvider(link string) (*providerDef, string) {
+	for i, def := range metadataProviders {
+		for _, re := range def.Patterns {
+			match := re.FindStringSubmatch(link)
+			if len(match) >= 2 && match[1] != "" {
+				return &metadataProviders[i], match[1]
+			}
+		}
 	}
-
-	return path, nil
+	return nil, ""
 }
 
-func (t *tmdb) getDuration() (string, error) {
-
-	runtime := 0
-
-	dat := t.resp
-
-	runtime = int((*dat)["runtime"].(float64))
-
-	return fmt.Sprintf("%v hr %v min", runtime/60, runtime%60), nil
+func providerNames() []string {
+	names := []string{}
+	for _, def := range metadataProviders {
+		names = append(names, def.Name)
+	}
+	return names
 }
 
-func (t *tmdb) getRating() (string, error) {
-
-	dat := t.resp
-
-	rating := (*dat)["vote_average"].(float64)
+// GetAutofillEnabled returns true if any metadata provider is enabled.
+func (b *backend) GetAutofillEnabled() (bool, error) {
+	for _, def := range metadataProviders {
+		enabled, err := def.Enabled(b)
+		if err != nil {
+			return false, err
+		}
 
-	return fmt.Sprintf("%v", rating), nil
+		if enabled {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
-func (t *tmdb) getTags() (string, error) {
-
-	dat := t.resp
-
-	tagMaps := (*dat)["genres"].([]interface{})
-
-	tags := []string{}
-	tags = append(tags, "IMDB")
-
-	for _, tag := range tagMaps {
-		tg := tag.(map[string]interface{})
-		tags = append(tags, tg["name"].(string))
+// SearchMetadata searches all the enabled providers that support searching
+// for the given title.  An error is only returned if every search failed.
+func (b *backend) SearchMetadata(query string) ([]*SearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("Search query cannot be empty")
 	}
 
-	// Sadly the Decision was made to pass all results as a slice of strings
-	// Therefore this has to be a string instead of a slice of string, otherwise it cannot be passed
-	// to the caller
-	return strings.Join(tags, ","), nil
-}
+	results := []*SearchResult{}
+	var lastErr error
+	searched := 0
 
-var re_duration = regexp.MustCompile(`([0-9]{1,3}) min`)
+	for _, def := range metadataProviders {
+		enabled, err := def.Enabled(b)
+		if err != nil || !enabled {
+			continue
+		}
 
-func (j *jikan) requestResults() error {
-	resp, err := http.Get("https://api.jikan.moe/v3/anime/" + j.id)
-	if err != nil || resp.StatusCode != 200 {
-		return errors.New("\n\nTried to access API - Response Code: " + resp.Status + "\n Request URL: " + "https://api.jikan.moe/v3/anime/" + j.id + "\n")
-	} else {
-		defer resp.Body.Close()
-		body, err := ioutil.ReadAll(resp.Body)
+		provider, err := def.New(b)
 		if err != nil {
-			return err
+			b.autofillLog.Debug("Skipping %s search: %v", def.Name, err)
+			continue
 		}
 
-		var dat map[string]interface{}
-
-		if err := json.Unmarshal(body, &dat); err != nil {
-			return errors.New("Error while unmarshalling json response")
+		searcher, ok := provider.(MetadataSearcher)
+		if !ok {
+			continue
 		}
 
-		for _, etype := range j.excludedTypes {
-			thisType := dat["type"].(string)
-			if strings.EqualFold(thisType, etype) {
-				return fmt.Errorf("The anime type %s was banned by the sites administrator. Please choose a different type!", thisType)
-			}
+		searched++
+		start := time.Now()
+		found, err := searcher.Search(query)
+		observeAutofill(def.Name, "search", start, err)
+		if err != nil {
+			b.autofillLog.Error("Error searching %s for %q: %v", def.Name, query, err)
+			lastErr = err
+			continue
 		}
 
-		if dat["episodes"] != nil {
-			episodes := dat["episodes"].(float64)
-
-			if int(episodes) > j.maxEpisodes && j.maxEpisodes != 0 {
-				return fmt.Errorf("The anime has too many (%d) episodes. The site administrator only allowed animes up to %d episodes.", int(episodes), j.maxEpisodes)
-			}
-		} else {
-			return fmt.Errorf("The episode count of this anime has not been published yet. Therefore this anime can not be added.")
+		if len(found) > maxSearchResults {
+			found = found[:maxSearchResults]
 		}
 
-		if dat["duration"] != nil && dat["duration"] != "Unknown" && j.maxDuration >= 0 {
-			match := re_duration.FindStringSubmatch(dat["duration"].(string))
-			if len(match) < 2 {
-				j.l.Error("Could not detect episode duration.")
-				return fmt.Errorf("The episode duration of this anime has not been published or has an unexpected format. Therefore this anime can not be added.")
-			}
-			duration, err := strconv.Atoi(match[1])
-
-			if err != nil {
-				j.l.Error("Could not convert duration %v to int", match[1])
-				return fmt.Errorf("The episode duration of this anime has not been published or has an unexpected format. Therefore this anime can not be added.")
-			}
-
-			// this looks stupid but it works lul
-			if (duration * int(dat["episodes"].(float64))) > j.maxDuration {
-				j.l.Error("Duration of the anime %s is too long: %d", dat["title"].(string), (duration * int(dat["episodes"].(float64))))
-				return fmt.Errorf("The duration of this series (episode duration * episodes) is longer than the maximum duration defined by the admin. Therefore this anime can not be added.")
-			}
+		for _, res := range found {
+			res.Provider = def.Name
 		}
-
-		j.resp = &dat
-		return nil
+		results = append(results, found...)
 	}
-}
-
-func (j *jikan) getTitle() (string, error) {
 
-	title := ""
-	dat := j.resp
-
-	if (*dat)["title"] != nil {
-		title = (*dat)["title"].(string)
-	} else {
-		return "", errors.New("No title returned from API")
+	if searched == 0 {
+		return nil, fmt.Errorf("Searching by title is not available, please enter a link instead")
 	}
 
-	if (*dat)["title_english"] != nil && (*dat)["title_english"].(string) != (*dat)["title"].(string) {
-		title += " (" + (*dat)["title_english"].(string) + ")"
+	if len(results) == 0 && lastErr != nil {
+		return nil, fmt.Errorf("Could not complete search, contact your site administrator\n Error: %v", lastErr)
 	}
 
-	return title, nil
+	return results, nil
 }
 
-func (j *jikan) getDesc() (string, error) {
-	dat := j.resp
-
-	if (*dat)["synopsis"] != nil {
-		return (*dat)["synopsis"].(string), nil
+// getJson requests the given URL and decodes the JSON response into v.
+func getJson(client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
 	}
-
-	return "", nil
-
+	return doJson(client, req, v)
 }
 
-func (j *jikan) getPoster() (string, error) {
-
-	fileurl := ""
-	dat := j.resp
-
-	if (*dat)["image_url"] != nil {
-		fileurl = (*dat)["image_url"].(string)
-	} else {
-		return defaultPosterPath, nil
+// postJson sends body as JSON to the given URL and decodes the JSON response
+// into v.
+func postJson(client *http.Client, url string, body interface{}, v interface{}) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
 	}
 
-	path := "posters/" + j.id + ".jpg"
-	err := DownloadFile(path, fileurl, j.uploadlimit)
-
-	if !(err == nil) {
-		return defaultPosterPath, errors.New("Error while downloading file, using unknown.jpg")
+	req, err := http.NewRequest("POST", url, bytes.NewReader(raw))
+	if err != nil {
+		return err
 	}
-	j.l.Debug("poster path: %s", path)
-	return path, nil
+	req.Header.Set("Content-Type", "application/json")
+	return doJson(client, req, v)
 }
 
-func (j *jikan) getDuration() (string, error) {
-
-	dat := j.resp
+// doJson sends the request and decodes the JSON response into v.
+func doJson(client *http.Client, req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
 
-	if (*dat)["duration"] != nil {
-		return (*dat)["duration"].(string), nil
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Tried to access API: %v", err)
 	}
+	defer resp.Body.Close()
 
-	return "", nil
-}
-
-func (j *jikan) getRating() (string, error) {
-
-	dat := j.resp
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Tried to access API - Response Code: %v", resp.Status)
+	}
 
-	if (*dat)["score"] != nil {
-		return fmt.Sprintf("%v", (*dat)["score"].(float64)), nil
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
 	}
 
-	return "", nil
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("Error while unmarshalling json response: %v", err)
+	}
+	return nil
 }
 
-func (j *jikan) getTags() (string, error) {
+// formatDuration formats a runtime in minutes, eg "2 hr 16 min".
+func formatDuration(minutes int) string {
+	return fmt.Sprintf("%v hr %v min", minutes/60, minutes%60)
+}
 
-	dat := j.resp
+var re_htmlTag = regexp.MustCompile(`<[^>]*>`)
 
-	tagMaps := (*dat)["genres"].([]interface{})
+// stripHtml removes tags from descriptions that contain HTML.
+func stripHtml(text string) string {
+	return strings.TrimSpace(html.UnescapeString(re_htmlTag.ReplaceAllString(text, "")))
+}
 
-	tags := []string{}
-	tags = append(tags, "MAL")
+// animeLimits are the restrictions the site administrator put on anime.
+// They are shared by all the anime providers.
+type animeLimits struct {
+	excludedTypes []string
+	maxEpisodes   int // zero for no limit
+	maxDuration   int // total minutes, negative for no limit
+}
 
-	for _, tag := range tagMaps {
-		tg := tag.(map[string]interface{})
-		tags = append(tags, tg["name"].(string))
+func (b *backend) getAnimeLimits() (animeLimits, error) {
+	bannedTypes, err := b.GetJikanBannedTypes()
+	if err != nil {
+		b.autofillLog.Debug("Error while retriving config value 'JikanBannedTypes':\n %v", err)
+		return animeLimits{}, fmt.Errorf("Something went wrong :C")
 	}
 
-	// Sadly the Decision was made to pass all results as a slice of strings
-	// Therefore this has to be a string instead of a slice of string, otherwise it cannot be passed
-	// to the caller
-	return strings.Join(tags, ","), nil
-}
-
-func DownloadFile(filepath string, url string, uploadlimit int) error {
+	maxEpisodes, err := b.GetJikanMaxEpisodes()
+	if err != nil {
+		b.autofillLog.Debug("Error while retriving config value 'JikanMaxEpisodes':\n %v", err)
+		return animeLimits{}, fmt.Errorf("Something went wrong :C")
+	}
 
-	// Download image data
-	resp, err := http.Get(url)
+	maxDuration, err := b.GetMaxDuration()
 	if err != nil {
-		return err
+		b.autofillLog.Debug("Error while retriving config value 'MaxMultEpLength':\n %v", err)
+		return animeLimits{}, fmt.Errorf("Something went wrong :C")
 	}
-	defer resp.Body.Close()
 
-	if resp.ContentLength > int64(uploadlimit) {
-		return fmt.Errorf("Poster is too large - Max: %d, Requested: %d", uploadlimit, resp.ContentLength)
+	return animeLimits{
+		excludedTypes: bannedTypes,
+		maxEpisodes:   maxEpisodes,
+		maxDuration:   maxDuration,
+	}, nil
+}
+
+func (a animeLimits) bannedType(animeType string) bool {
+	for _, etype := range a.excludedTypes {
+		if strings.EqualFold(animeType, strings.TrimSpace(etype)) {
+			return true
+		}
 	}
+	return false
+}
 
-	// Create the file
-	file, err := os.Create(filepath)
-	if err != nil {
-		return err
+// check returns an error if the anime breaks one of the limits.  Episodes is
+// nil if the episode count is unknown and episodeLength is the length of a
+// single episode in minutes, or zero if unknown.
+func (a animeLimits) check(animeType string, episodes *int, episodeLength int) error {
+	if a.bannedType(animeType) {
+		return fmt.Errorf("The anime type %s was banned by the sites administrator. Please choose a different type!", animeType)
 	}
-	defer file.Close()
 
-	// Decode image data
-	image, err := jpeg.Decode(resp.Body)
-	if err != nil {
-		os.Remove(file.Name())
-		return err
+	if episodes == nil {
+		return fmt.Errorf("The episode count of this anime has not been published yet. Therefore this anime can not be added.")
 	}
 
-	// Resize the raw image
-	resized := resize.Resize(360, 0, image, resize.NearestNeighbor)
+	if *episodes > a.maxEpisodes && a.maxEpisodes != 0 {
+		return fmt.Errorf("The anime has too many (%d) episodes. The site administrator only allowed animes up to %d episodes.", *episodes, a.maxEpisodes)
+	}
 
-	// Reencode the image data
-	err = jpeg.Encode(file, resized, nil)
-	if err != nil {
-		os.Remove(file.Name())
-		return err
+	if a.maxDuration >= 0 && episodeLength*(*episodes) > a.maxDuration {
+		return fmt.Errorf("The duration of this series (episode duration * episodes) is longer than the maximum duration defined by the admin. Therefore this anime can not be added.")
 	}
 
 	return nil
@@ -0,0 +1,111 @@
+package logic
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// fixture is a recorded API response stored in testdata/.
+type fixture struct {
+	File   string
+	Status int    // defaults to 200
+	Auth   string // required Authorization header, if set
+}
+
+// newFixtureServer serves the given fixtures by request path.  Requests for
+// any other path get a 404.
+func newFixtureServer(t *testing.T, fixtures map[string]fixture) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := fixtures[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		if f.Auth != "" && r.Header.Get("Authorization") != f.Auth {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		raw, err := ioutil.ReadFile(filepath.Join("testdata", f.File))
+		if err != nil {
+			t.Errorf("Unable to read fixture %s: %v", f.File, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if f.Status == 0 {
+			f.Status = http.StatusOK
+		}
+
+		if filepath.Ext(f.File) == ".html" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(f.Status)
+		w.Write(raw)
+	}))
+}
+
+func TestMatchProvider(t *testing.T) {
+	tests := []struct {
+		url      string
+		provider string
+		id       string
+	}{
+		{"https://www.imdb.com/title/tt0133093/", "IMDb", "tt0133093"},
+		{"https://m.imdb.com/title/tt0133093/?ref_=nv_sr_1", "IMDb", "tt0133093"},
+		{"https://myanimelist.net/anime/5/Cowboy_Bebop__Tengoku_no_Tobira", "MyAnimeList", "5"},
+		{"https://myanimelist.net/anime/1", "MyAnimeList", "1"},
+		{"https://myanimelist.net/manga/2/Berserk", "", ""},
+		{"https://www.imdb.com/name/nm0000206/", "", ""},
+		{"https://www.themoviedb.org/movie/603", "IMDb", "603"},
+		{"https://letterboxd.com/film/the-matrix/", "Letterboxd", "the-matrix"},
+		{"https://letterboxd.com/someuser/film/the-matrix/", "Letterboxd", "the-matrix"},
+		{"https://anilist.co/anime/1/Cowboy-Bebop/", "AniList", "1"},
+		{"https://anilist.co/manga/30002/Berserk/", "", ""},
+		{"https://kitsu.io/anime/cowboy-bebop", "Kitsu", "cowboy-bebop"},
+		{"https://kitsu.app/anime/1", "Kitsu", "1"},
+		{"https://thetvdb.com/movies/the-matrix", "TVDB", "movies/the-matrix"},
+		{"https://www.thetvdb.com/series/cowboy-bebop/seasons", "TVDB", "series/cowboy-bebop"},
+		{"https://example.com/", "", ""},
+	}
+
+	for _, tt := range tests {
+		def, id := matchProvider(tt.url)
+		name := ""
+		if def != nil {
+			name = def.Name
+		}
+
+		if name != tt.provider || id != tt.id {
+			t.Errorf("matchProvider(%q) = %q, %q; expected %q, %q", tt.url, name, id, tt.provider, tt.id)
+		}
+	}
+}
+
+func TestProviderPatterns(t *testing.T) {
+	for _, def := range metadataProviders {
+		if def.Enabled == nil {
+			t.Errorf("Provider %s has no enable setting", def.Name)
+		}
+
+		if def.New == nil {
+			t.Errorf("Provider %s has no constructor", def.Name)
+		}
+
+		if len(def.Patterns) == 0 {
+			t.Errorf("Provider %s has no URL patterns", def.Name)
+		}
+
+		for _, re := range def.Patterns {
+			if re.NumSubexp() < 1 {
+				t.Errorf("Pattern %q of provider %s has no ID submatch", re, def.Name)
+			}
+		}
+	}
+}
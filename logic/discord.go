@@ -0,0 +1,122 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+const discordApiUrl = "https://discord.com/api"
+
+// The guild membership of a Discord login is trusted for this long.  After
+// that the user has to log in with Discord again before they can vote, so
+// leaving or being kicked from the guild takes effect.
+const discordMemberMaxAge = 24 * time.Hour
+
+// ErrDiscordMembership is returned for Discord logins whose guild membership
+// hasn't been checked in a while.
+var ErrDiscordMembership = errors.New("Please log in with Discord again to confirm you are still a member of our Discord server")
+
+// CheckDiscordGuild checks the guild membership of the Discord user the
+// access token belongs to against the configured DiscordGuildID and
+// DiscordRequiredRoles.  Returns true if the member holds one of the
+// DiscordModRoles.  Returns an error if the user is not allowed in.
+func (b *backend) CheckDiscordGuild(accessToken string) (bool, error) {
+	guildId, err := b.GetDiscordGuildID()
+	if err != nil {
+		return false, err
+	}
+
+	// No guild configured, every Discord user is allowed
+	if guildId == "" {
+		return false, nil
+	}
+
+	required, err := b.GetDiscordRequiredRoles()
+	if err != nil {
+		return false, err
+	}
+
+	modRoles, err := b.GetDiscordModRoles()
+	if err != nil {
+		return false, err
+	}
+
+	return checkDiscordMember(b.client, discordApiUrl, guildId, accessToken, required, modRoles)
+}
+
+func checkDiscordMember(client *http.Client, apiUrl, guildId, accessToken string, required, modRoles []string) (bool, error) {
+	req, err := http.NewRequest("GET", apiUrl+"/users/@me/guilds/"+url.PathEscape(guildId)+"/member", nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	// Membership can change at any time.
+	req.Header.Set("Cache-Control", "no-store")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("Could not retrieve guild membership from Discord API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, fmt.Errorf("You need to be a member of our Discord server to use Discord login")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("Could not retrieve guild membership from Discord API: %v", resp.Status)
+	}
+
+	member := struct {
+		Roles []string `json:"roles"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&member); err != nil {
+		return false, fmt.Errorf("Could not parse guild membership from Discord API: %v", err)
+	}
+
+	if len(required) > 0 && !hasAnyRole(member.Roles, required) {
+		return false, fmt.Errorf("You do not have a Discord role required to use Discord login")
+	}
+
+	return hasAnyRole(member.Roles, modRoles), nil
+}
+
+func hasAnyRole(roles []string, wanted []string) bool {
+	for _, role := range roles {
+		for _, w := range wanted {
+			if role == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckDiscordMembership returns ErrDiscordMembership if the session belongs
+// to a Discord login whose guild membership was checked more than
+// discordMemberMaxAge ago.  The session is ended then, logging in again
+// checks the membership.
+func (b *backend) CheckDiscordMembership(session *models.Session) error {
+	if session == nil || session.AuthType != models.AUTH_DISCORD {
+		return nil
+	}
+
+	guildId, err := b.GetDiscordGuildID()
+	if err != nil {
+		return err
+	}
+
+	if guildId == "" || time.Since(session.MemberChecked) < discordMemberMaxAge {
+		return nil
+	}
+
+	b.dropSession(session, "Discord membership not checked since "+session.MemberChecked.Format(time.RFC3339))
+	return ErrDiscordMembership
+}
@@ -0,0 +1,134 @@
+package logic
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+const (
+	discordTestGuild = "81384788765712384"
+	discordTestRole  = "41771983423143936"
+	discordTestMod   = "41771983423143937"
+)
+
+func TestDiscordMember(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/users/@me/guilds/" + discordTestGuild + "/member": {File: "discord_member.json", Auth: "Bearer token"},
+	})
+	defer srv.Close()
+
+	tests := []struct {
+		required []string
+		modRoles []string
+		isMod    bool
+		err      string
+	}{
+		{nil, nil, false, ""},
+		{[]string{discordTestRole}, nil, false, ""},
+		{[]string{"1", discordTestRole}, []string{discordTestMod}, true, ""},
+		{nil, []string{"1"}, false, ""},
+		{[]string{"1"}, []string{discordTestMod}, false, "role required"},
+	}
+
+	for _, tt := range tests {
+		isMod, err := checkDiscordMember(http.DefaultClient, srv.URL, discordTestGuild, "token", tt.required, tt.modRoles)
+		if tt.err == "" && err != nil {
+			t.Errorf("Required %v, mod %v: unexpected error %v", tt.required, tt.modRoles, err)
+		} else if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("Required %v, mod %v: expected an error with %q, got %v", tt.required, tt.modRoles, tt.err, err)
+		}
+		if isMod != tt.isMod {
+			t.Errorf("Required %v, mod %v: isMod is %v", tt.required, tt.modRoles, isMod)
+		}
+	}
+}
+
+func TestDiscordMemberRejected(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/users/@me/guilds/" + discordTestGuild + "/member": {File: "discord_member.json", Auth: "Bearer token"},
+		"/users/@me/guilds/1/member":                        {File: "discord_unknown_guild.json", Status: http.StatusNotFound},
+	})
+	defer srv.Close()
+
+	// Not a member of the guild.
+	_, err := checkDiscordMember(http.DefaultClient, srv.URL, "1", "token", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "member of our Discord server") {
+		t.Errorf("Expected a membership error, got %v", err)
+	}
+
+	// Expired or revoked token.
+	_, err = checkDiscordMember(http.DefaultClient, srv.URL, discordTestGuild, "expired", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected an API error, got %v", err)
+	}
+}
+
+func TestDiscordMemberNotCached(t *testing.T) {
+	var count int32
+	srv := countingServer(&count, "application/json", http.StatusOK)
+	defer srv.Close()
+
+	client, dir := newTestClient(t, nil)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 2; i++ {
+		if _, err := checkDiscordMember(client, srv.URL, discordTestGuild, "token", nil, nil); err != nil {
+			t.Fatalf("checkDiscordMember() returned an error: %v", err)
+		}
+	}
+
+	if count != 2 {
+		t.Errorf("Expected every check to reach Discord, got %d requests", count)
+	}
+}
+
+func TestCheckDiscordMembership(t *testing.T) {
+	b, cleanup := newTestBackend(t)
+	defer cleanup()
+
+	addSession := func(authType models.AuthType, checked time.Time) *models.Session {
+		session := &models.Session{UserId: 1, AuthType: authType, TokenHash: string(authType) + checked.String(), MemberChecked: checked}
+		id, err := b.data.AddSession(session)
+		if err != nil {
+			t.Fatalf("AddSession() returned an error: %v", err)
+		}
+		session.Id = id
+		return session
+	}
+
+	old := time.Now().Add(-discordMemberMaxAge - time.Minute)
+	stale := addSession(models.AUTH_DISCORD, old)
+
+	// Without a guild there is nothing to check.
+	if err := b.CheckDiscordMembership(stale); err != nil {
+		t.Errorf("CheckDiscordMembership() returned %v without a guild", err)
+	}
+
+	if err := b.data.SetCfgString(ConfigDiscordGuildID, discordTestGuild); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.CheckDiscordMembership(nil); err != nil {
+		t.Errorf("CheckDiscordMembership() returned %v without a session", err)
+	}
+
+	if err := b.CheckDiscordMembership(addSession(models.AUTH_LOCAL, time.Time{})); err != nil {
+		t.Errorf("CheckDiscordMembership() returned %v for a password login", err)
+	}
+
+	if err := b.CheckDiscordMembership(addSession(models.AUTH_DISCORD, time.Now())); err != nil {
+		t.Errorf("CheckDiscordMembership() returned %v for a new login", err)
+	}
+
+	if err := b.CheckDiscordMembership(stale); err != ErrDiscordMembership {
+		t.Errorf("CheckDiscordMembership() returned %v for an old login", err)
+	}
+	if _, err := b.data.GetSession(stale.TokenHash); err == nil {
+		t.Errorf("The session of the old login wasn't ended")
+	}
+}
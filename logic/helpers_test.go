@@ -0,0 +1,56 @@
+package logic
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zorchenhimer/MoviePolls/database"
+)
+
+// newTestBackend returns a backend with an empty JSON database in a temporary
+// directory.  Call the returned function to remove it again.
+func newTestBackend(t *testing.T) (*backend, func()) {
+	dir, err := ioutil.TempDir("", "logic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The JSON connector creates a db directory in the working directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	cleanup := func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	}
+
+	db, err := database.GetDatabase("json", filepath.Join(dir, "data.json"), nil, nil)
+	if err != nil {
+		cleanup()
+		t.Fatalf("Unable to create the database: %v", err)
+	}
+
+	b := &backend{
+		data:   db,
+		stop:   make(chan struct{}),
+		recent: newRecentItems(),
+		search: newSearchIndex(),
+	}
+
+	// ConfigValues is global, it only has to be filled once.
+	if len(ConfigValues) == 0 {
+		b.setupConfig()
+	}
+
+	return b, func() {
+		db.Close()
+		cleanup()
+	}
+}
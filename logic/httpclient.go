@@ -0,0 +1,297 @@
+package logic
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	apiTimeout    = 30 * time.Second
+	apiRetries    = 3
+	apiRetryDelay = 500 * time.Millisecond
+	apiMaxBackoff = 30 * time.Second
+	apiCacheTTL   = time.Hour
+	apiCacheDir   = "cache"
+)
+
+// hostLimits is the minimum time between two requests to an API host.
+// Hosts that are not listed are not limited.
+var hostLimits = map[string]time.Duration{
+	"api.jikan.moe":      2 * time.Second,        // 30 requests a minute
+	"api.themoviedb.org": 250 * time.Millisecond, // about 40 requests every 10 seconds
+	"graphql.anilist.co": 700 * time.Millisecond, // 90 requests a minute
+	"kitsu.io":           250 * time.Millisecond,
+	"api4.thetvdb.com":   250 * time.Millisecond,
+	"letterboxd.com":     time.Second,
+}
+
+// newHttpClient returns the client used for every request to an external
+// site.  Requests time out, are retried with a backoff when the server is
+// overloaded, and are rate limited per host.  Successful JSON responses to
+// GET requests are cached in cacheDir.  An empty cacheDir disables the
+// cache.
+func newHttpClient(cacheDir string, limits map[string]time.Duration) *http.Client {
+	var transport http.RoundTripper = &limitTransport{
+		next:   http.DefaultTransport,
+		limits: limits,
+	}
+
+	transport = &retryTransport{
+		next:    transport,
+		retries: apiRetries,
+		delay:   apiRetryDelay,
+	}
+
+	if cacheDir != "" {
+		transport = &cacheTransport{
+			next: transport,
+			dir:  cacheDir,
+			ttl:  apiCacheTTL,
+		}
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   apiTimeout,
+	}
+}
+
+// A rateLimiter spaces out requests so that no more than one request is sent
+// per interval.  Requests over the limit wait their turn.
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func (r *rateLimiter) wait() {
+	r.mu.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	delay := r.next.Sub(now)
+	r.next = r.next.Add(r.interval)
+	r.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+// limitTransport applies the rate limit of the request's host.
+type limitTransport struct {
+	next   http.RoundTripper
+	limits map[string]time.Duration
+
+	mu       sync.Mutex
+	limiters map[string]*rateLimiter
+}
+
+func (t *limitTransport) limiter(host string) *rateLimiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	interval, ok := t.limits[host]
+	if !ok {
+		return nil
+	}
+
+	if t.limiters == nil {
+		t.limiters = map[string]*rateLimiter{}
+	}
+
+	limiter, ok := t.limiters[host]
+	if !ok {
+		limiter = &rateLimiter{interval: interval}
+		t.limiters[host] = limiter
+	}
+	return limiter
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if limiter := t.limiter(req.URL.Hostname()); limiter != nil {
+		limiter.wait()
+	}
+	return t.next.RoundTrip(req)
+}
+
+// retryTransport retries requests that failed to connect or were answered
+// with a 429 or 5xx status.  The delay doubles after every attempt, unless
+// the server asks for a specific delay with a Retry-After header.
+type retryTransport struct {
+	next    http.RoundTripper
+	retries int
+	delay   time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests with a body can only be retried if the body can be read again.
+	replayable := req.Body == nil || req.GetBody != nil
+	delay := t.delay
+
+	for attempt := 0; ; attempt++ {
+		try := req
+		if attempt > 0 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			try = req.Clone(req.Context())
+			try.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(try)
+		if attempt >= t.retries || !replayable || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		wait := delay
+		if resp != nil {
+			if after := retryAfter(resp); after > 0 {
+				wait = after
+			}
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryAfter returns the delay in a Retry-After header given in seconds.
+// Zero is returned if there is no usable header.
+func retryAfter(resp *http.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+
+	after := time.Duration(secs) * time.Second
+	if after > apiMaxBackoff {
+		return apiMaxBackoff
+	}
+	return after
+}
+
+// cacheTransport keeps successful JSON responses to GET requests on disk for
+// ttl.  Images, pages and requests sent with "Cache-Control: no-store" are
+// not cached.  Expired responses are deleted.
+type cacheTransport struct {
+	next http.RoundTripper
+	dir  string
+	ttl  time.Duration
+
+	pruneLock sync.Mutex
+	lastPrune time.Time // when dir was last checked for expired responses
+}
+
+// cacheKey names the cache file of a request.  The Authorization header is
+// part of the key so responses are never shared between credentials.
+func cacheKey(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.URL.String() + "\n" + req.Header.Get("Authorization")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || strings.Contains(req.Header.Get("Cache-Control"), "no-store") {
+		return t.next.RoundTrip(req)
+	}
+
+	path := filepath.Join(t.dir, cacheKey(req))
+	if resp := t.load(path, req); resp != nil {
+		return resp, nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return resp, err
+	}
+
+	// DumpResponse replaces the body it reads, so resp can still be returned.
+	raw, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(t.dir, 0755); err == nil {
+		ioutil.WriteFile(path, raw, 0644)
+	}
+	t.prune(time.Now())
+	return resp, nil
+}
+
+// load returns the cached response for req, or nil if there is no cached
+// response or it has expired.
+func (t *cacheTransport) load(path string, req *http.Request) *http.Response {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if time.Since(info.ModTime()) > t.ttl {
+		os.Remove(path)
+		return nil
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(raw)), req)
+	if err != nil {
+		return nil
+	}
+	return resp
+}
+
+// prune deletes the expired responses in the cache directory, at most once
+// per ttl.  Responses that are never asked for again would stay forever
+// otherwise.
+func (t *cacheTransport) prune(now time.Time) {
+	t.pruneLock.Lock()
+	if now.Sub(t.lastPrune) < t.ttl {
+		t.pruneLock.Unlock()
+		return
+	}
+	t.lastPrune = now
+	t.pruneLock.Unlock()
+
+	files, err := ioutil.ReadDir(t.dir)
+	if err != nil {
+		return
+	}
+
+	for _, info := range files {
+		// Only touch files named by cacheKey.
+		if info.IsDir() || len(info.Name()) != sha256.Size*2 {
+			continue
+		}
+		if now.Sub(info.ModTime()) > t.ttl {
+			os.Remove(filepath.Join(t.dir, info.Name()))
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package logic
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := &rateLimiter{interval: 20 * time.Millisecond}
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		limiter.wait()
+	}
+
+	// The first request goes out right away, the other three wait.
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("Four requests took %v, expected at least 60ms", elapsed)
+	}
+}
+
+// countingServer answers every request with the next status from statuses,
+// repeating the last one, and counts the requests it got.
+func countingServer(count *int32, contentType string, statuses ...int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(count, 1))
+		status := statuses[len(statuses)-1]
+		if n <= len(statuses) {
+			status = statuses[n-1]
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"request": %d}`, n)
+	}))
+}
+
+func newTestClient(t *testing.T, limits map[string]time.Duration) (*http.Client, string) {
+	dir, err := ioutil.TempDir("", "mp-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := newHttpClient(dir, limits)
+	client.Transport.(*cacheTransport).next.(*retryTransport).delay = time.Millisecond
+	return client, dir
+}
+
+func TestHttpClientRetry(t *testing.T) {
+	var count int32
+	srv := countingServer(&count, "application/json", http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+	defer srv.Close()
+
+	client, dir := newTestClient(t, nil)
+	defer os.RemoveAll(dir)
+
+	var v struct{ Request int }
+	if err := getJson(client, srv.URL, &v); err != nil {
+		t.Fatalf("getJson() returned an error: %v", err)
+	}
+
+	if v.Request != 3 || count != 3 {
+		t.Errorf("Expected the third request to succeed, got response %d after %d requests", v.Request, count)
+	}
+}
+
+func TestHttpClientRetryGivesUp(t *testing.T) {
+	var count int32
+	srv := countingServer(&count, "application/json", http.StatusInternalServerError)
+	defer srv.Close()
+
+	client, dir := newTestClient(t, nil)
+	defer os.RemoveAll(dir)
+
+	var v struct{}
+	if err := getJson(client, srv.URL, &v); err == nil {
+		t.Errorf("Expected an error from a server that always fails")
+	}
+
+	if count != apiRetries+1 {
+		t.Errorf("Expected %d requests, got %d", apiRetries+1, count)
+	}
+}
+
+func TestHttpClientNoRetryOnClientError(t *testing.T) {
+	var count int32
+	srv := countingServer(&count, "application/json", http.StatusNotFound)
+	defer srv.Close()
+
+	client, dir := newTestClient(t, nil)
+	defer os.RemoveAll(dir)
+
+	var v struct{}
+	if err := getJson(client, srv.URL, &v); err == nil {
+		t.Errorf("Expected an error for a 404")
+	}
+
+	if count != 1 {
+		t.Errorf("A 404 should not be retried, got %d requests", count)
+	}
+}
+
+func TestHttpClientRetryPost(t *testing.T) {
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&count, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	client, dir := newTestClient(t, nil)
+	defer os.RemoveAll(dir)
+
+	// The body has to be sent again with the retry.
+	var v struct{ Query string }
+	if err := postJson(client, srv.URL, map[string]string{"Query": "bebop"}, &v); err != nil {
+		t.Fatalf("postJson() returned an error: %v", err)
+	}
+
+	if v.Query != "bebop" {
+		t.Errorf("Retried request had the body %q", v.Query)
+	}
+}
+
+func TestHttpClientCache(t *testing.T) {
+	var count int32
+	srv := countingServer(&count, "application/json", http.StatusOK)
+	defer srv.Close()
+
+	client, dir := newTestClient(t, nil)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 3; i++ {
+		var v struct{ Request int }
+		if err := getJson(client, srv.URL+"/movie/603", &v); err != nil {
+			t.Fatalf("getJson() returned an error: %v", err)
+		}
+
+		if v.Request != 1 {
+			t.Errorf("Expected the cached response, got response %d", v.Request)
+		}
+	}
+
+	var v struct{ Request int }
+	if err := getJson(client, srv.URL+"/movie/604", &v); err != nil {
+		t.Fatalf("getJson() returned an error: %v", err)
+	}
+
+	if count != 2 {
+		t.Errorf("Expected 2 requests to the server, got %d", count)
+	}
+}
+
+func TestHttpClientCacheExpired(t *testing.T) {
+	var count int32
+	srv := countingServer(&count, "application/json", http.StatusOK)
+	defer srv.Close()
+
+	client, dir := newTestClient(t, nil)
+	defer os.RemoveAll(dir)
+	transport := client.Transport.(*cacheTransport)
+
+	get := func(path string) int {
+		var v struct{ Request int }
+		if err := getJson(client, srv.URL+path, &v); err != nil {
+			t.Fatalf("getJson() returned an error: %v", err)
+		}
+		return v.Request
+	}
+
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	get("/movie/603")
+
+	// An expired response, one nobody asks for anymore and a file that
+	// isn't a response at all.
+	old := time.Now().Add(-2 * apiCacheTTL)
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("Expected one cached response, got %d", len(files))
+	}
+	cached := files[0].Name()
+	orphan := strings.Repeat("a", len(cached))
+	for _, name := range []string{orphan, "notes.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{cached, orphan, "notes.txt"} {
+		if err := os.Chtimes(filepath.Join(dir, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The expired response is replaced.
+	if n := get("/movie/603"); n != 2 {
+		t.Errorf("Expected a new response for the expired one, got response %d", n)
+	}
+	if info, err := os.Stat(filepath.Join(dir, cached)); err != nil || info.ModTime().Before(time.Now().Add(-time.Minute)) {
+		t.Errorf("The expired response wasn't replaced: %v", err)
+	}
+
+	// The directory was pruned with the first response, the next prune
+	// is due after the ttl.
+	if !exists(orphan) {
+		t.Errorf("The cache was pruned again before the ttl was over")
+	}
+
+	transport.lastPrune = time.Time{}
+	get("/movie/604")
+
+	if exists(orphan) {
+		t.Errorf("The expired response of another request wasn't deleted")
+	}
+	if !exists("notes.txt") || !exists(cached) {
+		t.Errorf("Pruning deleted a file that isn't an expired response")
+	}
+}
+
+func TestHttpClientCacheSkipsImages(t *testing.T) {
+	var count int32
+	srv := countingServer(&count, "image/jpeg", http.StatusOK)
+	defer srv.Close()
+
+	client, dir := newTestClient(t, nil)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Get() returned an error: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if count != 2 {
+		t.Errorf("Images should not be cached, got %d requests", count)
+	}
+}
+
+func TestHttpClientHostLimit(t *testing.T) {
+	var count int32
+	srv := countingServer(&count, "text/plain", http.StatusOK)
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	client, dir := newTestClient(t, map[string]time.Duration{u.Hostname(): 30 * time.Millisecond})
+	defer os.RemoveAll(dir)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Get() returned an error: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("Three limited requests took %v, expected at least 60ms", elapsed)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"2", 2 * time.Second},
+		{"3600", apiMaxBackoff},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0},
+	}
+
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("Retry-After", tt.header)
+		if after := retryAfter(resp); after != tt.expected {
+			t.Errorf("retryAfter(%q) returned %v, expected %v", tt.header, after, tt.expected)
+		}
+	}
+}
@@ -2,6 +2,12 @@ package logic
 
 import "github.com/zorchenhimer/MoviePolls/models"
 
+// AddLink saves the link, or returns the id of an existing one with the same
+// URL.  Either way the cleanup leaves it alone until the movie is saved.
 func (b *backend) AddLink(link *models.Link) (int, error) {
-	return b.data.AddLink(link)
+	id, err := b.data.AddLink(link)
+	if err == nil {
+		b.recent.useLink(id)
+	}
+	return id, err
 }
@@ -0,0 +1,27 @@
+package logic
+
+import (
+	"github.com/zorchenhimer/MoviePolls/logger"
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+// GetLogLevels returns the current log level of every subsystem.
+func (b *backend) GetLogLevels() map[string]logger.LogLevel {
+	return b.l.Levels()
+}
+
+// AdminSetLogLevel changes the log level of a subsystem until the next
+// restart.  The command line decides the levels after that.
+func (b *backend) AdminSetLogLevel(admin *models.User, subsystem string, level logger.LogLevel) error {
+	before := b.l.Levels()[subsystem]
+	if before == level {
+		return nil
+	}
+
+	if err := b.l.SetLevel(subsystem, level); err != nil {
+		return err
+	}
+
+	b.audit(admin, models.AUDIT_LOG_LEVEL, "Logger", 0, subsystem, string(before), string(level))
+	return nil
+}
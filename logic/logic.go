@@ -1,19 +1,31 @@
 package logic
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"mime/multipart"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/zorchenhimer/MoviePolls/database"
 	"github.com/zorchenhimer/MoviePolls/logger"
 	"github.com/zorchenhimer/MoviePolls/models"
+	"github.com/zorchenhimer/MoviePolls/storage"
 )
 
 type Logic interface {
+	// Lifecycle
+	Ping() error
+	Close(ctx context.Context) error
+
 	// security
 	GetKeys() (string, string, string, error)
+	GetSessionKeys() ([]SessionKey, int)
+	SessionKeyGeneration() int
+	AdminRotateSessionKeys(admin *models.User) error
 	GetUrlKeys() map[string]*models.UrlKey
 	SetUrlKey(key string, val *models.UrlKey)
 	DeleteUrlKey(key string)
@@ -25,10 +37,14 @@ type Logic interface {
 	AddMovie(fields map[string]*InputField, user *models.User, file multipart.File, fileHeader *multipart.FileHeader) (int, map[string]*InputField)
 	GetMovie(id int) *models.Movie
 	GetActiveMovies() ([]*models.Movie, error)
-	SearchMovieTitles(query string) ([]*models.Movie, error)
+	SearchMovies(query string, filter SearchFilter) ([]*MovieSearchResult, error)
 	UpdateMovie(movie *models.Movie) error
-	DeleteMovie(mid int) error
-	UploadFile(file multipart.File, header *multipart.FileHeader, name string) (string, error)
+	DeleteMovie(admin *models.User, mid int) error
+	UploadFile(file multipart.File, header *multipart.FileHeader) (string, error)
+
+	// Poster stuff
+	GetPoster(key string) (io.ReadCloser, error)
+	GetPosterRedirect(key string) (string, error)
 
 	// Link stuff
 	AddLink(*models.Link) (int, error)
@@ -63,9 +79,69 @@ type Logic interface {
 
 	// Admin stuff
 	CheckAdminRights(user *models.User) bool
-	AdminDeleteUser(user *models.User) error
-	AdminBanUser(user *models.User) error
-	AdminPurgeUser(user *models.User) error
+	CheckPermission(user *models.User, perm models.Permission) bool
+	AdminDeleteUser(admin *models.User, user *models.User) error
+	AdminBanUser(admin *models.User, user *models.User, reason string, expires *time.Time) error
+	AdminUnbanUser(admin *models.User, user *models.User) error
+	AdminPurgeUser(admin *models.User, user *models.User) error
+	AdminPasswordReset(admin *models.User, user *models.User) (*models.UrlKey, error)
+	AdminSetConfigValue(admin *models.User, key string, value interface{}) error
+	AdminStartCycle(admin *models.User, plannedEnd *time.Time) (int, error)
+	AdminSetCyclePlannedEnd(admin *models.User, cycle *models.Cycle, plannedEnd *time.Time) error
+	AdminEndCycle(admin *models.User, cycle *models.Cycle, watched []*models.Movie, ended time.Time) error
+	GetAuditLog(filter models.AuditFilter) ([]*models.AuditEntry, error)
+
+	// Metadata refresh
+	GetMetadataChanges(movie *models.Movie) ([]*MetadataChange, error)
+	AdminRefreshMetadata(admin *models.User, movie *models.Movie, fields []string) error
+
+	// Tag stuff
+	GetTagUsage() ([]*TagUsage, error)
+	GetDuplicateTags() ([][]*TagUsage, error)
+	GetTagMovies(name string) (*models.Tag, []*models.Movie, error)
+	AdminRenameTag(admin *models.User, id int, name string) error
+	AdminMergeTags(admin *models.User, into int, ids []int) error
+	AdminDeleteTag(admin *models.User, id int) error
+
+	// Cleanup
+	Cleanup(admin *models.User, dryRun bool) (*CleanupReport, error)
+
+	// Logging
+	GetLogLevels() map[string]logger.LogLevel
+	AdminSetLogLevel(admin *models.User, subsystem string, level logger.LogLevel) error
+
+	// Approval queue
+	GetPendingMovies() ([]*models.Movie, error)
+	AdminApproveMovie(admin *models.User, movie *models.Movie) error
+	AdminDenyMovie(admin *models.User, movie *models.Movie, reason string) error
+
+	// Sessions
+	NewSession(user *models.User, authType models.AuthType, userAgent, address string) (string, error)
+	GetSession(token string) (*models.Session, *models.User, error)
+	GetUserSessions(user *models.User) ([]*models.Session, error)
+	EndSession(token string) error
+	RevokeSession(user *models.User, id int) error
+	RevokeOtherSessions(user *models.User, current *models.Session) error
+	AdminRevokeSessions(admin *models.User, user *models.User) error
+
+	// Notifications
+	GetUserNotifications(user *models.User) ([]*models.Notification, error)
+	GetUnreadNotificationCount(user *models.User) int
+	MarkNotificationsRead(user *models.User) error
+
+	// Roles
+	GetRoles() ([]*models.Role, error)
+	GetRole(id int) (*models.Role, error)
+	AddRole(admin *models.User, role *models.Role) (int, error)
+	UpdateRole(admin *models.User, role *models.Role) error
+	DeleteRole(admin *models.User, role *models.Role) error
+	AdminSetUserRole(admin *models.User, user *models.User, roleId int) error
+
+	// Bans
+	GetBans() ([]*models.Ban, error)
+	GetUserBan(user *models.User) (*models.Ban, error)
+	GetOauthBan(extId string, authType models.AuthType) (*models.Ban, error)
+	GetEmailBan(email string) (*models.Ban, error)
 
 	// Settings
 	GetConfigBanner() (string, error)
@@ -86,6 +162,7 @@ type Logic interface {
 	GetMaxLinkLength() (int, error)
 	GetMaxNameLength() (int, error)
 	GetAutofillEnabled() (bool, error)
+	SearchMetadata(query string) ([]*SearchResult, error)
 	GetPastCycles(start, count int) ([]*models.Cycle, error)
 	GetPreviousCycle() *models.Cycle
 
@@ -103,6 +180,11 @@ type Logic interface {
 	GetTwitchOauthClientSecret() (string, error)
 	GetDiscordOauthClientID() (string, error)
 	GetDiscordOauthClientSecret() (string, error)
+	GetDiscordGuildID() (string, error)
+	GetDiscordRequiredRoles() ([]string, error)
+	GetDiscordModRoles() ([]string, error)
+	CheckDiscordGuild(accessToken string) (bool, error)
+	CheckDiscordMembership(session *models.Session) error
 	GetPatreonOauthClientID() (string, error)
 	GetPatreonOauthClientSecret() (string, error)
 
@@ -125,18 +207,42 @@ type backend struct {
 	authKey      string
 	encryptKey   string
 	passwordSalt string
+	client       *http.Client // used for every request to an external site
+	posters      storage.PosterStore
+	search       *searchIndex
 	l            *logger.Logger
+	autofillLog  *logger.Logger // autofill and metadata refresh
+	recent       *recentItems   // kept from the cleanup until their movie is saved
+
+	keyLock       sync.Mutex   // guards the session keys below
+	sessionKeys   []SessionKey // current keys first
+	keyGeneration int          // changes with sessionKeys
+	keysRotated   time.Time
+
+	stop chan struct{}  // closed to stop the background jobs
+	jobs sync.WaitGroup // running background jobs
 }
 
-func New(db database.Database, log *logger.Logger) (Logic, error) {
+func New(db database.Database, posters storage.PosterStore, log *logger.Logger) (Logic, error) {
 	back := &backend{
 		data:    db,
 		urlKeys: make(map[string]*models.UrlKey),
+		client:  newHttpClient(apiCacheDir, hostLimits),
+		posters: posters,
 		l:       log,
+		stop:    make(chan struct{}),
+		recent:  newRecentItems(),
+
+		autofillLog: log.Sub("autofill"),
 	}
 
 	back.setupConfig()
-	err := back.LoadDefaultsIfNotSet()
+	err := db.SetSecretSettings(secretConfigKeys())
+	if err != nil {
+		return nil, fmt.Errorf("Unable to encrypt secret settings: %v", err)
+	}
+
+	err = back.LoadDefaultsIfNotSet()
 	if err != nil {
 		return nil, err
 	}
@@ -206,9 +312,72 @@ func New(db database.Database, log *logger.Logger) (Logic, error) {
 	back.encryptKey = encryptKey
 	back.passwordSalt = passwordSalt
 
+	if err := back.loadSessionKeys(); err != nil {
+		return nil, err
+	}
+
+	if err := back.seedDefaultPoster(); err != nil {
+		log.Error("Unable to add the default poster to the poster store: %v", err)
+	}
+
+	if err := back.buildSearchIndex(); err != nil {
+		return nil, fmt.Errorf("Unable to build the search index: %v", err)
+	}
+
+	back.startJob(back.metadataRefreshLoop)
+	back.startJob(back.cleanupLoop)
+	back.startJob(back.sessionPruneLoop)
+	back.startJob(back.sessionKeyLoop)
+
 	return back, nil
 }
 
+// startJob runs a background job until Close is called.  Jobs have to
+// return once b.stop is closed.
+func (b *backend) startJob(job func()) {
+	b.jobs.Add(1)
+	go func() {
+		defer b.jobs.Done()
+		job()
+	}()
+}
+
+// stopping reports whether Close has been called, for jobs that want to
+// stop in the middle of their work.
+func (b *backend) stopping() bool {
+	select {
+	case <-b.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Ping returns an error if the database doesn't answer.
+func (b *backend) Ping() error {
+	return b.data.Ping()
+}
+
+// Close stops the background jobs and closes the database.  If the jobs
+// don't stop before ctx is done, the database is closed anyway.
+func (b *backend) Close(ctx context.Context) error {
+	close(b.stop)
+
+	done := make(chan struct{})
+	go func() {
+		b.jobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		b.l.Error("Background jobs didn't stop in time: %v", ctx.Err())
+	}
+
+	return b.data.Close()
+}
+
 func (b *backend) GetUrlKeys() map[string]*models.UrlKey {
 	return b.urlKeys
 }
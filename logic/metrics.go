@@ -0,0 +1,47 @@
+package logic
+
+import (
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/metrics"
+)
+
+var (
+	metricVotes = metrics.NewCounter(
+		"moviepolls_votes_total",
+		"Votes cast.")
+
+	metricMoviesAdded = metrics.NewCounter(
+		"moviepolls_movies_added_total",
+		"Movies added, including the ones waiting for approval.")
+
+	metricAutofillRequests = metrics.NewCounter(
+		"moviepolls_autofill_requests_total",
+		"Requests to metadata providers by result (success or failure).",
+		"provider", "operation", "result")
+
+	metricAutofillDuration = metrics.NewHistogram(
+		"moviepolls_autofill_duration_seconds",
+		"Duration of requests to metadata providers.",
+		metrics.DefaultBuckets,
+		"provider", "operation")
+)
+
+// observeAutofill records a fetch or search of a metadata provider.
+func observeAutofill(provider, operation string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+
+	metricAutofillRequests.Inc(provider, operation, result)
+	metricAutofillDuration.ObserveSince(start, provider, operation)
+}
+
+// fetchMetadata calls Fetch on a provider and records the request.
+func fetchMetadata(def *providerDef, provider MetadataProvider, id string) (*MovieMetadata, error) {
+	start := time.Now()
+	meta, err := provider.Fetch(id)
+	observeAutofill(def.Name, "fetch", start, err)
+	return meta, err
+}
@@ -2,42 +2,30 @@ package logic
 
 import (
 	"fmt"
-	"io/ioutil"
 	"mime/multipart"
-	"regexp"
-	"strconv"
 	"strings"
 
 	"github.com/zorchenhimer/MoviePolls/models"
 )
 
-var re_tagSearch = regexp.MustCompile(`t:"([a-zA-Z ]+)"`)
-
-func (b *backend) SearchMovieTitles(query string) ([]*models.Movie, error) {
-	// finding tags
-	tags := re_tagSearch.FindAllString(query, -1)
-
-	// clean up the tags from the "tagsyntax"
-	tagsToFind := []string{}
-	for _, tag := range tags {
-		tagsToFind = append(tagsToFind, tag[3:len(tag)-1])
-	}
-
-	query = re_tagSearch.ReplaceAllString(query, "")
-	query = strings.Trim(query, " ")
-
-	// we first seach for matching titles (ignoring the tags for now)
-	movieList, err := b.data.SearchMovieTitles(query)
+// GetActiveMovies returns the unwatched movies that can be voted on.  Movies
+// waiting for approval or that have been denied are left out.
+func (b *backend) GetActiveMovies() ([]*models.Movie, error) {
+	movies, err := b.data.GetActiveMovies()
 	if err != nil {
 		return nil, err
 	}
-
-	// NOW we filter the already found movies by the tags provided
-	return models.FilterMoviesByTags(movieList, tagsToFind)
+	return visibleMovies(movies), nil
 }
 
-func (b *backend) GetActiveMovies() ([]*models.Movie, error) {
-	return b.data.GetActiveMovies()
+func visibleMovies(movies []*models.Movie) []*models.Movie {
+	visible := []*models.Movie{}
+	for _, m := range movies {
+		if m.Visible() {
+			visible = append(visible, m)
+		}
+	}
+	return visible
 }
 
 func (b *backend) GetMovie(id int) *models.Movie {
@@ -216,97 +204,54 @@ func (b *backend) doAutofill(links []*models.Link, user *models.User, remarks st
 
 	sourcelink := links[0]
 
-	results := []string{} //nolint:ineffassign,staticcheck
-
-	if sourcelink.Type == "MyAnimeList" {
-		b.l.Debug("MAL link")
-
-		apiResults, err := b.autofillJikan(sourcelink.Url)
-
-		if err != nil {
-			b.l.Error(err.Error())
-			return -1, err, nil
-		}
-
-		var title string
-
-		if len(apiResults) != 6 {
-			b.l.Error("Jikan API results have an unexpected length, expected 6 got %v", len(apiResults))
-			return -1, fmt.Errorf("API autofill did not return enough data, contact the server administrator"), nil
-		} else {
-			title = apiResults[0]
-		}
-
-		exists, err := b.CheckMovieExists(title)
-		if err != nil {
-			b.l.Error(err.Error())
-			return -1, fmt.Errorf("Something went wrong :C"), nil
-		}
-
-		if exists {
-			b.l.Debug("Movie already exists")
-			return -1, nil, fmt.Errorf("Movie already exists in database")
-		}
-
-		results = apiResults
-	} else if sourcelink.Type == "IMDb" {
-		b.l.Debug("IMDB link")
-
-		apiResults, err := b.autofillTmdb(sourcelink.Url)
-
-		if err != nil {
-			b.l.Error(err.Error())
-			return -1, err, nil
-		}
-
-		var title string
+	def, extId := matchProvider(sourcelink.Url)
+	if def == nil {
+		b.autofillLog.Debug("no provider for link %s", sourcelink.Url)
+		return -1, fmt.Errorf("To use autofill the first link has to be one of: %s", strings.Join(providerNames(), ", ")), nil
+	}
+	b.autofillLog.Debug("%s link", def.Name)
 
-		if len(apiResults) != 6 {
-			b.l.Error("Tmdb API results have an unexpected length, expected 6 got %v", len(apiResults))
-			return -1, fmt.Errorf("API autofill did not return enough data, did you input a link to a series?"), nil
-		} else {
-			title = apiResults[0]
-		}
+	provider, err := def.New(b)
+	if err != nil {
+		return -1, err, nil
+	}
 
-		exists, err := b.CheckMovieExists(title)
-		if err != nil {
-			b.l.Error(err.Error())
-			return -1, fmt.Errorf("Something went wrong :C"), nil
-		}
+	meta, err := fetchMetadata(def, provider, extId)
+	if err != nil {
+		b.autofillLog.Debug("Error while accessing %s API: %v", def.Name, err)
+		return -1, fmt.Errorf("Could not complete autofill, contact your site administrator\n Error: %s", err.Error()), nil
+	}
 
-		if exists {
-			b.l.Debug("Movie already exists")
-			return -1, nil, fmt.Errorf("Movie already exists in database")
-		}
+	exists, err := b.CheckMovieExists(meta.Title)
+	if err != nil {
+		b.autofillLog.Error(err.Error())
+		return -1, fmt.Errorf("Something went wrong :C"), nil
+	}
 
-		results = apiResults
-	} else {
-		b.l.Debug("no link")
-		return -1, fmt.Errorf("To use autofill an imdb or myanimelist link as first link is required"), nil
+	if exists {
+		b.autofillLog.Debug("Movie already exists")
+		return -1, nil, fmt.Errorf("Movie already exists in database")
 	}
 
 	movie := models.Movie{}
 
 	// Fill all the fields in the movie struct
-	movie.Name = results[0]
-	movie.Description = results[1]
-	movie.Poster = results[2]
-	movie.Duration = results[3]
-
-	rating, err := strconv.ParseFloat(results[4], 32)
-	if err != nil {
-		b.l.Error("[AddMovie] Error converting string to float, defaulting to a rating of 0")
-		movie.Rating = 0.0
-	} else {
-		movie.Rating = float32(rating)
+	movie.Name = meta.Title
+	movie.OriginalTitle = meta.OriginalTitle
+	movie.Description = meta.Description
+	movie.Poster = b.downloadPoster(meta.PosterUrl)
+	if movie.Poster != defaultPosterKey {
+		movie.PosterSource = meta.PosterUrl
 	}
+	movie.Duration = meta.Duration
+	movie.Rating = meta.Rating
 
 	movie.Remarks = remarks
 
 	for _, link := range links {
-		id, err := b.data.AddLink(link)
+		id, err := b.AddLink(link)
 		if err != nil {
-			b.l.Debug("[AddMovie] link error: %v", err)
+			b.autofillLog.Debug("[AddMovie] link error: %v", err)
 		}
 		link.Id = id
 	}
@@ -315,16 +260,12 @@ func (b *backend) doAutofill(links []*models.Link, user *models.User, remarks st
 	movie.AddedBy = user
 
 	tags := []*models.Tag{}
-	for _, tagStr := range strings.Split(results[5], ",") {
-		tag := &models.Tag{
-			Name: tagStr,
-		}
-
-		id, err := b.data.AddTag(tag)
+	for _, tagStr := range meta.Tags {
+		tag, err := b.findOrAddTag(tagStr)
 		if err != nil {
-			b.l.Debug("[AddMovie] duplicate tag: %v", tagStr)
+			b.autofillLog.Debug("[AddMovie] tag error: %v", err)
+			continue
 		}
-		tag.Id = id
 
 		tags = append(tags, tag)
 	}
@@ -336,119 +277,30 @@ func (b *backend) doAutofill(links []*models.Link, user *models.User, remarks st
 	return id, err, nil
 }
 
-var re_jikanToken = regexp.MustCompile(`[^\/]*\/anime\/([0-9]+)`)
-
-func (b *backend) autofillJikan(sourcelink string) ([]string, error) {
-
-	jikanEnabled, err := b.GetJikanEnabled()
-	if err != nil {
-		b.l.Debug(err.Error())
-		return nil, fmt.Errorf("Something went wrong :C")
-	}
-
-	if !jikanEnabled {
-		return nil, fmt.Errorf("Jikan API usage was not enabled by the site administrator")
-	}
-
-	// Get Data from MAL (jikan api)
-	match := re_jikanToken.FindStringSubmatch(sourcelink)
-	var id string
-	if len(match) < 2 {
-		b.l.Debug("Regex match didn't find the anime id in %v", sourcelink)
-		return nil, fmt.Errorf("Could not retrive anime id from provided link, did you input a manga link?")
-	}
-	id = match[1]
-
-	bannedTypes, err := b.GetJikanBannedTypes()
-
-	if err != nil {
-		b.l.Debug("Error while retriving config value 'JikanBannedTypes':\n %v", err)
-		return nil, fmt.Errorf("Something went wrong :C")
-	}
-
-	maxEpisodes, err := b.GetJikanMaxEpisodes()
-
-	if err != nil {
-		b.l.Debug("Error while retriving config value 'JikanMaxEpisodes':\n %v", err)
-		return nil, fmt.Errorf("Something went wrong :C")
-	}
-
-	maxDuration, err := b.GetMaxDuration()
-
-	if err != nil {
-		b.l.Debug("Error while retriving config value 'MaxMultEpLength':\n %v", err)
-		return nil, fmt.Errorf("Something went wrong :C")
-	}
-
-	uploadlimit, err := b.GetMaxUploadlimit()
-
-	if err != nil {
-		b.l.Debug("Error while retriving config value 'MaxUploadLimit':\n %v", err)
-		return nil, fmt.Errorf("Something went wrong :C")
-	}
-
-	sourceAPI := jikan{id: id, l: b.l, excludedTypes: bannedTypes, maxEpisodes: maxEpisodes, maxDuration: maxDuration, uploadlimit: uploadlimit}
-
-	// Request data from API
-	results, err := getMovieData(&sourceAPI)
-
-	if err != nil {
-		b.l.Debug("Error while accessing Jikan API: %v", err)
-		return nil, fmt.Errorf("Could not complete autofill, contact your site administrator\n Error: %s", err.Error())
-	}
-
-	return results, nil
-}
-
-var re_tmdbToken = regexp.MustCompile(`[^\/]*\/title\/(tt[0-9]*)`)
-
-func (b *backend) autofillTmdb(sourcelink string) ([]string, error) {
-
-	tmdbEnabled, err := b.GetTmdbEnabled()
-	if err != nil {
-		b.l.Error(err.Error())
-		return nil, fmt.Errorf("Something went wrong :C")
+// downloadPoster downloads and saves the poster at url.  The default poster
+// is returned if there is no poster or the download fails.
+func (b *backend) downloadPoster(url string) string {
+	if url == "" {
+		return defaultPosterKey
 	}
 
-	if !tmdbEnabled {
-		b.l.Debug("Aborting Tmdb autofill since it is not enabled")
-		return nil, fmt.Errorf("Tmdb API usage was not enabled by the site administrator")
-	}
-
-	// Retrieve token from database
-	token, err := b.GetTmdbToken()
-	if err != nil || token == "" {
-		b.l.Debug("Aborting Tmdb autofill since no token was found, its either empty or was never set")
-		return nil, fmt.Errorf("The Tmdb integration is not configured correctly, contact the site administrator")
-	}
-	// get the movie id
-	match := re_tmdbToken.FindStringSubmatch(sourcelink)
-	var id string
-	if len(match) < 2 {
-		b.l.Debug("Regex match didn't find the movie id in %v", sourcelink)
-		return nil, fmt.Errorf("Could not retrive movie information from the first provided link")
-	}
-
-	id = match[1]
-
 	uploadlimit, err := b.GetMaxUploadlimit()
-
 	if err != nil {
 		b.l.Debug("Error while retriving config value 'MaxUploadLimit':\n %v", err)
-		return nil, fmt.Errorf("Something went wrong :C")
+		return defaultPosterKey
 	}
 
-	sourceAPI := tmdb{id: id, token: token, l: b.l, uploadlimit: uploadlimit}
-
-	// Request data from API
-	results, err := getMovieData(&sourceAPI)
-
-	if err != nil {
-		b.l.Debug("Error while accessing Tmdb API: %v", err)
-		return nil, fmt.Errorf("Could not complete autofill, contact your site administrator\n Error: %s", err.Error())
+	data, err := fetchPoster(b.client, url, uploadlimit)
+	if err == nil {
+		var key string
+		if key, err = b.savePoster(data); err == nil {
+			b.l.Debug("poster key: %s", key)
+			return key
+		}
 	}
 
-	return results, nil
+	b.l.Error("Error while downloading poster, using unknown.jpg: %v", err)
+	return defaultPosterKey
 }
 
 func (b *backend) doFormfill(validatedForm map[string]*InputField, user *models.User, links []*models.Link, file multipart.File, fileHeader *multipart.FileHeader) (int, error) {
@@ -461,14 +313,14 @@ func (b *backend) doFormfill(validatedForm map[string]*InputField, user *models.
 	movie.Links = links
 
 	if file != nil && fileHeader != nil {
-		path, err := b.UploadFile(file, fileHeader, validatedForm["Title"].Value)
+		key, err := b.UploadFile(file, fileHeader)
 		if err != nil {
 			b.l.Debug("Upload failed: %v", err)
 			return -1, err
 		}
-		movie.Poster = path
+		movie.Poster = key
 	} else {
-		movie.Poster = "posters/unknown.jpg"
+		movie.Poster = defaultPosterKey
 	}
 
 	movie.AddedBy = user
@@ -476,8 +328,9 @@ func (b *backend) doFormfill(validatedForm map[string]*InputField, user *models.
 	return b.AddMovieToDB(&movie)
 }
 
-func (b *backend) UploadFile(file multipart.File, fileHeader *multipart.FileHeader, name string) (string, error) {
+func (b *backend) UploadFile(file multipart.File, fileHeader *multipart.FileHeader) (string, error) {
 	b.l.Debug("[uploadFile] Start")
+	defer file.Close()
 
 	uploadlimit, err := b.GetMaxUploadlimit()
 	if err != nil {
@@ -491,35 +344,40 @@ func (b *backend) UploadFile(file multipart.File, fileHeader *multipart.FileHead
 
 	b.l.Info("Uploaded File: %v - Size %v", fileHeader.Filename, fileHeader.Size)
 
-	defer file.Close()
-
-	tempFile, err := ioutil.TempFile("posters", name+"-*.png")
-
+	data, err := readPoster(file, uploadlimit)
 	if err != nil {
-		return "", fmt.Errorf("Error while saving file to disk: %v", err)
+		return "", err
 	}
-	defer tempFile.Close()
-
-	fileBytes, err := ioutil.ReadAll(file)
 
+	key, err := b.savePoster(data)
 	if err != nil {
 		return "", err
 	}
 
-	written, err := tempFile.Write(fileBytes)
-	if err != nil || written != len(fileBytes) {
-		return "", fmt.Errorf("Error while saving file to disk: %v", err)
-	}
-
-	b.l.Debug("[uploadFile] Filename: %v", tempFile.Name())
-
-	return tempFile.Name(), nil
+	b.l.Debug("[uploadFile] Poster key: %v", key)
+	return key, nil
 }
 
 func (b *backend) UpdateMovie(movie *models.Movie) error {
-	return b.data.UpdateMovie(movie)
+	if err := b.data.UpdateMovie(movie); err != nil {
+		return err
+	}
+
+	b.search.add(movie)
+	return nil
 }
 
-func (b *backend) DeleteMovie(mid int) error {
-	return b.data.RemoveMovie(mid)
+func (b *backend) DeleteMovie(admin *models.User, mid int) error {
+	movie, err := b.data.GetMovie(mid)
+	if err != nil {
+		return err
+	}
+
+	if err := b.data.RemoveMovie(mid); err != nil {
+		return err
+	}
+	b.search.remove(mid)
+
+	b.audit(admin, models.AUDIT_MOVIE_REMOVE, "Movie", movie.Id, movie.Name, "", "")
+	return nil
 }
@@ -0,0 +1,54 @@
+package logic
+
+import (
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+// notify adds a notification for the given user.  Like audit(), a failure is
+// only logged.
+func (b *backend) notify(user *models.User, message, link string) {
+	if user == nil {
+		return
+	}
+
+	_, err := b.data.AddNotification(&models.Notification{
+		UserId:  user.Id,
+		Message: message,
+		Link:    link,
+		Created: time.Now(),
+	})
+	if err != nil {
+		b.l.Error("Unable to add notification for user %d: %v", user.Id, err)
+	}
+}
+
+// GetUserNotifications returns all notifications for the user, newest first.
+func (b *backend) GetUserNotifications(user *models.User) ([]*models.Notification, error) {
+	return b.data.GetUserNotifications(user.Id)
+}
+
+func (b *backend) GetUnreadNotificationCount(user *models.User) int {
+	if user == nil {
+		return 0
+	}
+
+	notifications, err := b.data.GetUserNotifications(user.Id)
+	if err != nil {
+		b.l.Error("Unable to get notifications for user %d: %v", user.Id, err)
+		return 0
+	}
+
+	count := 0
+	for _, n := range notifications {
+		if !n.Read {
+			count++
+		}
+	}
+	return count
+}
+
+func (b *backend) MarkNotificationsRead(user *models.User) error {
+	return b.data.MarkNotificationsRead(user.Id)
+}
@@ -0,0 +1,196 @@
+package logic
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/nfnt/resize"
+	"github.com/zorchenhimer/MoviePolls/models"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// Posters wider than this are scaled down.  Smaller posters are never
+	// scaled up.
+	posterWidth    = 720
+	thumbnailWidth = 240
+
+	posterQuality = 90
+
+	// Images with more pixels than this are rejected before they are
+	// decoded, so a small file can't claim a huge image.
+	maxPosterPixels = 40000000
+)
+
+// posterTypes are the content types that are accepted for posters, keyed by
+// the format name image.DecodeConfig returns.
+var posterTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+	"webp": "image/webp",
+}
+
+// processPoster checks that data is a PNG, JPEG, GIF or WebP image and
+// returns the full size and thumbnail renditions, both as JPEG.  Re-encoding
+// the image drops any metadata that was embedded in the original.
+func processPoster(data []byte) ([]byte, []byte, error) {
+	sniffed := http.DetectContentType(data)
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Poster is not a PNG, JPEG, GIF or WebP image")
+	}
+
+	// DetectContentType doesn't know WebP on older Go versions, so only
+	// compare it when it recognized an image.
+	if ct, ok := posterTypes[format]; !ok || (strings.HasPrefix(sniffed, "image/") && sniffed != ct) {
+		return nil, nil, fmt.Errorf("Poster is not a PNG, JPEG, GIF or WebP image")
+	}
+
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPosterPixels {
+		return nil, nil, fmt.Errorf("Poster dimensions of %dx%d are not allowed", config.Width, config.Height)
+	}
+
+	// GIFs only keep their first frame.
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to decode poster: %v", err)
+	}
+	img = flattenImage(img)
+
+	full, err := encodePoster(img, posterWidth)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	thumb, err := encodePoster(img, thumbnailWidth)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return full, thumb, nil
+}
+
+// flattenImage draws the image onto a white background.  JPEG has no
+// transparency, and transparent pixels would otherwise turn black.
+func flattenImage(img image.Image) image.Image {
+	bounds := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.ZP, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, bounds.Min, draw.Over)
+	return flat
+}
+
+func encodePoster(img image.Image, width uint) ([]byte, error) {
+	if uint(img.Bounds().Dx()) > width {
+		img = resize.Resize(width, 0, img, resize.Lanczos3)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: posterQuality}); err != nil {
+		return nil, fmt.Errorf("Unable to encode poster: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// savePoster processes the poster and saves both renditions to the poster
+// store.  Keys are the hash of the original image, so the same poster is
+// only stored once.  The key of the full size poster is returned.
+func (b *backend) savePoster(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:]) + ".jpg"
+	thumbKey := models.ThumbnailKey(key)
+
+	b.recent.usePoster(key)
+	b.recent.usePoster(thumbKey)
+
+	// An unused poster could be old enough for the cleanup.  If touching it
+	// fails, it is simply saved again.
+	if b.posters.Touch(key) == nil && b.posters.Touch(thumbKey) == nil {
+		return key, nil
+	}
+
+	full, thumb, err := processPoster(data)
+	if err != nil {
+		return "", err
+	}
+
+	if err := b.posters.Put(key, full, "image/jpeg"); err != nil {
+		return "", fmt.Errorf("Error while saving poster: %v", err)
+	}
+
+	if err := b.posters.Put(thumbKey, thumb, "image/jpeg"); err != nil {
+		b.posters.Delete(key)
+		return "", fmt.Errorf("Error while saving poster: %v", err)
+	}
+
+	return key, nil
+}
+
+// seedDefaultPoster copies the bundled placeholder poster into the store,
+// so it can be served like any other poster.
+func (b *backend) seedDefaultPoster() error {
+	if ok, err := b.posters.Exists(defaultPosterKey); ok || err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(defaultPosterFile)
+	if err != nil {
+		return fmt.Errorf("Unable to read default poster: %v", err)
+	}
+
+	return b.posters.Put(defaultPosterKey, data, "image/jpeg")
+}
+
+func (b *backend) GetPoster(key string) (io.ReadCloser, error) {
+	return b.posters.Get(key)
+}
+
+func (b *backend) GetPosterRedirect(key string) (string, error) {
+	return b.posters.RedirectUrl(key)
+}
+
+// readPoster reads at most limit bytes of a poster.
+func readPoster(r io.Reader, limit int) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > limit {
+		return nil, fmt.Errorf("Poster is too large - Max: %d bytes", limit)
+	}
+	return data, nil
+}
+
+// fetchPoster downloads the poster at url.
+func fetchPoster(client *http.Client, url string, limit int) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unable to download poster - Response Code: %v", resp.Status)
+	}
+
+	if resp.ContentLength > int64(limit) {
+		return nil, fmt.Errorf("Poster is too large - Max: %d, Requested: %d", limit, resp.ContentLength)
+	}
+
+	return readPoster(resp.Body, limit)
+}
@@ -0,0 +1,255 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+	"github.com/zorchenhimer/MoviePolls/storage"
+)
+
+func testImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func encodeTestImage(t *testing.T, format string, img image.Image) []byte {
+	buf := &bytes.Buffer{}
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(buf, img)
+	case "jpeg":
+		err = jpeg.Encode(buf, img, nil)
+	case "gif":
+		err = gif.Encode(buf, img, nil)
+	}
+
+	if err != nil {
+		t.Fatalf("Unable to encode %s test image: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func decodeJpeg(t *testing.T, data []byte) image.Image {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unable to decode rendition: %v", err)
+	}
+
+	if format != "jpeg" {
+		t.Fatalf("Rendition is %s, expected jpeg", format)
+	}
+	return img
+}
+
+func TestProcessPosterFormats(t *testing.T) {
+	webp, err := ioutil.ReadFile(filepath.Join("testdata", "poster.webp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	posters := map[string][]byte{
+		"png":  encodeTestImage(t, "png", testImage(100, 150)),
+		"jpeg": encodeTestImage(t, "jpeg", testImage(100, 150)),
+		"gif":  encodeTestImage(t, "gif", testImage(100, 150)),
+		"webp": webp,
+	}
+
+	for format, data := range posters {
+		full, thumb, err := processPoster(data)
+		if err != nil {
+			t.Errorf("processPoster() returned an error for %s: %v", format, err)
+			continue
+		}
+
+		decodeJpeg(t, full)
+		decodeJpeg(t, thumb)
+	}
+}
+
+func TestProcessPosterRenditions(t *testing.T) {
+	tests := []struct {
+		width, height int
+		full, thumb   int // expected widths
+	}{
+		{1000, 1500, posterWidth, thumbnailWidth},
+		{500, 750, 500, thumbnailWidth},
+		{100, 150, 100, 100}, // never scaled up
+	}
+
+	for _, tt := range tests {
+		full, thumb, err := processPoster(encodeTestImage(t, "png", testImage(tt.width, tt.height)))
+		if err != nil {
+			t.Errorf("processPoster() returned an error for %dx%d: %v", tt.width, tt.height, err)
+			continue
+		}
+
+		if w := decodeJpeg(t, full).Bounds().Dx(); w != tt.full {
+			t.Errorf("Full size poster of a %dx%d image is %d wide, expected %d", tt.width, tt.height, w, tt.full)
+		}
+
+		img := decodeJpeg(t, thumb)
+		if w := img.Bounds().Dx(); w != tt.thumb {
+			t.Errorf("Thumbnail of a %dx%d image is %d wide, expected %d", tt.width, tt.height, w, tt.thumb)
+		}
+
+		// The aspect ratio is kept.
+		if h := img.Bounds().Dy(); h != tt.thumb*tt.height/tt.width {
+			t.Errorf("Thumbnail of a %dx%d image is %d high, expected %d", tt.width, tt.height, h, tt.thumb*tt.height/tt.width)
+		}
+	}
+}
+
+func TestProcessPosterTransparency(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	full, _, err := processPoster(encodeTestImage(t, "png", img))
+	if err != nil {
+		t.Fatalf("processPoster() returned an error: %v", err)
+	}
+
+	r, g, b, _ := decodeJpeg(t, full).At(5, 5).RGBA()
+	if r < 0xf000 || g < 0xf000 || b < 0xf000 {
+		t.Errorf("Transparent pixel became %x,%x,%x, expected white", r, g, b)
+	}
+}
+
+// hugePng returns a valid PNG header that claims a width and height that are
+// far too large.
+func hugePng(t *testing.T) []byte {
+	data := encodeTestImage(t, "png", testImage(1, 1))
+
+	// The IHDR chunk follows the 8 byte signature.  Its data starts after
+	// the length and type, and its CRC covers the type and data.
+	binary.BigEndian.PutUint32(data[16:], 100000)
+	binary.BigEndian.PutUint32(data[20:], 100000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestProcessPosterRejects(t *testing.T) {
+	tests := map[string][]byte{
+		"text":      []byte("definitely not an image"),
+		"html":      []byte("<html><body><script>alert(1)</script></body></html>"),
+		"truncated": encodeTestImage(t, "png", testImage(50, 50))[:60],
+		"huge":      hugePng(t),
+		"empty":     []byte{},
+	}
+
+	for name, data := range tests {
+		if _, _, err := processPoster(data); err == nil {
+			t.Errorf("processPoster() accepted the %s poster", name)
+		}
+	}
+}
+
+func TestSavePosterDedup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mp-poster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	posters, err := storage.GetPosterStore("fs", dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &backend{posters: posters, recent: newRecentItems()}
+
+	data := encodeTestImage(t, "png", testImage(100, 150))
+	first, err := b.savePoster(data)
+	if err != nil {
+		t.Fatalf("savePoster() returned an error: %v", err)
+	}
+
+	second, err := b.savePoster(data)
+	if err != nil {
+		t.Fatalf("savePoster() returned an error: %v", err)
+	}
+
+	if first != second {
+		t.Errorf("The same poster was saved as %q and %q", first, second)
+	}
+
+	// Reusing an old poster makes it new again for the cleanup.
+	old := time.Now().Add(-2 * cleanupMinAge)
+	os.Chtimes(filepath.Join(dir, first), old, old)
+	if _, err := b.savePoster(data); err != nil {
+		t.Fatalf("savePoster() returned an error: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, first)); err != nil || !info.ModTime().After(old) {
+		t.Errorf("The reused poster wasn't touched")
+	}
+
+	if !storage.ValidKey(first) || !strings.HasSuffix(first, ".jpg") {
+		t.Errorf("Unexpected poster key %q", first)
+	}
+
+	if thumb := (models.Movie{Poster: first}).Thumbnail(); thumb == first {
+		t.Errorf("Movie has no thumbnail")
+	} else if ok, _ := posters.Exists(thumb); !ok {
+		t.Errorf("Thumbnail %q was not saved", thumb)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 2 {
+		t.Errorf("Expected a poster and a thumbnail, found %d files", len(files))
+	}
+
+	other, err := b.savePoster(encodeTestImage(t, "png", testImage(120, 150)))
+	if err != nil {
+		t.Fatalf("savePoster() returned an error: %v", err)
+	}
+
+	if other == first {
+		t.Errorf("Different posters were saved to the same key")
+	}
+}
+
+func TestFetchPoster(t *testing.T) {
+	data := encodeTestImage(t, "jpeg", testImage(100, 150))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/poster.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	fetched, err := fetchPoster(srv.Client(), srv.URL+"/poster.jpg", len(data))
+	if err != nil {
+		t.Fatalf("fetchPoster() returned an error: %v", err)
+	}
+
+	if !bytes.Equal(fetched, data) {
+		t.Errorf("fetchPoster() returned different data")
+	}
+
+	if _, err := fetchPoster(srv.Client(), srv.URL+"/poster.jpg", len(data)-1); err == nil {
+		t.Errorf("Expected an error for a poster over the limit")
+	}
+
+	if _, err := fetchPoster(srv.Client(), srv.URL+"/missing.jpg", len(data)); err == nil {
+		t.Errorf("Expected an error when the poster is missing")
+	}
+}
@@ -0,0 +1,133 @@
+package logic
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerProvider(providerDef{
+		Name:     "AniList",
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`anilist\.co/anime/([0-9]+)`)},
+		Enabled:  (*backend).GetAnilistEnabled,
+		New:      (*backend).newAnilistProvider,
+	})
+}
+
+// anilistProvider looks up AniList links using the AniList GraphQL API.
+type anilistProvider struct {
+	client *http.Client
+	apiUrl string
+	limits animeLimits
+}
+
+const anilistQuery = `query ($id: Int) {
+  Media(id: $id, type: ANIME) {
+    title { romaji english native }
+    description(asHtml: false)
+    coverImage { large }
+    format
+    episodes
+    duration
+    averageScore
+    genres
+    startDate { year }
+  }
+}`
+
+type anilistResponse struct {
+	Data struct {
+		Media *struct {
+			Title struct {
+				Romaji  string `json:"romaji"`
+				English string `json:"english"`
+				Native  string `json:"native"`
+			} `json:"title"`
+			Description string `json:"description"`
+			CoverImage  struct {
+				Large string `json:"large"`
+			} `json:"coverImage"`
+			Format       string   `json:"format"`
+			Episodes     *int     `json:"episodes"`
+			Duration     int      `json:"duration"`
+			AverageScore int      `json:"averageScore"`
+			Genres       []string `json:"genres"`
+		} `json:"Media"`
+	} `json:"data"`
+}
+
+func (b *backend) newAnilistProvider() (MetadataProvider, error) {
+	enabled, err := b.GetAnilistEnabled()
+	if err != nil {
+		b.autofillLog.Error(err.Error())
+		return nil, fmt.Errorf("Something went wrong :C")
+	}
+
+	if !enabled {
+		return nil, fmt.Errorf("AniList API usage was not enabled by the site administrator")
+	}
+
+	limits, err := b.getAnimeLimits()
+	if err != nil {
+		return nil, err
+	}
+
+	return &anilistProvider{
+		client: b.client,
+		apiUrl: "https://graphql.anilist.co",
+		limits: limits,
+	}, nil
+}
+
+func (a *anilistProvider) Fetch(id string) (*MovieMetadata, error) {
+	mediaId, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid AniList ID %q", id)
+	}
+
+	body := map[string]interface{}{
+		"query":     anilistQuery,
+		"variables": map[string]int{"id": mediaId},
+	}
+
+	resp := anilistResponse{}
+	if err := postJson(a.client, a.apiUrl, body, &resp); err != nil {
+		return nil, err
+	}
+
+	media := resp.Data.Media
+	if media == nil || media.Title.Romaji == "" {
+		return nil, fmt.Errorf("No title returned from API")
+	}
+
+	// Formats are things like TV, TV_SHORT, MOVIE and MUSIC.
+	if err := a.limits.check(strings.Replace(media.Format, "_", " ", -1), media.Episodes, media.Duration); err != nil {
+		return nil, err
+	}
+
+	meta := &MovieMetadata{
+		Title:         media.Title.Romaji,
+		OriginalTitle: media.Title.Native,
+		Description:   stripHtml(media.Description),
+		PosterUrl:     media.CoverImage.Large,
+		Rating:        float32(media.AverageScore) / 10,
+		Tags:          append([]string{"AniList"}, media.Genres...),
+	}
+
+	if media.Title.English != "" && media.Title.English != media.Title.Romaji {
+		meta.Title += " (" + media.Title.English + ")"
+	}
+
+	if media.Duration > 0 {
+		if *media.Episodes > 1 {
+			meta.Duration = fmt.Sprintf("%d min per ep", media.Duration)
+		} else {
+			meta.Duration = formatDuration(media.Duration)
+		}
+	}
+
+	return meta, nil
+}
@@ -0,0 +1,69 @@
+package logic
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestAnilist(url string) *anilistProvider {
+	return &anilistProvider{
+		client: http.DefaultClient,
+		apiUrl: url,
+		limits: animeLimits{
+			excludedTypes: []string{"TV", "music"},
+			maxEpisodes:   1,
+			maxDuration:   120,
+		},
+	}
+}
+
+func TestAnilistFetch(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/": {File: "anilist_media_1.json"},
+	})
+	defer srv.Close()
+
+	meta, err := newTestAnilist(srv.URL + "/").Fetch("5")
+	if err != nil {
+		t.Fatalf("Fetch() returned an error: %v", err)
+	}
+
+	expected := &MovieMetadata{
+		Title:       "Cowboy Bebop: Tengoku no Tobira (Cowboy Bebop: The Movie - Knockin' on Heaven's Door)",
+		Description: "Another day, another bounty—such is the life of the often unlucky crew of the Bebop.\n(Source: Sony Pictures)",
+		PosterUrl:   "https://s4.anilist.co/file/anilistcdn/media/anime/cover/medium/bx5-NozHwXWdNLCz.jpg",
+		Duration:    "1 hr 55 min",
+		Rating:      8.2,
+		Tags:        []string{"AniList", "Action", "Drama", "Mystery", "Sci-Fi"},
+	}
+
+	if !reflect.DeepEqual(meta, expected) {
+		t.Errorf("Fetch() returned %#v\nexpected %#v", meta, expected)
+	}
+}
+
+func TestAnilistFetchErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file fixture
+		id   string
+		err  string
+	}{
+		{"banned type", fixture{File: "anilist_media_tv.json"}, "1", "type TV was banned"},
+		{"not found", fixture{File: "anilist_not_found.json", Status: http.StatusNotFound}, "999999", "404"},
+		{"invalid id", fixture{File: "anilist_media_1.json"}, "abc", "Invalid AniList ID"},
+	}
+
+	for _, tt := range tests {
+		srv := newFixtureServer(t, map[string]fixture{"/": tt.file})
+
+		_, err := newTestAnilist(srv.URL + "/").Fetch(tt.id)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.err, err)
+		}
+
+		srv.Close()
+	}
+}
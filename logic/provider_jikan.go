@@ -0,0 +1,180 @@
+package logic
+
+import (
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"strconv"
+
+	"github.com/zorchenhimer/MoviePolls/logger"
+)
+
+func init() {
+	registerProvider(providerDef{
+		Name:     "MyAnimeList",
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`myanimelist\.net/anime/([0-9]+)`)},
+		Enabled:  (*backend).GetJikanEnabled,
+		New:      (*backend).newJikanProvider,
+	})
+}
+
+// jikanProvider looks up MyAnimeList links using the Jikan API.
+type jikanProvider struct {
+	l      *logger.Logger
+	client *http.Client
+	apiUrl string
+	limits animeLimits
+}
+
+type jikanAnime struct {
+	Title         string  `json:"title"`
+	TitleEnglish  string  `json:"title_english"`
+	TitleJapanese string  `json:"title_japanese"`
+	Synopsis      string  `json:"synopsis"`
+	ImageUrl      string  `json:"image_url"`
+	Type          string  `json:"type"`
+	Episodes      *int    `json:"episodes"`
+	Duration      string  `json:"duration"`
+	Score         float32 `json:"score"`
+	Genres        []struct {
+		Name string `json:"name"`
+	} `json:"genres"`
+}
+
+type jikanSearchResult struct {
+	Results []struct {
+		MalId     int    `json:"mal_id"`
+		ImageUrl  string `json:"image_url"`
+		Title     string `json:"title"`
+		Type      string `json:"type"`
+		StartDate string `json:"start_date"`
+	} `json:"results"`
+}
+
+var (
+	re_durationHours = regexp.MustCompile(`([0-9]{1,3}) hr`)
+	re_duration      = regexp.MustCompile(`([0-9]{1,3}) min`)
+)
+
+func (b *backend) newJikanProvider() (MetadataProvider, error) {
+	jikanEnabled, err := b.GetJikanEnabled()
+	if err != nil {
+		b.autofillLog.Debug(err.Error())
+		return nil, fmt.Errorf("Something went wrong :C")
+	}
+
+	if !jikanEnabled {
+		return nil, fmt.Errorf("Jikan API usage was not enabled by the site administrator")
+	}
+
+	limits, err := b.getAnimeLimits()
+	if err != nil {
+		return nil, err
+	}
+
+	return &jikanProvider{
+		l:      b.autofillLog,
+		client: b.client,
+		apiUrl: "https://api.jikan.moe/v3",
+		limits: limits,
+	}, nil
+}
+
+func (j *jikanProvider) Fetch(id string) (*MovieMetadata, error) {
+	anime := jikanAnime{}
+	if err := getJson(j.client, j.apiUrl+"/anime/"+id, &anime); err != nil {
+		return nil, err
+	}
+
+	length, err := j.episodeLength(anime.Duration)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := j.limits.check(anime.Type, anime.Episodes, length); err != nil {
+		return nil, err
+	}
+
+	if anime.Title == "" {
+		return nil, fmt.Errorf("No title returned from API")
+	}
+
+	meta := &MovieMetadata{
+		Title:         anime.Title,
+		OriginalTitle: anime.TitleJapanese,
+		Description:   anime.Synopsis,
+		PosterUrl:     anime.ImageUrl,
+		Duration:      anime.Duration,
+		Rating:        anime.Score,
+		Tags:          []string{"MAL"},
+	}
+
+	if anime.TitleEnglish != "" && anime.TitleEnglish != anime.Title {
+		meta.Title += " (" + anime.TitleEnglish + ")"
+	}
+
+	for _, genre := range anime.Genres {
+		meta.Tags = append(meta.Tags, genre.Name)
+	}
+
+	return meta, nil
+}
+
+// episodeLength parses a duration like "1 hr 55 min" or "24 min per ep" into
+// minutes.  Zero is returned if the duration is unknown.
+func (j *jikanProvider) episodeLength(duration string) (int, error) {
+	if duration == "" || duration == "Unknown" {
+		return 0, nil
+	}
+
+	match := re_duration.FindStringSubmatch(duration)
+	if len(match) < 2 {
+		j.l.Error("Could not detect episode duration in %q", duration)
+		return 0, fmt.Errorf("The episode duration of this anime has not been published or has an unexpected format. Therefore this anime can not be added.")
+	}
+
+	minutes, err := strconv.Atoi(match[1])
+	if err != nil {
+		j.l.Error("Could not convert duration %v to int", match[1])
+		return 0, fmt.Errorf("The episode duration of this anime has not been published or has an unexpected format. Therefore this anime can not be added.")
+	}
+
+	if match = re_durationHours.FindStringSubmatch(duration); len(match) == 2 {
+		hours, _ := strconv.Atoi(match[1])
+		minutes += hours * 60
+	}
+
+	return minutes, nil
+}
+
+// Search returns the anime matching the query.  Types banned by the site
+// administrator are left out.
+func (j *jikanProvider) Search(query string) ([]*SearchResult, error) {
+	found := jikanSearchResult{}
+	url := fmt.Sprintf("%s/search/anime?q=%s&limit=%d", j.apiUrl, neturl.QueryEscape(query), maxSearchResults)
+	if err := getJson(j.client, url, &found); err != nil {
+		return nil, err
+	}
+
+	results := []*SearchResult{}
+	for _, anime := range found.Results {
+		if j.limits.bannedType(anime.Type) {
+			continue
+		}
+
+		res := &SearchResult{
+			Title:     anime.Title,
+			PosterUrl: anime.ImageUrl,
+			Link:      fmt.Sprintf("https://myanimelist.net/anime/%d", anime.MalId),
+		}
+
+		if len(anime.StartDate) >= 4 {
+			res.Year = anime.StartDate[0:4]
+		}
+
+		results = append(results, res)
+	}
+
+	return results, nil
+}
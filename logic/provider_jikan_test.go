@@ -0,0 +1,111 @@
+package logic
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zorchenhimer/MoviePolls/logger"
+)
+
+func newTestJikan(url string) *jikanProvider {
+	return &jikanProvider{
+		l:      &logger.Logger{},
+		client: http.DefaultClient,
+		apiUrl: url,
+		limits: animeLimits{
+			excludedTypes: []string{"TV", "music"},
+			maxEpisodes:   1,
+			maxDuration:   120,
+		},
+	}
+}
+
+var jikanFixtures = map[string]fixture{
+	"/anime/1":  {File: "jikan_anime_1.json"},
+	"/anime/5":  {File: "jikan_anime_5.json"},
+	"/anime/21": {File: "jikan_anime_21.json"},
+}
+
+func TestJikanFetch(t *testing.T) {
+	srv := newFixtureServer(t, jikanFixtures)
+	defer srv.Close()
+
+	meta, err := newTestJikan(srv.URL).Fetch("5")
+	if err != nil {
+		t.Fatalf("Fetch() returned an error: %v", err)
+	}
+
+	expected := &MovieMetadata{
+		Title:         "Cowboy Bebop: Tengoku no Tobira (Cowboy Bebop: The Movie)",
+		OriginalTitle: "カウボーイビバップ 天国の扉",
+		Description:   "Another day, another bounty—such is the life of the often unlucky crew of the Bebop.",
+		PosterUrl:     "https://cdn.myanimelist.net/images/anime/1439/93480.jpg",
+		Duration:      "1 hr 55 min",
+		Rating:        8.39,
+		Tags:          []string{"MAL", "Action", "Drama", "Sci-Fi"},
+	}
+
+	if !reflect.DeepEqual(meta, expected) {
+		t.Errorf("Fetch() returned %#v\nexpected %#v", meta, expected)
+	}
+}
+
+func TestJikanLimits(t *testing.T) {
+	srv := newFixtureServer(t, jikanFixtures)
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		id     string
+		modify func(j *jikanProvider)
+		err    string
+	}{
+		{"banned type", "1", nil, "type TV was banned"},
+		{"too many episodes", "1", func(j *jikanProvider) { j.limits.excludedTypes = nil }, "too many (26) episodes"},
+		{"unknown episodes", "21", func(j *jikanProvider) { j.limits.excludedTypes = nil }, "episode count"},
+		{"too long", "5", func(j *jikanProvider) { j.limits.maxDuration = 100 }, "longer than the maximum duration"},
+		{"not found", "404", nil, "404"},
+	}
+
+	for _, tt := range tests {
+		j := newTestJikan(srv.URL)
+		if tt.modify != nil {
+			tt.modify(j)
+		}
+
+		_, err := j.Fetch(tt.id)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestJikanSearch(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/search/anime": {File: "jikan_search_bebop.json"},
+	})
+	defer srv.Close()
+
+	results, err := newTestJikan(srv.URL).Search("cowboy bebop")
+	if err != nil {
+		t.Fatalf("Search() returned an error: %v", err)
+	}
+
+	// The TV series is left out because the type is banned.
+	expected := []*SearchResult{
+		{Title: "Cowboy Bebop: Tengoku no Tobira", Year: "2001", PosterUrl: "https://cdn.myanimelist.net/images/anime/1439/93480.jpg?s=4c3e8a2b2d3c3b0d9e4f5a6b7c8d9e0f", Link: "https://myanimelist.net/anime/5"},
+		{Title: "Cowboy Bebop: Ein no Natsuyasumi", PosterUrl: "https://cdn.myanimelist.net/images/anime/11/50307.jpg?s=1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d", Link: "https://myanimelist.net/anime/17205"},
+	}
+
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Search() returned %v\nexpected %v", results, expected)
+	}
+
+	for _, res := range results {
+		if def, _ := matchProvider(res.Link); def == nil || def.Name != "MyAnimeList" {
+			t.Errorf("Result link %q is not handled by the Jikan provider", res.Link)
+		}
+	}
+}
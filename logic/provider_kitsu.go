@@ -0,0 +1,151 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+func init() {
+	registerProvider(providerDef{
+		Name:     "Kitsu",
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`kitsu\.(?:io|app)/anime/([a-z0-9-]+)`)},
+		Enabled:  (*backend).GetKitsuEnabled,
+		New:      (*backend).newKitsuProvider,
+	})
+}
+
+// kitsuProvider looks up Kitsu links using the Kitsu JSON:API.  Links can
+// contain either the numeric ID or the slug of an anime.
+type kitsuProvider struct {
+	client *http.Client
+	apiUrl string
+	limits animeLimits
+}
+
+type kitsuAnime struct {
+	Attributes struct {
+		CanonicalTitle string `json:"canonicalTitle"`
+		Titles         struct {
+			En   string `json:"en"`
+			JaJp string `json:"ja_jp"`
+		} `json:"titles"`
+		Synopsis    string `json:"synopsis"`
+		PosterImage struct {
+			Large string `json:"large"`
+		} `json:"posterImage"`
+		Subtype       string `json:"subtype"`
+		EpisodeCount  *int   `json:"episodeCount"`
+		EpisodeLength int    `json:"episodeLength"`
+		AverageRating string `json:"averageRating"` // percentage
+	} `json:"attributes"`
+}
+
+type kitsuResponse struct {
+	// A single object when requested by ID, a list when filtered by slug.
+	Data     json.RawMessage `json:"data"`
+	Included []struct {
+		Type       string `json:"type"`
+		Attributes struct {
+			Title string `json:"title"`
+		} `json:"attributes"`
+	} `json:"included"`
+}
+
+var re_numeric = regexp.MustCompile(`^[0-9]+$`)
+
+func (b *backend) newKitsuProvider() (MetadataProvider, error) {
+	enabled, err := b.GetKitsuEnabled()
+	if err != nil {
+		b.autofillLog.Error(err.Error())
+		return nil, fmt.Errorf("Something went wrong :C")
+	}
+
+	if !enabled {
+		return nil, fmt.Errorf("Kitsu API usage was not enabled by the site administrator")
+	}
+
+	limits, err := b.getAnimeLimits()
+	if err != nil {
+		return nil, err
+	}
+
+	return &kitsuProvider{
+		client: b.client,
+		apiUrl: "https://kitsu.io/api/edge",
+		limits: limits,
+	}, nil
+}
+
+func (k *kitsuProvider) Fetch(id string) (*MovieMetadata, error) {
+	var reqUrl string
+	if re_numeric.MatchString(id) {
+		reqUrl = fmt.Sprintf("%s/anime/%s?include=categories", k.apiUrl, id)
+	} else {
+		reqUrl = fmt.Sprintf("%s/anime?filter[slug]=%s&include=categories", k.apiUrl, url.QueryEscape(id))
+	}
+
+	resp := kitsuResponse{}
+	if err := getJson(k.client, reqUrl, &resp); err != nil {
+		return nil, err
+	}
+
+	anime := kitsuAnime{}
+	if len(resp.Data) > 0 && resp.Data[0] == '[' {
+		list := []kitsuAnime{}
+		if err := json.Unmarshal(resp.Data, &list); err != nil {
+			return nil, fmt.Errorf("Error while unmarshalling json response: %v", err)
+		}
+
+		if len(list) == 0 {
+			return nil, fmt.Errorf("No anime found for %q", id)
+		}
+		anime = list[0]
+	} else if err := json.Unmarshal(resp.Data, &anime); err != nil {
+		return nil, fmt.Errorf("Error while unmarshalling json response: %v", err)
+	}
+
+	attr := anime.Attributes
+	if attr.CanonicalTitle == "" {
+		return nil, fmt.Errorf("No title returned from API")
+	}
+
+	if err := k.limits.check(attr.Subtype, attr.EpisodeCount, attr.EpisodeLength); err != nil {
+		return nil, err
+	}
+
+	meta := &MovieMetadata{
+		Title:         attr.CanonicalTitle,
+		OriginalTitle: attr.Titles.JaJp,
+		Description:   attr.Synopsis,
+		PosterUrl:     attr.PosterImage.Large,
+		Tags:          []string{"Kitsu"},
+	}
+
+	if attr.Titles.En != "" && attr.Titles.En != attr.CanonicalTitle {
+		meta.Title += " (" + attr.Titles.En + ")"
+	}
+
+	if rating, err := strconv.ParseFloat(attr.AverageRating, 64); err == nil {
+		meta.Rating = float32(rating / 10)
+	}
+
+	if attr.EpisodeLength > 0 {
+		if *attr.EpisodeCount > 1 {
+			meta.Duration = fmt.Sprintf("%d min per ep", attr.EpisodeLength)
+		} else {
+			meta.Duration = formatDuration(attr.EpisodeLength)
+		}
+	}
+
+	for _, inc := range resp.Included {
+		if inc.Type == "categories" {
+			meta.Tags = append(meta.Tags, inc.Attributes.Title)
+		}
+	}
+
+	return meta, nil
+}
@@ -0,0 +1,71 @@
+package logic
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestKitsu(url string) *kitsuProvider {
+	return &kitsuProvider{
+		client: http.DefaultClient,
+		apiUrl: url,
+		limits: animeLimits{
+			excludedTypes: []string{"TV", "music"},
+			maxEpisodes:   1,
+			maxDuration:   120,
+		},
+	}
+}
+
+func TestKitsuFetchSlug(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/anime": {File: "kitsu_anime_slug.json"},
+	})
+	defer srv.Close()
+
+	meta, err := newTestKitsu(srv.URL).Fetch("cowboy-bebop-tengoku-no-tobira")
+	if err != nil {
+		t.Fatalf("Fetch() returned an error: %v", err)
+	}
+
+	expected := &MovieMetadata{
+		Title:         "Cowboy Bebop: Tengoku no Tobira (Cowboy Bebop: The Movie)",
+		OriginalTitle: "カウボーイビバップ 天国の扉",
+		Description:   "Another day, another bounty—such is the life of the often unlucky crew of the Bebop.",
+		PosterUrl:     "https://media.kitsu.io/anime/poster_images/2/large.jpg",
+		Duration:      "1 hr 55 min",
+		Rating:        8.197,
+		Tags:          []string{"Kitsu", "Action", "Space"},
+	}
+
+	if !reflect.DeepEqual(meta, expected) {
+		t.Errorf("Fetch() returned %#v\nexpected %#v", meta, expected)
+	}
+}
+
+func TestKitsuFetchErrors(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/anime":   {File: "kitsu_anime_empty.json"},
+		"/anime/1": {File: "kitsu_anime_1.json"},
+	})
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		id   string
+		err  string
+	}{
+		{"banned type", "1", "type TV was banned"},
+		{"unknown slug", "not-an-anime", "No anime found"},
+		{"not found", "404", "404"},
+	}
+
+	for _, tt := range tests {
+		_, err := newTestKitsu(srv.URL).Fetch(tt.id)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
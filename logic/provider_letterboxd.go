@@ -0,0 +1,117 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+func init() {
+	registerProvider(providerDef{
+		Name:     "Letterboxd",
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`letterboxd\.com/(?:[A-Za-z0-9_]+/)?film/([a-z0-9-]+)`)},
+		Enabled:  (*backend).GetLetterboxdEnabled,
+		New:      (*backend).newLetterboxdProvider,
+	})
+}
+
+// letterboxdProvider reads the metadata from a film's page on Letterboxd.
+// Letterboxd has no public API, so this uses the JSON-LD block and meta tags
+// embedded in the page.
+type letterboxdProvider struct {
+	client  *http.Client
+	siteUrl string
+}
+
+type letterboxdFilm struct {
+	Name            string   `json:"name"`
+	Image           string   `json:"image"`
+	Genre           []string `json:"genre"`
+	AggregateRating struct {
+		RatingValue float32 `json:"ratingValue"`
+	} `json:"aggregateRating"`
+	ReleasedEvent []struct {
+		StartDate string `json:"startDate"`
+	} `json:"releasedEvent"`
+}
+
+var (
+	re_letterboxdJsonLd  = regexp.MustCompile(`(?s)<script type="application/ld\+json">\s*(?:/\* <!\[CDATA\[ \*/)?(.*?)(?:/\* \]\]> \*/)?\s*</script>`)
+	re_letterboxdDesc    = regexp.MustCompile(`<meta name="description" content="([^"]*)"`)
+	re_letterboxdRuntime = regexp.MustCompile(`([0-9]+)&nbsp;mins`)
+)
+
+func (b *backend) newLetterboxdProvider() (MetadataProvider, error) {
+	enabled, err := b.GetLetterboxdEnabled()
+	if err != nil {
+		b.autofillLog.Error(err.Error())
+		return nil, fmt.Errorf("Something went wrong :C")
+	}
+
+	if !enabled {
+		return nil, fmt.Errorf("Letterboxd autofill was not enabled by the site administrator")
+	}
+
+	return &letterboxdProvider{
+		client:  b.client,
+		siteUrl: "https://letterboxd.com",
+	}, nil
+}
+
+func (l *letterboxdProvider) Fetch(id string) (*MovieMetadata, error) {
+	resp, err := l.client.Get(l.siteUrl + "/film/" + id + "/")
+	if err != nil {
+		return nil, fmt.Errorf("Tried to access Letterboxd: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Tried to access Letterboxd - Response Code: %v", resp.Status)
+	}
+
+	page, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	match := re_letterboxdJsonLd.FindSubmatch(page)
+	if len(match) < 2 {
+		return nil, fmt.Errorf("Could not find the film information on the Letterboxd page")
+	}
+
+	film := letterboxdFilm{}
+	if err := json.Unmarshal(match[1], &film); err != nil {
+		return nil, fmt.Errorf("Error while unmarshalling Letterboxd film information: %v", err)
+	}
+
+	if film.Name == "" {
+		return nil, fmt.Errorf("No title found on the Letterboxd page")
+	}
+
+	meta := &MovieMetadata{
+		Title:     film.Name,
+		PosterUrl: film.Image,
+		// Letterboxd ratings are out of five stars.
+		Rating: film.AggregateRating.RatingValue * 2,
+		Tags:   append([]string{"Letterboxd"}, film.Genre...),
+	}
+
+	if len(film.ReleasedEvent) > 0 && len(film.ReleasedEvent[0].StartDate) >= 4 {
+		meta.Title += " (" + film.ReleasedEvent[0].StartDate[0:4] + ")"
+	}
+
+	if match = re_letterboxdDesc.FindSubmatch(page); len(match) == 2 {
+		meta.Description = html.UnescapeString(string(match[1]))
+	}
+
+	if match = re_letterboxdRuntime.FindSubmatch(page); len(match) == 2 {
+		runtime, _ := strconv.Atoi(string(match[1]))
+		meta.Duration = formatDuration(runtime)
+	}
+
+	return meta, nil
+}
@@ -0,0 +1,45 @@
+package logic
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLetterboxdFetch(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/film/the-matrix/": {File: "letterboxd_the-matrix.html"},
+	})
+	defer srv.Close()
+
+	l := &letterboxdProvider{client: http.DefaultClient, siteUrl: srv.URL}
+	meta, err := l.Fetch("the-matrix")
+	if err != nil {
+		t.Fatalf("Fetch() returned an error: %v", err)
+	}
+
+	expected := &MovieMetadata{
+		Title:       "The Matrix (1999)",
+		Description: "Set in the 22nd century, The Matrix tells the story of a computer hacker who joins a group of underground insurgents fighting the vast and powerful computers who now rule the earth.",
+		PosterUrl:   "https://a.ltrbxd.com/resized/film-poster/5/1/5/1/8/51518-the-matrix-0-230-0-345-crop.jpg",
+		Duration:    "2 hr 16 min",
+		Rating:      8.4,
+		Tags:        []string{"Letterboxd", "Action", "Science Fiction"},
+	}
+
+	if !reflect.DeepEqual(meta, expected) {
+		t.Errorf("Fetch() returned %#v\nexpected %#v", meta, expected)
+	}
+}
+
+func TestLetterboxdFetchNotFound(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{})
+	defer srv.Close()
+
+	l := &letterboxdProvider{client: http.DefaultClient, siteUrl: srv.URL}
+	_, err := l.Fetch("not-a-film")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected a 404 error, got %v", err)
+	}
+}
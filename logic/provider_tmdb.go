@@ -0,0 +1,161 @@
+package logic
+
+import (
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerProvider(providerDef{
+		Name: "IMDb",
+		Patterns: []*regexp.Regexp{
+			regexp.MustCompile(`imdb\.com/title/(tt[0-9]+)`),
+			regexp.MustCompile(`themoviedb\.org/movie/([0-9]+)`),
+		},
+		Enabled: (*backend).GetTmdbEnabled,
+		New:     (*backend).newTmdbProvider,
+	})
+}
+
+// tmdbProvider looks up IMDb and TMDB links using the TMDB API.  IDs are
+// either an IMDb ID ("tt0133093") or a TMDB movie ID ("603").
+type tmdbProvider struct {
+	client   *http.Client
+	apiUrl   string
+	imageUrl string
+	token    string
+}
+
+type tmdbFindResult struct {
+	MovieResults []struct {
+		Id int `json:"id"`
+	} `json:"movie_results"`
+}
+
+type tmdbSearchResult struct {
+	Results []struct {
+		Id          int    `json:"id"`
+		Title       string `json:"title"`
+		ReleaseDate string `json:"release_date"`
+		PosterPath  string `json:"poster_path"`
+	} `json:"results"`
+}
+
+type tmdbMovie struct {
+	Title         string  `json:"title"`
+	OriginalTitle string  `json:"original_title"`
+	ReleaseDate   string  `json:"release_date"`
+	Overview      string  `json:"overview"`
+	PosterPath    string  `json:"poster_path"`
+	Runtime       int     `json:"runtime"`
+	VoteAverage   float32 `json:"vote_average"`
+	Genres        []struct {
+		Name string `json:"name"`
+	} `json:"genres"`
+}
+
+func (b *backend) newTmdbProvider() (MetadataProvider, error) {
+	tmdbEnabled, err := b.GetTmdbEnabled()
+	if err != nil {
+		b.autofillLog.Error(err.Error())
+		return nil, fmt.Errorf("Something went wrong :C")
+	}
+
+	if !tmdbEnabled {
+		b.autofillLog.Debug("Aborting Tmdb autofill since it is not enabled")
+		return nil, fmt.Errorf("Tmdb API usage was not enabled by the site administrator")
+	}
+
+	// Retrieve token from database
+	token, err := b.GetTmdbToken()
+	if err != nil || token == "" {
+		b.autofillLog.Debug("Aborting Tmdb autofill since no token was found, its either empty or was never set")
+		return nil, fmt.Errorf("The Tmdb integration is not configured correctly, contact the site administrator")
+	}
+
+	return &tmdbProvider{
+		client:   b.client,
+		apiUrl:   "https://api.themoviedb.org/3",
+		imageUrl: "https://image.tmdb.org",
+		token:    token,
+	}, nil
+}
+
+func (t *tmdbProvider) Fetch(id string) (*MovieMetadata, error) {
+	if strings.HasPrefix(id, "tt") {
+		found := tmdbFindResult{}
+		url := fmt.Sprintf("%s/find/%s?api_key=%s&language=en-US&external_source=imdb_id", t.apiUrl, id, t.token)
+		if err := getJson(t.client, url, &found); err != nil {
+			return nil, fmt.Errorf("%v\nMaybe check your tmdb api token", err)
+		}
+
+		if len(found.MovieResults) == 0 {
+			return nil, fmt.Errorf("JSON Result did not return a movie, make sure the imdb link is for a movie")
+		}
+		id = strconv.Itoa(found.MovieResults[0].Id)
+	}
+
+	movie := tmdbMovie{}
+	url := fmt.Sprintf("%s/movie/%s?api_key=%s", t.apiUrl, id, t.token)
+	if err := getJson(t.client, url, &movie); err != nil {
+		return nil, fmt.Errorf("%v\nMaybe check your tmdb api token", err)
+	}
+
+	meta := &MovieMetadata{
+		Title:       movie.Title,
+		Description: movie.Overview,
+		Duration:    formatDuration(movie.Runtime),
+		Rating:      movie.VoteAverage,
+		Tags:        []string{"IMDB"},
+	}
+
+	if movie.OriginalTitle != movie.Title {
+		meta.OriginalTitle = movie.OriginalTitle
+	}
+
+	if len(movie.ReleaseDate) >= 4 {
+		meta.Title += " (" + movie.ReleaseDate[0:4] + ")"
+	}
+
+	if movie.PosterPath != "" {
+		meta.PosterUrl = t.imageUrl + "/t/p/original" + movie.PosterPath
+	}
+
+	for _, genre := range movie.Genres {
+		meta.Tags = append(meta.Tags, genre.Name)
+	}
+
+	return meta, nil
+}
+
+func (t *tmdbProvider) Search(query string) ([]*SearchResult, error) {
+	found := tmdbSearchResult{}
+	url := fmt.Sprintf("%s/search/movie?api_key=%s&language=en-US&query=%s", t.apiUrl, t.token, neturl.QueryEscape(query))
+	if err := getJson(t.client, url, &found); err != nil {
+		return nil, fmt.Errorf("%v\nMaybe check your tmdb api token", err)
+	}
+
+	results := []*SearchResult{}
+	for _, movie := range found.Results {
+		res := &SearchResult{
+			Title: movie.Title,
+			Link:  fmt.Sprintf("https://www.themoviedb.org/movie/%d", movie.Id),
+		}
+
+		if len(movie.ReleaseDate) >= 4 {
+			res.Year = movie.ReleaseDate[0:4]
+		}
+
+		if movie.PosterPath != "" {
+			res.PosterUrl = t.imageUrl + "/t/p/w92" + movie.PosterPath
+		}
+
+		results = append(results, res)
+	}
+
+	return results, nil
+}
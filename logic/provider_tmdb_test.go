@@ -0,0 +1,112 @@
+package logic
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestTmdb(url string) *tmdbProvider {
+	return &tmdbProvider{
+		client:   http.DefaultClient,
+		apiUrl:   url,
+		imageUrl: "https://image.example.org",
+		token:    "token",
+	}
+}
+
+func TestTmdbFetch(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/find/tt0133093": {File: "tmdb_find_tt0133093.json"},
+		"/movie/603":      {File: "tmdb_movie_603.json"},
+	})
+	defer srv.Close()
+
+	meta, err := newTestTmdb(srv.URL).Fetch("tt0133093")
+	if err != nil {
+		t.Fatalf("Fetch() returned an error: %v", err)
+	}
+
+	expected := &MovieMetadata{
+		Title:       "The Matrix (1999)",
+		Description: "Set in the 22nd century, The Matrix tells the story of a computer hacker who joins a group of underground insurgents fighting the vast and powerful computers who now rule the earth.",
+		PosterUrl:   "https://image.example.org/t/p/original/f89U3ADr1oiB1s9GkdPOEpXUk5H.jpg",
+		Duration:    "2 hr 16 min",
+		Rating:      8.2,
+		Tags:        []string{"IMDB", "Action", "Science Fiction"},
+	}
+
+	if !reflect.DeepEqual(meta, expected) {
+		t.Errorf("Fetch() returned %#v\nexpected %#v", meta, expected)
+	}
+}
+
+func TestTmdbFetchNotAMovie(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/find/tt0944947": {File: "tmdb_find_empty.json"},
+	})
+	defer srv.Close()
+
+	_, err := newTestTmdb(srv.URL).Fetch("tt0944947")
+	if err == nil || !strings.Contains(err.Error(), "did not return a movie") {
+		t.Errorf("Expected a not a movie error, got %v", err)
+	}
+}
+
+func TestTmdbFetchBadToken(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/find/tt0133093": {File: "tmdb_unauthorized.json", Status: http.StatusUnauthorized},
+	})
+	defer srv.Close()
+
+	_, err := newTestTmdb(srv.URL).Fetch("tt0133093")
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected a 401 error, got %v", err)
+	}
+}
+
+func TestTmdbFetchTmdbId(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/movie/603": {File: "tmdb_movie_603.json"},
+	})
+	defer srv.Close()
+
+	meta, err := newTestTmdb(srv.URL).Fetch("603")
+	if err != nil {
+		t.Fatalf("Fetch() returned an error: %v", err)
+	}
+
+	if meta.Title != "The Matrix (1999)" {
+		t.Errorf("Unexpected title %q", meta.Title)
+	}
+}
+
+func TestTmdbSearch(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/search/movie": {File: "tmdb_search_matrix.json"},
+	})
+	defer srv.Close()
+
+	results, err := newTestTmdb(srv.URL).Search("the matrix")
+	if err != nil {
+		t.Fatalf("Search() returned an error: %v", err)
+	}
+
+	expected := []*SearchResult{
+		{Title: "The Matrix", Year: "1999", PosterUrl: "https://image.example.org/t/p/w92/f89U3ADr1oiB1s9GkdPOEpXUk5H.jpg", Link: "https://www.themoviedb.org/movie/603"},
+		{Title: "The Matrix Reloaded", Year: "2003", PosterUrl: "https://image.example.org/t/p/w92/9TGHDvWrqKBzwDxDodHYXEmOE6J.jpg", Link: "https://www.themoviedb.org/movie/604"},
+		{Title: "The Matrix Unreleased", Link: "https://www.themoviedb.org/movie/684731"},
+	}
+
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Search() returned %v\nexpected %v", results, expected)
+	}
+
+	// Every result must be usable for autofill.
+	for _, res := range results {
+		if def, _ := matchProvider(res.Link); def == nil || def.Name != "IMDb" {
+			t.Errorf("Result link %q is not handled by the TMDB provider", res.Link)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package logic
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	registerProvider(providerDef{
+		Name:     "TVDB",
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`thetvdb\.com/((?:movies|series)/[a-z0-9-]+)`)},
+		Enabled:  (*backend).GetTvdbEnabled,
+		New:      (*backend).newTvdbProvider,
+	})
+}
+
+// tvdbProvider looks up TheTVDB movie and series links using the v4 API.  The
+// ID is the kind and slug from the link, eg "movies/the-matrix".
+type tvdbProvider struct {
+	client *http.Client
+	apiUrl string
+	apiKey string
+}
+
+type tvdbLogin struct {
+	Data struct {
+		Token string `json:"token"`
+	} `json:"data"`
+}
+
+type tvdbRecord struct {
+	Data struct {
+		Id             int    `json:"id"`
+		Name           string `json:"name"`
+		Image          string `json:"image"`
+		Year           string `json:"year"`
+		Runtime        int    `json:"runtime"`        // movies
+		AverageRuntime int    `json:"averageRuntime"` // series
+		Genres         []struct {
+			Name string `json:"name"`
+		} `json:"genres"`
+		Translations struct {
+			NameTranslations []struct {
+				Language string `json:"language"`
+				Name     string `json:"name"`
+			} `json:"nameTranslations"`
+			OverviewTranslations []struct {
+				Language string `json:"language"`
+				Overview string `json:"overview"`
+			} `json:"overviewTranslations"`
+		} `json:"translations"`
+	} `json:"data"`
+}
+
+func (b *backend) newTvdbProvider() (MetadataProvider, error) {
+	enabled, err := b.GetTvdbEnabled()
+	if err != nil {
+		b.autofillLog.Error(err.Error())
+		return nil, fmt.Errorf("Something went wrong :C")
+	}
+
+	if !enabled {
+		return nil, fmt.Errorf("TVDB API usage was not enabled by the site administrator")
+	}
+
+	token, err := b.GetTvdbToken()
+	if err != nil || token == "" {
+		b.autofillLog.Debug("Aborting TVDB autofill since no token was found, its either empty or was never set")
+		return nil, fmt.Errorf("The TVDB integration is not configured correctly, contact the site administrator")
+	}
+
+	return &tvdbProvider{
+		client: b.client,
+		apiUrl: "https://api4.thetvdb.com/v4",
+		apiKey: token,
+	}, nil
+}
+
+func (t *tvdbProvider) Fetch(id string) (*MovieMetadata, error) {
+	parts := strings.SplitN(id, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Invalid TVDB ID %q", id)
+	}
+	kind, slug := parts[0], parts[1]
+
+	login := tvdbLogin{}
+	if err := postJson(t.client, t.apiUrl+"/login", map[string]string{"apikey": t.apiKey}, &login); err != nil {
+		return nil, fmt.Errorf("%v\nMaybe check your TVDB api key", err)
+	}
+
+	found := tvdbRecord{}
+	if err := t.get(login.Data.Token, fmt.Sprintf("/%s/slug/%s", kind, slug), &found); err != nil {
+		return nil, err
+	}
+
+	record := tvdbRecord{}
+	if err := t.get(login.Data.Token, fmt.Sprintf("/%s/%d/extended?meta=translations", kind, found.Data.Id), &record); err != nil {
+		return nil, err
+	}
+
+	data := record.Data
+	meta := &MovieMetadata{
+		Title:     data.Name,
+		PosterUrl: data.Image,
+		Tags:      []string{"TVDB"},
+	}
+
+	for _, tr := range data.Translations.NameTranslations {
+		if tr.Language == "eng" && tr.Name != "" {
+			meta.Title = tr.Name
+		}
+	}
+
+	for _, tr := range data.Translations.OverviewTranslations {
+		if tr.Language == "eng" {
+			meta.Description = tr.Overview
+		}
+	}
+
+	if meta.Title == "" {
+		return nil, fmt.Errorf("No title returned from API")
+	}
+
+	if data.Year != "" {
+		meta.Title += " (" + data.Year + ")"
+	}
+
+	if data.Runtime > 0 {
+		meta.Duration = formatDuration(data.Runtime)
+	} else if data.AverageRuntime > 0 {
+		meta.Duration = fmt.Sprintf("%d min per ep", data.AverageRuntime)
+	}
+
+	for _, genre := range data.Genres {
+		meta.Tags = append(meta.Tags, genre.Name)
+	}
+
+	return meta, nil
+}
+
+func (t *tvdbProvider) get(token, path string, v interface{}) error {
+	req, err := http.NewRequest("GET", t.apiUrl+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return doJson(t.client, req, v)
+}
@@ -0,0 +1,48 @@
+package logic
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTvdbFetch(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/login":                  {File: "tvdb_login.json"},
+		"/movies/slug/the-matrix": {File: "tvdb_movie_slug.json", Auth: "Bearer test-bearer-token"},
+		"/movies/169/extended":    {File: "tvdb_movie_extended.json", Auth: "Bearer test-bearer-token"},
+	})
+	defer srv.Close()
+
+	tv := &tvdbProvider{client: http.DefaultClient, apiUrl: srv.URL, apiKey: "key"}
+	meta, err := tv.Fetch("movies/the-matrix")
+	if err != nil {
+		t.Fatalf("Fetch() returned an error: %v", err)
+	}
+
+	expected := &MovieMetadata{
+		Title:       "The Matrix (1999)",
+		Description: "Set in the 22nd century, The Matrix tells the story of a computer hacker who joins a group of underground insurgents fighting the vast and powerful computers who now rule the earth.",
+		PosterUrl:   "https://artworks.thetvdb.com/banners/movies/169/posters/169.jpg",
+		Duration:    "2 hr 16 min",
+		Tags:        []string{"TVDB", "Action", "Science Fiction"},
+	}
+
+	if !reflect.DeepEqual(meta, expected) {
+		t.Errorf("Fetch() returned %#v\nexpected %#v", meta, expected)
+	}
+}
+
+func TestTvdbFetchBadKey(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/login": {File: "tvdb_unauthorized.json", Status: http.StatusUnauthorized},
+	})
+	defer srv.Close()
+
+	tv := &tvdbProvider{client: http.DefaultClient, apiUrl: srv.URL, apiKey: "bad"}
+	_, err := tv.Fetch("movies/the-matrix")
+	if err == nil || !strings.Contains(err.Error(), "api key") {
+		t.Errorf("Expected an api key error, got %v", err)
+	}
+}
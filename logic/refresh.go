@@ -0,0 +1,280 @@
+package logic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+// Fields that can be updated by a metadata refresh.
+const (
+	RefreshOriginalTitle string = "OriginalTitle"
+	RefreshDescription   string = "Description"
+	RefreshDuration      string = "Duration"
+	RefreshRating        string = "Rating"
+	RefreshPoster        string = "Poster"
+)
+
+// A MetadataChange is a field that differs between a movie and what its
+// provider currently returns.
+type MetadataChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// metadataUpdate holds the fresh metadata for a movie and how it differs
+// from what is saved.
+type metadataUpdate struct {
+	meta    *MovieMetadata
+	changes []*MetadataChange
+}
+
+// sourceLink returns the link a movie was added with.
+func sourceLink(movie *models.Movie) *models.Link {
+	for _, link := range movie.Links {
+		if link.IsSource {
+			return link
+		}
+	}
+
+	if len(movie.Links) > 0 {
+		return movie.Links[0]
+	}
+	return nil
+}
+
+func (b *backend) fetchMetadataUpdate(movie *models.Movie) (*metadataUpdate, error) {
+	link := sourceLink(movie)
+	if link == nil {
+		return nil, fmt.Errorf("Movie %q does not have a source link", movie.Name)
+	}
+
+	def, extId := matchProvider(link.Url)
+	if def == nil {
+		return nil, fmt.Errorf("The source link of %q is not supported by any metadata provider", movie.Name)
+	}
+
+	provider, err := def.New(b)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := fetchMetadata(def, provider, extId)
+	if err != nil {
+		return nil, fmt.Errorf("Could not refresh metadata from %s: %v", def.Name, err)
+	}
+
+	return &metadataUpdate{
+		meta:    meta,
+		changes: diffMetadata(movie, meta),
+	}, nil
+}
+
+// diffMetadata lists the fields of movie that differ from meta.  Fields the
+// provider left empty are never reported, so a refresh will not blank out
+// anything that was there before.
+func diffMetadata(movie *models.Movie, meta *MovieMetadata) []*MetadataChange {
+	changes := []*MetadataChange{}
+
+	if meta.OriginalTitle != "" && meta.OriginalTitle != movie.OriginalTitle {
+		changes = append(changes, &MetadataChange{Field: RefreshOriginalTitle, Old: movie.OriginalTitle, New: meta.OriginalTitle})
+	}
+
+	if meta.Description != "" && meta.Description != movie.Description {
+		changes = append(changes, &MetadataChange{Field: RefreshDescription, Old: movie.Description, New: meta.Description})
+	}
+
+	if meta.Duration != "" && meta.Duration != movie.Duration {
+		changes = append(changes, &MetadataChange{Field: RefreshDuration, Old: movie.Duration, New: meta.Duration})
+	}
+
+	if meta.Rating != 0 && meta.Rating != movie.Rating {
+		changes = append(changes, &MetadataChange{Field: RefreshRating, Old: formatRating(movie.Rating), New: formatRating(meta.Rating)})
+	}
+
+	// Movies added before the poster source was recorded only have the store
+	// key, so they will pick up a fresh copy on their first refresh.
+	if meta.PosterUrl != "" && meta.PosterUrl != movie.PosterSource {
+		old := movie.PosterSource
+		if old == "" {
+			old = movie.Poster
+		}
+		changes = append(changes, &MetadataChange{Field: RefreshPoster, Old: old, New: meta.PosterUrl})
+	}
+
+	return changes
+}
+
+func formatRating(rating float32) string {
+	return strconv.FormatFloat(float64(rating), 'f', -1, 32)
+}
+
+// applyMetadataUpdate copies the changed fields named in fields to the movie
+// and saves it.  A nil fields slice applies every change.  The changes are
+// worked out again against movie, which might be newer than the copy the
+// update was fetched for.  The changes that were applied are returned.
+func (b *backend) applyMetadataUpdate(movie *models.Movie, update *metadataUpdate, fields []string) ([]*MetadataChange, error) {
+	applied := []*MetadataChange{}
+	for _, change := range diffMetadata(movie, update.meta) {
+		if fields != nil && !models.StringSliceContains(change.Field, fields) {
+			continue
+		}
+
+		switch change.Field {
+		case RefreshOriginalTitle:
+			movie.OriginalTitle = update.meta.OriginalTitle
+		case RefreshDescription:
+			movie.Description = update.meta.Description
+		case RefreshDuration:
+			movie.Duration = update.meta.Duration
+		case RefreshRating:
+			movie.Rating = update.meta.Rating
+		case RefreshPoster:
+			key := b.downloadPoster(update.meta.PosterUrl)
+			if key == defaultPosterKey {
+				// Keep the old poster rather than replacing it with the
+				// placeholder.
+				continue
+			}
+			movie.Poster = key
+			movie.PosterSource = update.meta.PosterUrl
+		}
+		applied = append(applied, change)
+	}
+
+	if len(applied) == 0 {
+		return applied, nil
+	}
+
+	if err := b.UpdateMovie(movie); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// GetMetadataChanges queries the provider of the movie's source link and
+// returns the fields that would change with a refresh.  Nothing is saved.
+func (b *backend) GetMetadataChanges(movie *models.Movie) ([]*MetadataChange, error) {
+	update, err := b.fetchMetadataUpdate(movie)
+	if err != nil {
+		return nil, err
+	}
+	return update.changes, nil
+}
+
+// AdminRefreshMetadata queries the provider again and applies the changes to
+// the given fields.
+func (b *backend) AdminRefreshMetadata(admin *models.User, movie *models.Movie, fields []string) error {
+	update, err := b.fetchMetadataUpdate(movie)
+	if err != nil {
+		return err
+	}
+
+	applied, err := b.applyMetadataUpdate(movie, update, fields)
+	if err != nil {
+		return err
+	}
+
+	b.auditRefresh(admin, movie, applied)
+	return nil
+}
+
+func (b *backend) auditRefresh(admin *models.User, movie *models.Movie, applied []*MetadataChange) {
+	if len(applied) == 0 {
+		return
+	}
+
+	before := []string{}
+	after := []string{}
+	for _, change := range applied {
+		before = append(before, change.Field+": "+change.Old)
+		after = append(after, change.Field+": "+change.New)
+	}
+
+	b.audit(admin, models.AUDIT_MOVIE_REFRESH, "Movie", movie.Id, movie.Name, strings.Join(before, "\n"), strings.Join(after, "\n"))
+}
+
+// refreshAllMetadata refreshes the metadata of every movie that has not been
+// watched yet.  Movies whose provider is disabled or fails are skipped.
+func (b *backend) refreshAllMetadata() {
+	movies, err := b.data.GetActiveMovies()
+	if err != nil {
+		b.autofillLog.Error("Unable to get movies for metadata refresh: %v", err)
+		return
+	}
+
+	b.autofillLog.Info("Refreshing metadata for %d movies", len(movies))
+	for _, movie := range movies {
+		if b.stopping() {
+			b.autofillLog.Info("Metadata refresh stopped for shutdown")
+			return
+		}
+
+		update, err := b.fetchMetadataUpdate(movie)
+		if err != nil {
+			b.autofillLog.Debug("Skipping metadata refresh of movie %d: %v", movie.Id, err)
+			continue
+		}
+
+		b.applyScheduledRefresh(movie.Id, update)
+	}
+}
+
+// applyScheduledRefresh applies update to the saved copy of the movie.  The
+// movie list of a run is read before the providers are queried, so the movie
+// might have been deleted, watched or edited since.  Only the metadata
+// fields are taken from the update, everything else is kept as saved.
+func (b *backend) applyScheduledRefresh(movieId int, update *metadataUpdate) {
+	movie, err := b.data.GetMovie(movieId)
+	if err != nil {
+		b.autofillLog.Debug("Skipping metadata refresh of movie %d: %v", movieId, err)
+		return
+	}
+
+	if movie.CycleWatched != nil {
+		b.autofillLog.Debug("Skipping metadata refresh of movie %d, it has been watched", movieId)
+		return
+	}
+
+	applied, err := b.applyMetadataUpdate(movie, update, nil)
+	if err != nil {
+		b.autofillLog.Error("Unable to update metadata of movie %d: %v", movieId, err)
+		return
+	}
+
+	b.auditRefresh(nil, movie, applied)
+}
+
+// metadataRefreshLoop runs refreshAllMetadata every MetadataRefreshInterval
+// hours.  The setting is checked every minute so changes to it take effect
+// without a restart.
+func (b *backend) metadataRefreshLoop() {
+	last := time.Now()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+
+		hours, err := b.GetMetadataRefreshInterval()
+		if err != nil {
+			b.autofillLog.Error("Unable to get config value %s: %v", ConfigMetadataRefreshInterval, err)
+			continue
+		}
+
+		if hours <= 0 || time.Since(last) < time.Duration(hours)*time.Hour {
+			continue
+		}
+
+		last = time.Now()
+		b.refreshAllMetadata()
+	}
+}
@@ -0,0 +1,188 @@
+package logic
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/zorchenhimer/MoviePolls/database"
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+func TestDiffMetadata(t *testing.T) {
+	movie := &models.Movie{
+		Name:         "The Matrix (1999)",
+		Description:  "A hacker learns the truth.",
+		Duration:     "2 hr 16 min",
+		Rating:       7.9,
+		Poster:       "posters/imdb-tt0133093.jpg",
+		PosterSource: "https://image.example.org/old.jpg",
+	}
+
+	tests := []struct {
+		name     string
+		meta     *MovieMetadata
+		expected []*MetadataChange
+	}{
+		{
+			name: "unchanged",
+			meta: &MovieMetadata{
+				Description: "A hacker learns the truth.",
+				Duration:    "2 hr 16 min",
+				Rating:      7.9,
+				PosterUrl:   "https://image.example.org/old.jpg",
+			},
+			expected: []*MetadataChange{},
+		},
+		{
+			name: "changed",
+			meta: &MovieMetadata{
+				Description: "Set in the 22nd century.",
+				Duration:    "2 hr 16 min",
+				Rating:      8.2,
+				PosterUrl:   "https://image.example.org/new.jpg",
+			},
+			expected: []*MetadataChange{
+				{Field: RefreshDescription, Old: "A hacker learns the truth.", New: "Set in the 22nd century."},
+				{Field: RefreshRating, Old: "7.9", New: "8.2"},
+				{Field: RefreshPoster, Old: "https://image.example.org/old.jpg", New: "https://image.example.org/new.jpg"},
+			},
+		},
+		{
+			// Missing values from the provider never clear a field.
+			name:     "empty",
+			meta:     &MovieMetadata{},
+			expected: []*MetadataChange{},
+		},
+	}
+
+	for _, tt := range tests {
+		changes := diffMetadata(movie, tt.meta)
+		if !reflect.DeepEqual(changes, tt.expected) {
+			t.Errorf("%s: diffMetadata() returned %v, expected %v", tt.name, changes, tt.expected)
+		}
+	}
+}
+
+func TestDiffMetadataUnknownPosterSource(t *testing.T) {
+	movie := &models.Movie{Poster: "posters/imdb-tt0133093.jpg"}
+
+	changes := diffMetadata(movie, &MovieMetadata{PosterUrl: "https://image.example.org/new.jpg"})
+	expected := []*MetadataChange{
+		{Field: RefreshPoster, Old: "posters/imdb-tt0133093.jpg", New: "https://image.example.org/new.jpg"},
+	}
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("diffMetadata() returned %v, expected %v", changes, expected)
+	}
+}
+
+func TestSourceLink(t *testing.T) {
+	source := &models.Link{Url: "https://www.imdb.com/title/tt0133093/", IsSource: true}
+	other := &models.Link{Url: "https://www.themoviedb.org/movie/603"}
+
+	if link := sourceLink(&models.Movie{Links: []*models.Link{other, source}}); link != source {
+		t.Errorf("sourceLink() returned %v, expected the source link", link)
+	}
+
+	// Movies without a flagged link fall back to the first one.
+	if link := sourceLink(&models.Movie{Links: []*models.Link{other}}); link != other {
+		t.Errorf("sourceLink() returned %v, expected the first link", link)
+	}
+
+	if link := sourceLink(&models.Movie{}); link != nil {
+		t.Errorf("sourceLink() returned %v for a movie without links", link)
+	}
+}
+
+func TestApplyScheduledRefresh(t *testing.T) {
+	b, cleanup := newTestBackend(t)
+	defer cleanup()
+
+	cycleId, err := b.data.AddCycle(nil)
+	if err != nil {
+		t.Fatalf("AddCycle() returned an error: %v", err)
+	}
+	cycle, err := b.data.GetCycle(cycleId)
+	if err != nil {
+		t.Fatalf("GetCycle() returned an error: %v", err)
+	}
+
+	add := func(movie *models.Movie) int {
+		id, err := b.data.AddMovie(movie)
+		if err != nil {
+			t.Fatalf("AddMovie() returned an error: %v", err)
+		}
+		return id
+	}
+
+	edited := add(&models.Movie{Name: "Zodiac", Description: "Old", Duration: "2 hr 37 min"})
+	deleted := add(&models.Movie{Name: "Amadeus", Description: "Old"})
+	watched := add(&models.Movie{Name: "Heat", Description: "Old"})
+
+	// The run reads the list, then the movies change while the providers
+	// are queried.
+	movies, err := b.data.GetActiveMovies()
+	if err != nil || len(movies) != 3 {
+		t.Fatalf("GetActiveMovies() returned %v, %v", movies, err)
+	}
+
+	movie, _ := b.data.GetMovie(edited)
+	movie.Pending = true
+	movie.Duration = "2 hr 42 min"
+	if err := b.data.UpdateMovie(movie); err != nil {
+		t.Fatalf("UpdateMovie() returned an error: %v", err)
+	}
+
+	if err := b.data.RemoveMovie(deleted); err != nil {
+		t.Fatalf("RemoveMovie() returned an error: %v", err)
+	}
+
+	movie, _ = b.data.GetMovie(watched)
+	movie.CycleWatched = cycle
+	if err := b.data.UpdateMovie(movie); err != nil {
+		t.Fatalf("UpdateMovie() returned an error: %v", err)
+	}
+
+	meta := &MovieMetadata{Description: "New", Duration: "2 hr 42 min"}
+	for _, movie := range movies {
+		b.applyScheduledRefresh(movie.Id, &metadataUpdate{meta: meta, changes: diffMetadata(movie, meta)})
+	}
+
+	movie, err = b.data.GetMovie(edited)
+	if err != nil {
+		t.Fatalf("GetMovie() returned an error: %v", err)
+	}
+	if movie.Description != "New" || !movie.Pending || movie.Duration != "2 hr 42 min" {
+		t.Errorf("The edit was lost or the metadata not applied: %v", movie)
+	}
+
+	if movie, err := b.data.GetMovie(deleted); err == nil {
+		t.Errorf("The deleted movie was added back: %v", movie)
+	}
+
+	if movie, _ := b.data.GetMovie(watched); movie.Description != "Old" || movie.CycleWatched == nil {
+		t.Errorf("The watched movie was refreshed: %v", movie)
+	}
+
+	// Only the edited movie was refreshed, and only its description
+	// changed.
+	entries, err := b.GetAuditLog(models.AuditFilter{Action: models.AUDIT_MOVIE_REFRESH})
+	if err != nil || len(entries) != 1 || entries[0].Before != RefreshDescription+": Old" {
+		t.Errorf("Unexpected audit log %v, %v", entries, err)
+	}
+}
+
+func TestUpdateMissingMovie(t *testing.T) {
+	b, cleanup := newTestBackend(t)
+	defer cleanup()
+
+	err := b.data.UpdateMovie(&models.Movie{Id: 42, Name: "Zodiac"})
+	if !errors.Is(err, database.ErrNoValue) {
+		t.Errorf("UpdateMovie() returned %v, expected ErrNoValue", err)
+	}
+
+	if movie, err := b.data.GetMovie(42); err == nil {
+		t.Errorf("UpdateMovie() added the movie: %v", movie)
+	}
+}
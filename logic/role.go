@@ -0,0 +1,121 @@
+package logic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+func (b *backend) GetRoles() ([]*models.Role, error) {
+	return b.data.GetRoles()
+}
+
+func (b *backend) GetRole(id int) (*models.Role, error) {
+	return b.data.GetRole(id)
+}
+
+func (b *backend) AddRole(admin *models.User, role *models.Role) (int, error) {
+	if err := validateRole(role); err != nil {
+		return 0, err
+	}
+
+	id, err := b.data.AddRole(role)
+	if err != nil {
+		return 0, err
+	}
+
+	b.audit(admin, models.AUDIT_ROLE_ADD, "Role", id, role.Name, "", roleString(role))
+	return id, nil
+}
+
+func (b *backend) UpdateRole(admin *models.User, role *models.Role) error {
+	if err := validateRole(role); err != nil {
+		return err
+	}
+
+	old, err := b.data.GetRole(role.Id)
+	if err != nil {
+		return err
+	}
+	before := roleString(old)
+
+	if err := b.data.UpdateRole(role); err != nil {
+		return err
+	}
+
+	b.audit(admin, models.AUDIT_ROLE_UPDATE, "Role", role.Id, role.Name, before, roleString(role))
+	return nil
+}
+
+func (b *backend) DeleteRole(admin *models.User, role *models.Role) error {
+	old, err := b.data.GetRole(role.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := b.data.DeleteRole(role.Id); err != nil {
+		return err
+	}
+
+	b.audit(admin, models.AUDIT_ROLE_DELETE, "Role", old.Id, old.Name, roleString(old), "")
+	return nil
+}
+
+// Assign a role to a user.  A roleId of zero removes the user's role.
+func (b *backend) AdminSetUserRole(admin *models.User, user *models.User, roleId int) error {
+	before := "none"
+	if user.Role != nil {
+		before = user.Role.Name
+	}
+
+	if roleId == 0 {
+		user.Role = nil
+	} else {
+		role, err := b.data.GetRole(roleId)
+		if err != nil {
+			return err
+		}
+		user.Role = role
+	}
+
+	after := "none"
+	if user.Role != nil {
+		after = user.Role.Name
+	}
+
+	if err := b.data.UpdateUser(user); err != nil {
+		return err
+	}
+
+	if before != after {
+		b.audit(admin, models.AUDIT_USER_ROLE, "User", user.Id, user.Name, before, after)
+	}
+	return nil
+}
+
+func (b *backend) CheckPermission(user *models.User, perm models.Permission) bool {
+	return user != nil && user.HasPermission(perm)
+}
+
+func validateRole(role *models.Role) error {
+	role.Name = strings.TrimSpace(role.Name)
+	if role.Name == "" {
+		return fmt.Errorf("Role name cannot be empty")
+	}
+
+	for _, perm := range role.Permissions {
+		if !perm.Valid() {
+			return fmt.Errorf("Unknown permission %q", perm)
+		}
+	}
+	return nil
+}
+
+func roleString(role *models.Role) string {
+	perms := []string{}
+	for _, p := range role.Permissions {
+		perms = append(perms, string(p))
+	}
+	return fmt.Sprintf("%s: %s", role.Name, strings.Join(perms, ", "))
+}
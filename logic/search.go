@@ -0,0 +1,535 @@
+package logic
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+// Weights of the indexed fields.  A word in the title counts for more than
+// the same word somewhere in the description.
+const (
+	searchWeightTitle         = 10
+	searchWeightOriginalTitle = 8
+	searchWeightTag           = 5
+	searchWeightText          = 1 // description and remarks
+)
+
+// How good a match between a query word and an indexed word is.
+const (
+	searchQualityExact  = 1.0
+	searchQualityPrefix = 0.8
+	searchQualityTypo   = 0.6 // per typo the quality is reduced by this factor
+)
+
+// Length of the description snippet shown with a search result, in runes.
+const searchSnippetLength = 200
+
+// SearchFilter selects which movies are searched.  Pending and denied movies
+// are never included.
+type SearchFilter struct {
+	Active  bool // not watched and not removed
+	Watched bool
+	Removed bool
+}
+
+func (f SearchFilter) matches(movie *models.Movie) bool {
+	switch {
+	case movie.Removed:
+		return f.Removed
+	case movie.CycleWatched != nil:
+		return f.Watched
+	default:
+		return f.Active
+	}
+}
+
+// A TextFragment is a piece of a search result.  Match is set if the text
+// matched the query.
+type TextFragment struct {
+	Text  string
+	Match bool
+}
+
+// A MovieSearchResult is a movie found by SearchMovies with its matching parts
+// highlighted.
+type MovieSearchResult struct {
+	Movie         *models.Movie
+	Score         float64
+	Title         []TextFragment
+	OriginalTitle []TextFragment
+	Snippet       []TextFragment // part of the description or remarks
+	MatchedTags   map[int]bool
+}
+
+// searchIndex is an inverted index of the words in every movie.
+type searchIndex struct {
+	lock sync.RWMutex
+
+	// word -> movie ID -> weight of the best field the word is in
+	words map[string]map[int]int
+
+	// movie ID -> words, so a movie can be taken out again
+	movies map[int][]string
+}
+
+// searchHit is a movie found in the index.  Words are the indexed words that
+// matched the query.
+type searchHit struct {
+	Id    int
+	Score float64
+	Words map[string]bool
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		words:  map[string]map[int]int{},
+		movies: map[int][]string{},
+	}
+}
+
+// searchWords splits text into lower case words.  Anything that isn't a
+// letter or a digit separates words.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// movieWords returns every word of the movie with the weight of the most
+// important field it is in.
+func movieWords(movie *models.Movie) map[string]int {
+	words := map[string]int{}
+	add := func(text string, weight int) {
+		for _, word := range searchWords(text) {
+			if words[word] < weight {
+				words[word] = weight
+			}
+		}
+	}
+
+	add(movie.Name, searchWeightTitle)
+	add(movie.OriginalTitle, searchWeightOriginalTitle)
+	for _, tag := range movie.Tags {
+		add(tag.Name, searchWeightTag)
+		// "Sci-Fi" is also found by "scifi", and by "science" through its key.
+		add(strings.Join(searchWords(tag.Name), ""), searchWeightTag)
+		add(tagKey(tag.Name), searchWeightTag)
+	}
+	add(movie.Description, searchWeightText)
+	add(movie.Remarks, searchWeightText)
+
+	return words
+}
+
+// add indexes the movie, replacing what was indexed for it before.
+func (idx *searchIndex) add(movie *models.Movie) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	idx.removeLocked(movie.Id)
+
+	words := []string{}
+	for word, weight := range movieWords(movie) {
+		if idx.words[word] == nil {
+			idx.words[word] = map[int]int{}
+		}
+		idx.words[word][movie.Id] = weight
+		words = append(words, word)
+	}
+	idx.movies[movie.Id] = words
+}
+
+func (idx *searchIndex) remove(id int) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	idx.removeLocked(id)
+}
+
+func (idx *searchIndex) removeLocked(id int) {
+	for _, word := range idx.movies[id] {
+		delete(idx.words[word], id)
+		if len(idx.words[word]) == 0 {
+			delete(idx.words, word)
+		}
+	}
+	delete(idx.movies, id)
+}
+
+// maxTypos returns the number of typos allowed in a query word.  Short words
+// have to match exactly, otherwise almost anything would match them.
+func maxTypos(word []rune) int {
+	switch {
+	case len(word) < 4:
+		return 0
+	case len(word) < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// matchQuality returns how well the query word matches an indexed word, or
+// zero if it doesn't.
+func matchQuality(query, word []rune) float64 {
+	if string(query) == string(word) {
+		return searchQualityExact
+	}
+
+	if len(query) >= 2 && len(word) > len(query) && string(word[:len(query)]) == string(query) {
+		return searchQualityPrefix
+	}
+
+	max := maxTypos(query)
+	if max == 0 {
+		return 0
+	}
+
+	typos := editDistance(query, word, max)
+	if typos > max {
+		return 0
+	}
+
+	quality := 1.0
+	for i := 0; i < typos; i++ {
+		quality *= searchQualityTypo
+	}
+	return quality
+}
+
+// editDistance returns the number of insertions, deletions, substitutions and
+// swaps of two neighbouring runes needed to turn a into b.  Anything above max
+// is returned as max+1.
+func editDistance(a, b []rune, max int) int {
+	diff := len(a) - len(b)
+	if diff > max || -diff > max {
+		return max + 1
+	}
+
+	// Only the last two rows are needed for a swap.
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	row := make([]int, len(b)+1)
+	for k := range prev {
+		prev[k] = k
+	}
+
+	for i := 1; i <= len(a); i++ {
+		row[0] = i
+		rowMin := row[0]
+
+		for k := 1; k <= len(b); k++ {
+			cost := 1
+			if a[i-1] == b[k-1] {
+				cost = 0
+			}
+
+			row[k] = minInt(prev[k]+1, minInt(row[k-1]+1, prev[k-1]+cost))
+			if i > 1 && k > 1 && a[i-1] == b[k-2] && a[i-2] == b[k-1] {
+				row[k] = minInt(row[k], prev2[k-2]+1)
+			}
+
+			rowMin = minInt(rowMin, row[k])
+		}
+
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, row = prev, row, prev2
+	}
+
+	if prev[len(b)] > max {
+		return max + 1
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// search returns the movies that match every word of the query, best match
+// first.
+func (idx *searchIndex) search(query string) []*searchHit {
+	words := searchWords(query)
+	if len(words) == 0 {
+		return []*searchHit{}
+	}
+
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	var hits map[int]*searchHit
+	seen := map[string]bool{}
+
+	for _, word := range words {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+
+		// The best score of this query word for each movie.
+		scores := map[int]float64{}
+		matched := map[int][]string{}
+
+		query := []rune(word)
+		for indexed, movies := range idx.words {
+			quality := matchQuality(query, []rune(indexed))
+			if quality == 0 {
+				continue
+			}
+
+			for id, weight := range movies {
+				if hits != nil && hits[id] == nil {
+					continue
+				}
+
+				if score := quality * float64(weight); score > scores[id] {
+					scores[id] = score
+				}
+				matched[id] = append(matched[id], indexed)
+			}
+		}
+
+		// Every query word has to match, so only the movies that matched
+		// the words before are kept.
+		next := map[int]*searchHit{}
+		for id, score := range scores {
+			hit := hits[id]
+			if hit == nil {
+				hit = &searchHit{Id: id, Words: map[string]bool{}}
+			}
+
+			hit.Score += score
+			for _, w := range matched[id] {
+				hit.Words[w] = true
+			}
+			next[id] = hit
+		}
+		hits = next
+
+		if len(hits) == 0 {
+			break
+		}
+	}
+
+	found := []*searchHit{}
+	for _, hit := range hits {
+		found = append(found, hit)
+	}
+
+	sort.Slice(found, func(i, k int) bool {
+		if found[i].Score != found[k].Score {
+			return found[i].Score > found[k].Score
+		}
+		return found[i].Id < found[k].Id
+	})
+	return found
+}
+
+// highlight splits text into fragments, marking the words that are in words.
+func highlight(text string, words map[string]bool) []TextFragment {
+	fragments := []TextFragment{}
+	add := func(s string, match bool) {
+		if s == "" {
+			return
+		}
+
+		last := len(fragments) - 1
+		if last >= 0 && fragments[last].Match == match && !match {
+			fragments[last].Text += s
+			return
+		}
+		fragments = append(fragments, TextFragment{Text: s, Match: match})
+	}
+
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+	start := 0
+	inWord := false
+	for i, r := range text {
+		if isWord(r) == inWord {
+			continue
+		}
+
+		if inWord {
+			add(text[start:i], words[strings.ToLower(text[start:i])])
+		} else {
+			add(text[start:i], false)
+		}
+		start = i
+		inWord = !inWord
+	}
+
+	if inWord {
+		add(text[start:], words[strings.ToLower(text[start:])])
+	} else {
+		add(text[start:], false)
+	}
+	return fragments
+}
+
+// snippet returns up to length runes of text around the first word that is
+// in words, highlighted.  Without a match the start of the text is used.
+func snippet(text string, words map[string]bool, length int) []TextFragment {
+	fragments := highlight(text, words)
+
+	offset := 0
+	if hasMatch(fragments) {
+		for _, f := range fragments {
+			if f.Match {
+				break
+			}
+			offset += len([]rune(f.Text))
+		}
+	}
+
+	runes := []rune(text)
+	if len(runes) <= length {
+		return fragments
+	}
+
+	// Show a bit of context before the match, starting at a word.
+	start := offset - length/4
+	if start <= 0 {
+		start = 0
+	} else {
+		for start < offset && !unicode.IsSpace(runes[start-1]) {
+			start++
+		}
+	}
+
+	end := start + length
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	cut := highlight(string(runes[start:end]), words)
+	if start > 0 {
+		cut = append([]TextFragment{{Text: "…"}}, cut...)
+	}
+	if end < len(runes) {
+		cut = append(cut, TextFragment{Text: "…"})
+	}
+	return cut
+}
+
+func hasMatch(fragments []TextFragment) bool {
+	for _, f := range fragments {
+		if f.Match {
+			return true
+		}
+	}
+	return false
+}
+
+var re_searchTag = regexp.MustCompile(`t:"([^"]+)"`)
+
+// parseSearchQuery takes the t:"Tag Name" filters out of the query.
+func parseSearchQuery(query string) (string, []string) {
+	tags := []string{}
+	for _, match := range re_searchTag.FindAllStringSubmatch(query, -1) {
+		tags = append(tags, match[1])
+	}
+
+	return strings.TrimSpace(re_searchTag.ReplaceAllString(query, "")), tags
+}
+
+// buildSearchIndex indexes every movie in the database.
+func (b *backend) buildSearchIndex() error {
+	movies, err := b.data.GetAllMovies()
+	if err != nil {
+		return err
+	}
+
+	idx := newSearchIndex()
+	for _, movie := range movies {
+		idx.add(movie)
+	}
+
+	b.search = idx
+	return nil
+}
+
+// SearchMovies searches the title, original title, tags, description and
+// remarks of every movie the filter selects.  Movies can be limited to some
+// tags with t:"Tag Name" in the query.
+func (b *backend) SearchMovies(query string, filter SearchFilter) ([]*MovieSearchResult, error) {
+	text, tags := parseSearchQuery(query)
+
+	hits := []*searchHit{}
+	if text != "" {
+		hits = b.search.search(text)
+	} else if len(tags) > 0 {
+		// Only tags, so every movie is a candidate.
+		movies, err := b.data.GetAllMovies()
+		if err != nil {
+			return nil, err
+		}
+		for _, movie := range movies {
+			hits = append(hits, &searchHit{Id: movie.Id, Words: map[string]bool{}})
+		}
+	}
+
+	results := []*MovieSearchResult{}
+	for _, hit := range hits {
+		movie, err := b.data.GetMovie(hit.Id)
+		if err != nil {
+			// Deleted since it was indexed.
+			continue
+		}
+
+		if !movie.Visible() || !filter.matches(movie) {
+			continue
+		}
+
+		hasTags := true
+		for _, tag := range tags {
+			if !movie.HasTag(tag) {
+				hasTags = false
+				break
+			}
+		}
+		if !hasTags {
+			continue
+		}
+
+		result := &MovieSearchResult{
+			Movie:         movie,
+			Score:         hit.Score,
+			Title:         highlight(movie.Name, hit.Words),
+			OriginalTitle: highlight(movie.OriginalTitle, hit.Words),
+			Snippet:       snippet(movie.Description, hit.Words, searchSnippetLength),
+			MatchedTags:   map[int]bool{},
+		}
+
+		if !hasMatch(result.Snippet) {
+			if remarks := snippet(movie.Remarks, hit.Words, searchSnippetLength); hasMatch(remarks) {
+				result.Snippet = remarks
+			}
+		}
+
+		for _, tag := range movie.Tags {
+			matched := hit.Words[tagKey(tag.Name)] || hit.Words[strings.Join(searchWords(tag.Name), "")]
+			for _, word := range searchWords(tag.Name) {
+				matched = matched || hit.Words[word]
+			}
+			for _, name := range tags {
+				matched = matched || strings.EqualFold(name, tag.Name)
+			}
+			result.MatchedTags[tag.Id] = matched
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,202 @@
+package logic
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+func TestSearchWords(t *testing.T) {
+	words := searchWords("The Matrix: Reloaded (2003), sci-fi!")
+	expected := []string{"the", "matrix", "reloaded", "2003", "sci", "fi"}
+	if !reflect.DeepEqual(words, expected) {
+		t.Errorf("searchWords() returned %v, expected %v", words, expected)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		max      int
+		expected int
+	}{
+		{"matrix", "matrix", 2, 0},
+		{"matirx", "matrix", 2, 1}, // swapped letters
+		{"matrx", "matrix", 2, 1},
+		{"natrix", "matrix", 2, 1},
+		{"mtarx", "matrix", 2, 2},
+		{"alien", "matrix", 2, 3},
+		{"ab", "abcdef", 2, 3}, // too different in length
+		{"ビバップ", "ビバプ", 1, 1},
+	}
+
+	for _, tt := range tests {
+		if d := editDistance([]rune(tt.a), []rune(tt.b), tt.max); d != tt.expected {
+			t.Errorf("editDistance(%q, %q, %d) = %d, expected %d", tt.a, tt.b, tt.max, d, tt.expected)
+		}
+	}
+}
+
+func TestMatchQuality(t *testing.T) {
+	tests := []struct {
+		query, word string
+		expected    float64
+	}{
+		{"matrix", "matrix", searchQualityExact},
+		{"mat", "matrix", searchQualityPrefix},
+		{"matirx", "matrix", searchQualityTypo},
+		{"m", "matrix", 0},
+		{"cat", "car", 0}, // short words have to match exactly
+		{"inception", "interception", 0},
+	}
+
+	for _, tt := range tests {
+		if q := matchQuality([]rune(tt.query), []rune(tt.word)); q != tt.expected {
+			t.Errorf("matchQuality(%q, %q) = %v, expected %v", tt.query, tt.word, q, tt.expected)
+		}
+	}
+}
+
+func testSearchMovies() []*models.Movie {
+	return []*models.Movie{
+		{
+			Id:          1,
+			Name:        "The Matrix (1999)",
+			Description: "A hacker learns the truth about his reality.",
+			Tags:        []*models.Tag{{Id: 1, Name: "Sci-Fi"}},
+		},
+		{
+			Id:            2,
+			Name:          "Cowboy Bebop: Tengoku no Tobira",
+			OriginalTitle: "カウボーイビバップ 天国の扉",
+			Description:   "Bounty hunters chase a terrorist through Mars.",
+			Tags:          []*models.Tag{{Id: 2, Name: "Anime"}, {Id: 1, Name: "Sci-Fi"}},
+		},
+		{
+			Id:          3,
+			Name:        "Hackers (1995)",
+			Description: "Teenagers are framed for a virus.",
+			Remarks:     "Better than the matrix",
+		},
+	}
+}
+
+func searchIds(hits []*searchHit) []int {
+	ids := []int{}
+	for _, hit := range hits {
+		ids = append(ids, hit.Id)
+	}
+	return ids
+}
+
+func TestSearchIndex(t *testing.T) {
+	idx := newSearchIndex()
+	for _, movie := range testSearchMovies() {
+		idx.add(movie)
+	}
+
+	tests := []struct {
+		query    string
+		expected []int
+	}{
+		{"matrix", []int{1, 3}}, // the title counts for more than the remarks
+		{"matirx", []int{1, 3}},
+		{"hack", []int{3, 1}},
+		{"scifi", []int{1, 2}},
+		{"sci fi anime", []int{2}},
+		{"bebop mars", []int{2}},
+		{"カウボーイビバップ", []int{2}},
+		{"matrix anime", []int{}},
+		{"", []int{}},
+		{"...", []int{}},
+	}
+
+	for _, tt := range tests {
+		if ids := searchIds(idx.search(tt.query)); !reflect.DeepEqual(ids, tt.expected) {
+			t.Errorf("search(%q) returned %v, expected %v", tt.query, ids, tt.expected)
+		}
+	}
+
+	hits := idx.search("matirx")
+	if !hits[0].Words["matrix"] {
+		t.Errorf("Expected the matched word, got %v", hits[0].Words)
+	}
+
+	// Updating a movie replaces its words.
+	updated := testSearchMovies()[0]
+	updated.Name = "Alien (1979)"
+	idx.add(updated)
+
+	if ids := searchIds(idx.search("matrix")); !reflect.DeepEqual(ids, []int{3}) {
+		t.Errorf("search() after an update returned %v, expected [3]", ids)
+	}
+	if ids := searchIds(idx.search("alien")); !reflect.DeepEqual(ids, []int{1}) {
+		t.Errorf("search() after an update returned %v, expected [1]", ids)
+	}
+
+	idx.remove(1)
+	if ids := searchIds(idx.search("alien")); len(ids) != 0 {
+		t.Errorf("search() after remove returned %v", ids)
+	}
+	if _, ok := idx.words["alien"]; ok {
+		t.Errorf("Removed movie left words in the index")
+	}
+}
+
+// fragmentsString writes matches as [word] to make the tests easier to read.
+func fragmentsString(fragments []TextFragment) string {
+	s := strings.Builder{}
+	for _, f := range fragments {
+		if f.Match {
+			s.WriteString("[" + f.Text + "]")
+		} else {
+			s.WriteString(f.Text)
+		}
+	}
+	return s.String()
+}
+
+func TestHighlight(t *testing.T) {
+	words := map[string]bool{"matrix": true, "1999": true}
+
+	fragments := highlight("The Matrix (1999)", words)
+	if s := fragmentsString(fragments); s != "The [Matrix] ([1999])" {
+		t.Errorf("highlight() returned %q", s)
+	}
+
+	if len(highlight("", words)) != 0 {
+		t.Errorf("highlight() of an empty string returned fragments")
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	words := map[string]bool{"needle": true}
+	text := strings.Repeat("hay ", 100) + "needle " + strings.Repeat("straw ", 100)
+
+	s := fragmentsString(snippet(text, words, 40))
+	if !strings.HasPrefix(s, "…hay") || !strings.Contains(s, "[needle]") || !strings.HasSuffix(s, "…") {
+		t.Errorf("snippet() returned %q", s)
+	}
+
+	// Without a match the snippet starts at the beginning.
+	s = fragmentsString(snippet(text, map[string]bool{}, 40))
+	if !strings.HasPrefix(s, "hay hay") || strings.Contains(s, "[") {
+		t.Errorf("snippet() without a match returned %q", s)
+	}
+
+	if s := fragmentsString(snippet("short needle", words, 40)); s != "short [needle]" {
+		t.Errorf("snippet() of a short text returned %q", s)
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	text, tags := parseSearchQuery(`space t:"Sci-Fi" cowboys t:"Anime"`)
+	if text != "space  cowboys" {
+		t.Errorf("Unexpected query text %q", text)
+	}
+	if !reflect.DeepEqual(tags, []string{"Sci-Fi", "Anime"}) {
+		t.Errorf("Unexpected tags %v", tags)
+	}
+}
@@ -27,6 +27,18 @@ func (b *backend) GetCryptRandKey(size int) string {
 	return out
 }
 
+// secretConfigKeys returns the keys of the settings holding credentials,
+// the database encrypts them.
+func secretConfigKeys() []string {
+	keys := []string{"SessionAuth", "SessionEncrypt", "PassSalt", configSessionKeysPrevious}
+	for key, val := range ConfigValues {
+		if val.Type == ConfigStringPriv {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
 // AuthKey, EncryptKey, Salt
 func (b *backend) GetKeys() (string, string, string, error) {
 	authKey, err := b.data.GetCfgString("SessionAuth", "")
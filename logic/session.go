@@ -0,0 +1,243 @@
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/database"
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+// SessionMaxAge is how long a session lasts without being used.  The session
+// cookie lives as long.
+const SessionMaxAge = 30 * 24 * time.Hour
+
+// The last seen time is only saved this often, not on every request.
+const sessionTouchInterval = 5 * time.Minute
+
+// Long user agents are cut off, they are only shown to the user.
+const maxUserAgentLength = 256
+
+// ErrInvalidSession is returned for tokens without a session, and for
+// sessions that expired or whose login method changed.
+var ErrInvalidSession = errors.New("Invalid session")
+
+func newSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSessionToken is what is stored instead of the token, so a copy of the
+// database can't be used to take over sessions.
+func hashSessionToken(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
+
+// authDateHash changes whenever the auth method's Date does, eg when the
+// password is changed.  Sessions started before that are no longer valid.
+// The date is hashed in UTC, it comes back from the database in UTC while a
+// fresh one is in local time.
+func authDateHash(auth *models.AuthMethod) (string, error) {
+	gobbed, err := auth.Date.UTC().GobEncode()
+	if err != nil {
+		return "", fmt.Errorf("Unable to gob Date: %v", err)
+	}
+	return fmt.Sprintf("%X", sha256.Sum256(gobbed)), nil
+}
+
+// NewSession starts a session for the user on a new device and returns the
+// token for the cookie.
+func (b *backend) NewSession(user *models.User, authType models.AuthType, userAgent, address string) (string, error) {
+	auth, err := user.GetAuthMethod(authType)
+	if err != nil {
+		return "", err
+	}
+
+	dateHash, err := authDateHash(auth)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		return "", fmt.Errorf("Unable to generate session token: %v", err)
+	}
+
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		UserId:    user.Id,
+		TokenHash: hashSessionToken(token),
+		AuthType:  authType,
+		AuthDate:  dateHash,
+		UserAgent: userAgent,
+		Address:   address,
+		Created:   now,
+		LastSeen:  now,
+	}
+
+	// Discord logins check the guild membership before they get here.
+	if authType == models.AUTH_DISCORD {
+		session.MemberChecked = now
+	}
+
+	_, err = b.data.AddSession(session)
+	if err != nil {
+		return "", fmt.Errorf("Unable to save session: %v", err)
+	}
+	return token, nil
+}
+
+// GetSession returns the session of the token along with its user.  Invalid
+// sessions are deleted and ErrInvalidSession is returned.
+func (b *backend) GetSession(token string) (*models.Session, *models.User, error) {
+	if token == "" {
+		return nil, nil, ErrInvalidSession
+	}
+
+	session, err := b.data.GetSession(hashSessionToken(token))
+	if err == database.ErrNoValue {
+		return nil, nil, ErrInvalidSession
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if session.Expired(SessionMaxAge, now) {
+		b.dropSession(session, "expired")
+		return nil, nil, ErrInvalidSession
+	}
+
+	user, err := b.data.GetUser(session.UserId)
+	if err != nil {
+		b.dropSession(session, "user not found")
+		return nil, nil, ErrInvalidSession
+	}
+
+	auth, err := user.GetAuthMethod(session.AuthType)
+	if err != nil {
+		b.dropSession(session, "login method removed")
+		return nil, nil, ErrInvalidSession
+	}
+
+	dateHash, err := authDateHash(auth)
+	if err != nil || dateHash != session.AuthDate {
+		b.dropSession(session, "login method changed")
+		return nil, nil, ErrInvalidSession
+	}
+
+	if now.Sub(session.LastSeen) > sessionTouchInterval {
+		session.LastSeen = now
+		if err := b.data.UpdateSession(session); err != nil {
+			b.l.Error("Unable to update session %d: %v", session.Id, err)
+		}
+	}
+
+	return session, user, nil
+}
+
+func (b *backend) dropSession(session *models.Session, reason string) {
+	b.l.Info("Dropping session %d of user %d: %s", session.Id, session.UserId, reason)
+	if err := b.data.DeleteSession(session.Id); err != nil {
+		b.l.Error("Unable to delete session %d: %v", session.Id, err)
+	}
+}
+
+// GetUserSessions returns the sessions of the user, most recently used
+// first.
+func (b *backend) GetUserSessions(user *models.User) ([]*models.Session, error) {
+	return b.data.GetUserSessions(user.Id)
+}
+
+// EndSession deletes the session of the token when the user logs out.
+func (b *backend) EndSession(token string) error {
+	session, err := b.data.GetSession(hashSessionToken(token))
+	if err == database.ErrNoValue {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return b.data.DeleteSession(session.Id)
+}
+
+// RevokeSession logs the user out on one of their devices.
+func (b *backend) RevokeSession(user *models.User, id int) error {
+	sessions, err := b.data.GetUserSessions(user.Id)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.Id == id {
+			return b.data.DeleteSession(id)
+		}
+	}
+	return fmt.Errorf("Session with ID %d not found", id)
+}
+
+// RevokeOtherSessions logs the user out everywhere but the current session.
+func (b *backend) RevokeOtherSessions(user *models.User, current *models.Session) error {
+	sessions, err := b.data.GetUserSessions(user.Id)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if current != nil && session.Id == current.Id {
+			continue
+		}
+		if err := b.data.DeleteSession(session.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AdminRevokeSessions logs the user out on every device, eg when the account
+// has been compromised.
+func (b *backend) AdminRevokeSessions(admin *models.User, user *models.User) error {
+	sessions, err := b.data.GetUserSessions(user.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := b.data.DeleteUserSessions(user.Id); err != nil {
+		return err
+	}
+
+	b.l.Info("Revoked %d sessions of user %s", len(sessions), user)
+	b.audit(admin, models.AUDIT_USER_SESSIONS, "User", user.Id, user.Name, fmt.Sprintf("%d sessions", len(sessions)), "")
+	return nil
+}
+
+// sessionPruneLoop deletes expired sessions once an hour.  Sessions are also
+// checked when they are used, this only keeps the database from growing.
+func (b *backend) sessionPruneLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+
+		count, err := b.data.DeleteSessionsBefore(time.Now().Add(-SessionMaxAge))
+		if err != nil {
+			b.l.Error("Unable to delete expired sessions: %v", err)
+		} else if count > 0 {
+			b.l.Info("Deleted %d expired sessions", count)
+		}
+	}
+}
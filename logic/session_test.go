@@ -0,0 +1,59 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+func TestSessionToken(t *testing.T) {
+	first, err := newSessionToken()
+	if err != nil {
+		t.Fatalf("newSessionToken() returned an error: %v", err)
+	}
+	second, _ := newSessionToken()
+
+	if len(first) != 43 || first == second {
+		t.Errorf("Unexpected tokens %q and %q", first, second)
+	}
+
+	hash := hashSessionToken(first)
+	if hash == first || hash != hashSessionToken(first) || hash == hashSessionToken(second) {
+		t.Errorf("Unexpected hash %q of %q", hash, first)
+	}
+}
+
+func TestAuthDateHash(t *testing.T) {
+	auth := &models.AuthMethod{Type: models.AUTH_LOCAL, Date: time.Date(2020, 10, 19, 8, 0, 0, 0, time.UTC)}
+
+	before, err := authDateHash(auth)
+	if err != nil {
+		t.Fatalf("authDateHash() returned an error: %v", err)
+	}
+
+	// The same date loaded from the database.
+	auth.Date = auth.Date.In(time.FixedZone("", 0))
+	if loaded, _ := authDateHash(auth); loaded != before {
+		t.Errorf("The hash changed with the time zone")
+	}
+
+	// Changing the password updates the date.
+	auth.Date = auth.Date.Add(time.Second)
+	after, _ := authDateHash(auth)
+	if before == after {
+		t.Errorf("The hash didn't change with the date")
+	}
+}
+
+func TestSessionExpired(t *testing.T) {
+	now := time.Date(2020, 10, 19, 8, 0, 0, 0, time.UTC)
+	session := models.Session{LastSeen: now.Add(-SessionMaxAge)}
+
+	if session.Expired(SessionMaxAge, now) {
+		t.Errorf("A session used exactly SessionMaxAge ago expired")
+	}
+	if !session.Expired(SessionMaxAge, now.Add(time.Second)) {
+		t.Errorf("The session didn't expire")
+	}
+}
@@ -0,0 +1,206 @@
+package logic
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/database"
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+// A SessionKey is a pair of keys for the session cookies.  Keys replaced by
+// a rotation are kept until they expire so cookies made with them can still
+// be read.
+type SessionKey struct {
+	Auth    string
+	Encrypt string
+	Expires time.Time // zero for the current keys
+}
+
+// Id identifies the keys without giving them away.
+func (k SessionKey) Id() string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(k.Auth)))[:16]
+}
+
+// Replaced keys are kept as long as a cookie lives, so nobody is logged out
+// by a rotation.  Cookies are saved again with the new keys when they are
+// used.
+const sessionKeyGrace = SessionMaxAge
+
+// Config keys that aren't shown on the config page.  The current keys are
+// kept in SessionAuth and SessionEncrypt, see GetKeys.
+const configSessionKeysPrevious = "SessionKeysPrevious" // JSON list of SessionKey
+const configSessionKeysRotated = "SessionKeysRotated"   // RFC 3339 time of the last rotation
+
+// unexpiredSessionKeys returns the keys that are still valid at now.
+func unexpiredSessionKeys(keys []SessionKey, now time.Time) []SessionKey {
+	valid := []SessionKey{}
+	for _, key := range keys {
+		if key.Expires.After(now) {
+			valid = append(valid, key)
+		}
+	}
+	return valid
+}
+
+// loadSessionKeys reads the current and previous keys into memory.  It is
+// called once GetKeys made sure the current ones exist.
+func (b *backend) loadSessionKeys() error {
+	b.keyLock.Lock()
+	defer b.keyLock.Unlock()
+
+	previous := []SessionKey{}
+	str, err := b.data.GetCfgString(configSessionKeysPrevious, "")
+	if err != nil && !errors.Is(err, database.ErrNoValue) {
+		return fmt.Errorf("Unable to get %s: %v", configSessionKeysPrevious, err)
+	}
+	if str != "" {
+		if err := json.Unmarshal([]byte(str), &previous); err != nil {
+			return fmt.Errorf("Unable to parse %s: %v", configSessionKeysPrevious, err)
+		}
+	}
+
+	b.sessionKeys = append([]SessionKey{{Auth: b.authKey, Encrypt: b.encryptKey}}, unexpiredSessionKeys(previous, time.Now())...)
+	b.keyGeneration++
+
+	// Keys from before rotation was added count as new, otherwise enabling
+	// the schedule would rotate them right away.
+	rotated, err := b.data.GetCfgString(configSessionKeysRotated, "")
+	if err != nil && !errors.Is(err, database.ErrNoValue) {
+		return fmt.Errorf("Unable to get %s: %v", configSessionKeysRotated, err)
+	}
+	b.keysRotated, err = time.Parse(time.RFC3339, rotated)
+	if err != nil {
+		b.keysRotated = time.Now()
+		return b.data.SetCfgString(configSessionKeysRotated, b.keysRotated.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// saveSessionKeys writes the previous keys to the config.  keyLock has to be
+// held.
+func (b *backend) saveSessionKeys() error {
+	raw, err := json.Marshal(b.sessionKeys[1:])
+	if err != nil {
+		return fmt.Errorf("Unable to encode session keys: %v", err)
+	}
+	return b.data.SetCfgString(configSessionKeysPrevious, string(raw))
+}
+
+// GetSessionKeys returns the keys for the session cookies, the current ones
+// first, along with a number that changes whenever the list does.
+func (b *backend) GetSessionKeys() ([]SessionKey, int) {
+	b.keyLock.Lock()
+	defer b.keyLock.Unlock()
+
+	keys := make([]SessionKey, len(b.sessionKeys))
+	copy(keys, b.sessionKeys)
+	return keys, b.keyGeneration
+}
+
+// SessionKeyGeneration returns the number GetSessionKeys does, without
+// copying the keys.
+func (b *backend) SessionKeyGeneration() int {
+	b.keyLock.Lock()
+	defer b.keyLock.Unlock()
+	return b.keyGeneration
+}
+
+// AdminRotateSessionKeys replaces the session keys.  The old keys still work
+// for a while, see sessionKeyGrace.
+func (b *backend) AdminRotateSessionKeys(admin *models.User) error {
+	return b.rotateSessionKeys(admin, time.Now())
+}
+
+func (b *backend) rotateSessionKeys(admin *models.User, now time.Time) error {
+	b.keyLock.Lock()
+	defer b.keyLock.Unlock()
+
+	current := SessionKey{
+		Auth:    b.GetCryptRandKey(64),
+		Encrypt: b.GetCryptRandKey(32),
+	}
+
+	old := b.sessionKeys[0]
+	old.Expires = now.Add(sessionKeyGrace)
+	previous := append([]SessionKey{old}, unexpiredSessionKeys(b.sessionKeys[1:], now)...)
+	b.sessionKeys = append([]SessionKey{current}, previous...)
+	b.keyGeneration++
+
+	// The old keys are saved first.  If saving the new ones fails, the
+	// current keys are still among them.
+	if err := b.saveSessionKeys(); err != nil {
+		return err
+	}
+	if err := b.data.SetCfgString("SessionAuth", current.Auth); err != nil {
+		return fmt.Errorf("Unable to set SessionAuth: %v", err)
+	}
+	if err := b.data.SetCfgString("SessionEncrypt", current.Encrypt); err != nil {
+		return fmt.Errorf("Unable to set SessionEncrypt: %v", err)
+	}
+
+	b.authKey = current.Auth
+	b.encryptKey = current.Encrypt
+	b.keysRotated = now
+	if err := b.data.SetCfgString(configSessionKeysRotated, now.Format(time.RFC3339)); err != nil {
+		b.l.Error("Unable to set %s: %v", configSessionKeysRotated, err)
+	}
+
+	b.l.Info("Rotated the session keys, the old ones are valid until %s", old.Expires.Format(time.RFC3339))
+	b.audit(admin, models.AUDIT_SESSION_KEYS, "Config", 0, "Session keys", old.Id(), current.Id())
+	return nil
+}
+
+// pruneSessionKeys drops previous keys that expired.
+func (b *backend) pruneSessionKeys(now time.Time) error {
+	b.keyLock.Lock()
+	defer b.keyLock.Unlock()
+
+	previous := unexpiredSessionKeys(b.sessionKeys[1:], now)
+	if len(previous) == len(b.sessionKeys)-1 {
+		return nil
+	}
+
+	b.sessionKeys = append(b.sessionKeys[:1], previous...)
+	b.keyGeneration++
+	return b.saveSessionKeys()
+}
+
+// sessionKeyLoop drops expired keys and rotates the keys every
+// SessionKeyRotation days.  Like the session pruning, it runs once an hour.
+func (b *backend) sessionKeyLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		if err := b.pruneSessionKeys(now); err != nil {
+			b.l.Error("Unable to drop expired session keys: %v", err)
+		}
+
+		days, err := b.GetSessionKeyRotation()
+		if err != nil {
+			b.l.Error("Unable to get config value %s: %v", ConfigSessionKeyRotation, err)
+			continue
+		}
+
+		b.keyLock.Lock()
+		due := days > 0 && now.Sub(b.keysRotated) >= time.Duration(days)*24*time.Hour
+		b.keyLock.Unlock()
+
+		if due {
+			if err := b.rotateSessionKeys(nil, now); err != nil {
+				b.l.Error("Unable to rotate the session keys: %v", err)
+			}
+		}
+	}
+}
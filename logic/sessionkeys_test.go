@@ -0,0 +1,130 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+func TestUnexpiredSessionKeys(t *testing.T) {
+	now := time.Date(2020, 10, 19, 8, 0, 0, 0, time.UTC)
+	keys := []SessionKey{
+		{Auth: "expired", Expires: now.Add(-time.Hour)},
+		{Auth: "valid", Expires: now.Add(time.Hour)},
+		{Auth: "now", Expires: now},
+	}
+
+	valid := unexpiredSessionKeys(keys, now)
+	if len(valid) != 1 || valid[0].Auth != "valid" {
+		t.Errorf("Unexpected keys %v", valid)
+	}
+}
+
+func TestSessionKeyId(t *testing.T) {
+	first := SessionKey{Auth: "first", Encrypt: "encrypt"}
+	second := SessionKey{Auth: "second", Encrypt: "encrypt"}
+
+	if len(first.Id()) != 16 || first.Id() == second.Id() {
+		t.Errorf("Unexpected ids %q and %q", first.Id(), second.Id())
+	}
+	if first.Id() == first.Auth {
+		t.Errorf("The id is the key")
+	}
+}
+
+func newTestSessionKeys(t *testing.T) (*backend, func()) {
+	b, cleanup := newTestBackend(t)
+
+	var err error
+	if b.authKey, b.encryptKey, _, err = b.GetKeys(); err != nil {
+		cleanup()
+		t.Fatalf("GetKeys() returned an error: %v", err)
+	}
+	if err := b.loadSessionKeys(); err != nil {
+		cleanup()
+		t.Fatalf("loadSessionKeys() returned an error: %v", err)
+	}
+	return b, cleanup
+}
+
+func TestRotateSessionKeys(t *testing.T) {
+	b, cleanup := newTestSessionKeys(t)
+	defer cleanup()
+
+	now := time.Now()
+	first, generation := b.GetSessionKeys()
+
+	if err := b.rotateSessionKeys(nil, now); err != nil {
+		t.Fatalf("rotateSessionKeys() returned an error: %v", err)
+	}
+
+	keys, newGeneration := b.GetSessionKeys()
+	if newGeneration == generation {
+		t.Errorf("The generation didn't change")
+	}
+	if len(keys) != 2 || keys[0].Auth == first[0].Auth || keys[1].Auth != first[0].Auth {
+		t.Fatalf("Unexpected keys after a rotation: %v", keys)
+	}
+	if !keys[1].Expires.Equal(now.Add(sessionKeyGrace)) {
+		t.Errorf("The old keys expire at %s, expected %s", keys[1].Expires, now.Add(sessionKeyGrace))
+	}
+
+	// The new keys are saved, and the old ones survive a restart.
+	if auth, _, _, _ := b.GetKeys(); auth != keys[0].Auth {
+		t.Errorf("GetKeys() returned the old keys")
+	}
+	if err := b.loadSessionKeys(); err != nil {
+		t.Fatalf("loadSessionKeys() returned an error: %v", err)
+	}
+	if loaded, _ := b.GetSessionKeys(); len(loaded) != 2 || loaded[1].Auth != first[0].Auth {
+		t.Errorf("Unexpected keys after loading them again: %v", loaded)
+	}
+
+	entries, err := b.GetAuditLog(models.AuditFilter{Action: models.AUDIT_SESSION_KEYS})
+	if err != nil || len(entries) != 1 || entries[0].Before != first[0].Id() || entries[0].After != keys[0].Id() {
+		t.Errorf("Unexpected audit log %v, %v", entries, err)
+	}
+}
+
+func TestPruneSessionKeys(t *testing.T) {
+	b, cleanup := newTestSessionKeys(t)
+	defer cleanup()
+
+	now := time.Now()
+	// The first keys expire in an hour, the second ones in 30 days.
+	if err := b.rotateSessionKeys(nil, now.Add(-sessionKeyGrace+time.Hour)); err != nil {
+		t.Fatalf("rotateSessionKeys() returned an error: %v", err)
+	}
+	if err := b.rotateSessionKeys(nil, now); err != nil {
+		t.Fatalf("rotateSessionKeys() returned an error: %v", err)
+	}
+
+	keys, generation := b.GetSessionKeys()
+	if len(keys) != 3 {
+		t.Fatalf("Expected three keys, got %v", keys)
+	}
+
+	// Nothing expired yet.
+	if err := b.pruneSessionKeys(now); err != nil {
+		t.Fatalf("pruneSessionKeys() returned an error: %v", err)
+	}
+	if _, same := b.GetSessionKeys(); same != generation {
+		t.Errorf("pruneSessionKeys() changed the generation without dropping keys")
+	}
+
+	if err := b.pruneSessionKeys(now.Add(2 * time.Hour)); err != nil {
+		t.Fatalf("pruneSessionKeys() returned an error: %v", err)
+	}
+	pruned, newGeneration := b.GetSessionKeys()
+	if len(pruned) != 2 || pruned[0].Auth != keys[0].Auth || pruned[1].Auth != keys[1].Auth || newGeneration == generation {
+		t.Errorf("Unexpected keys after pruning: %v", pruned)
+	}
+
+	if err := b.loadSessionKeys(); err != nil {
+		t.Fatalf("loadSessionKeys() returned an error: %v", err)
+	}
+	if loaded, _ := b.GetSessionKeys(); len(loaded) != 2 {
+		t.Errorf("The dropped keys are still saved: %v", loaded)
+	}
+}
@@ -0,0 +1,328 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/zorchenhimer/MoviePolls/database"
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+// tagAliases maps the key of a common abbreviation to the key of the full
+// name, so both end up in the same duplicate group.
+var tagAliases = map[string]string{
+	"scifi":  "sciencefiction",
+	"romcom": "romanticcomedy",
+}
+
+// tagKey normalizes a tag name for finding near-duplicates.  Case, spaces
+// and punctuation are ignored, so "Sci-Fi", "sci fi" and "Science Fiction"
+// all have the same key.
+func tagKey(name string) string {
+	name = strings.ReplaceAll(strings.ToLower(name), "&", "and")
+
+	key := strings.Builder{}
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			key.WriteRune(r)
+		}
+	}
+
+	if alias, ok := tagAliases[key.String()]; ok {
+		return alias
+	}
+	return key.String()
+}
+
+// TagUsage is a tag with the number of movies that have it.
+type TagUsage struct {
+	Tag    *models.Tag
+	Movies int
+}
+
+// tagUsage counts the movies for every tag.  The result is sorted by name.
+func tagUsage(tags []*models.Tag, movies []*models.Movie) []*TagUsage {
+	counts := map[int]int{}
+	for _, movie := range movies {
+		for _, tag := range movie.Tags {
+			counts[tag.Id]++
+		}
+	}
+
+	usage := []*TagUsage{}
+	for _, tag := range tags {
+		usage = append(usage, &TagUsage{Tag: tag, Movies: counts[tag.Id]})
+	}
+
+	sort.Slice(usage, func(i, k int) bool {
+		return strings.ToLower(usage[i].Tag.Name) < strings.ToLower(usage[k].Tag.Name)
+	})
+	return usage
+}
+
+// duplicateTags groups tags with the same key.  The most used tag of a
+// group comes first, as it is the obvious one to merge the others into.
+func duplicateTags(usage []*TagUsage) [][]*TagUsage {
+	groups := map[string][]*TagUsage{}
+	keys := []string{}
+	for _, u := range usage {
+		key := tagKey(u.Tag.Name)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], u)
+	}
+
+	dupes := [][]*TagUsage{}
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+
+		sort.SliceStable(group, func(i, k int) bool { return group[i].Movies > group[k].Movies })
+		dupes = append(dupes, group)
+	}
+	return dupes
+}
+
+// replaceTags returns the tags with every tag in from replaced by into, which
+// is only added once.  A nil into only removes them.
+func replaceTags(tags []*models.Tag, from map[int]bool, into *models.Tag) ([]*models.Tag, bool) {
+	replaced := false
+	hasInto := false
+	result := []*models.Tag{}
+
+	for _, tag := range tags {
+		if from[tag.Id] {
+			replaced = true
+			continue
+		}
+
+		if into != nil && tag.Id == into.Id {
+			hasInto = true
+		}
+		result = append(result, tag)
+	}
+
+	if replaced && into != nil && !hasInto {
+		result = append(result, into)
+	}
+	return result, replaced
+}
+
+// findOrAddTag returns the tag for name.  An existing tag with the same key
+// is used instead of adding a near-duplicate.  Either way the cleanup leaves
+// it alone until the movie is saved.
+func (b *backend) findOrAddTag(name string) (*models.Tag, error) {
+	tags, err := b.data.GetTags()
+	if err != nil {
+		return nil, err
+	}
+
+	key := tagKey(name)
+	for _, tag := range tags {
+		if tagKey(tag.Name) == key {
+			b.recent.useTag(tag.Id)
+			return tag, nil
+		}
+	}
+
+	tag := &models.Tag{Name: name}
+	tag.Id, err = b.data.AddTag(tag)
+	if err == nil {
+		b.recent.useTag(tag.Id)
+	}
+	return tag, err
+}
+
+func (b *backend) GetTagUsage() ([]*TagUsage, error) {
+	tags, err := b.data.GetTags()
+	if err != nil {
+		return nil, err
+	}
+
+	movies, err := b.data.GetAllMovies()
+	if err != nil {
+		return nil, err
+	}
+
+	return tagUsage(tags, movies), nil
+}
+
+// GetDuplicateTags returns groups of tags that are probably the same, like
+// "Sci-Fi" and "Science Fiction".
+func (b *backend) GetDuplicateTags() ([][]*TagUsage, error) {
+	usage, err := b.GetTagUsage()
+	if err != nil {
+		return nil, err
+	}
+	return duplicateTags(usage), nil
+}
+
+// GetTagMovies returns the tag with the given name and the visible movies
+// that have it, watched ones included.  A nil tag is returned if there is no
+// such tag.
+func (b *backend) GetTagMovies(name string) (*models.Tag, []*models.Movie, error) {
+	id, err := b.data.FindTag(name)
+	if errors.Is(err, database.ErrNoValue) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	tag := b.data.GetTag(id)
+	if tag == nil {
+		return nil, nil, nil
+	}
+
+	movies, err := b.data.GetAllMovies()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	found := []*models.Movie{}
+	for _, movie := range visibleMovies(movies) {
+		for _, t := range movie.Tags {
+			if t.Id == tag.Id {
+				found = append(found, movie)
+				break
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, k int) bool { return strings.ToLower(found[i].Name) < strings.ToLower(found[k].Name) })
+	return tag, found, nil
+}
+
+func (b *backend) AdminRenameTag(admin *models.User, id int, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("Tag name cannot be empty")
+	}
+
+	tag := b.data.GetTag(id)
+	if tag == nil {
+		return fmt.Errorf("Tag with ID %d does not exist", id)
+	}
+
+	other, err := b.data.FindTag(name)
+	if err != nil && !errors.Is(err, database.ErrNoValue) {
+		return err
+	}
+	if err == nil && other != id {
+		return fmt.Errorf("A tag named %q already exists, merge the tags instead", name)
+	}
+
+	old := tag.Name
+	updated := &models.Tag{Id: tag.Id, Name: name}
+	if err := b.data.UpdateTag(updated); err != nil {
+		return err
+	}
+
+	// The search index still has the old name.
+	if err := b.reindexTag(id); err != nil {
+		b.l.Error("Unable to update the search index for tag %d: %v", id, err)
+	}
+
+	b.audit(admin, models.AUDIT_TAG_RENAME, "Tag", id, name, old, name)
+	return nil
+}
+
+// reindexTag updates the search index of every movie with the tag.
+func (b *backend) reindexTag(id int) error {
+	movies, err := b.data.GetAllMovies()
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		for _, tag := range movie.Tags {
+			if tag.Id == id {
+				b.search.add(movie)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// AdminMergeTags moves every movie with one of the given tags over to the
+// into tag and deletes the other tags.
+func (b *backend) AdminMergeTags(admin *models.User, into int, ids []int) error {
+	target := b.data.GetTag(into)
+	if target == nil {
+		return fmt.Errorf("Tag with ID %d does not exist", into)
+	}
+
+	from := map[int]bool{}
+	names := []string{}
+	for _, id := range ids {
+		if id == into || from[id] {
+			continue
+		}
+
+		tag := b.data.GetTag(id)
+		if tag == nil {
+			return fmt.Errorf("Tag with ID %d does not exist", id)
+		}
+		from[id] = true
+		names = append(names, tag.Name)
+	}
+
+	if len(from) == 0 {
+		return fmt.Errorf("No tags to merge")
+	}
+
+	if err := b.retagMovies(from, target); err != nil {
+		return err
+	}
+
+	for id := range from {
+		b.data.DeleteTag(id)
+	}
+
+	b.audit(admin, models.AUDIT_TAG_MERGE, "Tag", target.Id, target.Name, strings.Join(names, ", "), target.Name)
+	return nil
+}
+
+// AdminDeleteTag removes the tag from every movie and deletes it.
+func (b *backend) AdminDeleteTag(admin *models.User, id int) error {
+	tag := b.data.GetTag(id)
+	if tag == nil {
+		return fmt.Errorf("Tag with ID %d does not exist", id)
+	}
+
+	if err := b.retagMovies(map[int]bool{id: true}, nil); err != nil {
+		return err
+	}
+
+	b.data.DeleteTag(id)
+	b.audit(admin, models.AUDIT_TAG_DELETE, "Tag", id, tag.Name, tag.Name, "")
+	return nil
+}
+
+// retagMovies replaces the from tags with into on every movie.  A nil into
+// only removes them.
+func (b *backend) retagMovies(from map[int]bool, into *models.Tag) error {
+	movies, err := b.data.GetAllMovies()
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		var replaced bool
+		movie.Tags, replaced = replaceTags(movie.Tags, from, into)
+		if !replaced {
+			continue
+		}
+
+		if err := b.UpdateMovie(movie); err != nil {
+			return fmt.Errorf("Unable to update movie %d: %v", movie.Id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package logic
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/zorchenhimer/MoviePolls/database"
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+func TestTagKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Sci-Fi", "Science Fiction", true},
+		{"sci fi", "SciFi", true},
+		{"Action & Adventure", "Action and Adventure", true},
+		{"Rom-Com", "Romantic Comedy", true},
+		{"Comedy", "Romantic Comedy", false},
+		{"Drama", "Dramas", false},
+	}
+
+	for _, tt := range tests {
+		if same := tagKey(tt.a) == tagKey(tt.b); same != tt.same {
+			t.Errorf("%q and %q: expected same=%v, keys are %q and %q", tt.a, tt.b, tt.same, tagKey(tt.a), tagKey(tt.b))
+		}
+	}
+}
+
+func TestDuplicateTags(t *testing.T) {
+	scifi := &models.Tag{Id: 1, Name: "Sci-Fi"}
+	science := &models.Tag{Id: 2, Name: "Science Fiction"}
+	drama := &models.Tag{Id: 3, Name: "Drama"}
+	scienceLower := &models.Tag{Id: 4, Name: "science-fiction"}
+
+	movies := []*models.Movie{
+		{Id: 1, Tags: []*models.Tag{scifi, drama}},
+		{Id: 2, Tags: []*models.Tag{science}},
+		{Id: 3, Tags: []*models.Tag{science, drama}},
+	}
+
+	usage := tagUsage([]*models.Tag{scifi, science, drama, scienceLower}, movies)
+
+	names := []string{}
+	for _, u := range usage {
+		names = append(names, u.Tag.Name)
+	}
+	if expected := []string{"Drama", "Sci-Fi", "Science Fiction", "science-fiction"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("tagUsage() is sorted as %v, expected %v", names, expected)
+	}
+
+	dupes := duplicateTags(usage)
+	if len(dupes) != 1 {
+		t.Fatalf("Expected one group of duplicates, got %d", len(dupes))
+	}
+
+	// The most used tag comes first.
+	group := []*models.Tag{}
+	counts := []int{}
+	for _, u := range dupes[0] {
+		group = append(group, u.Tag)
+		counts = append(counts, u.Movies)
+	}
+
+	if !reflect.DeepEqual(group, []*models.Tag{science, scifi, scienceLower}) || !reflect.DeepEqual(counts, []int{2, 1, 0}) {
+		t.Errorf("Unexpected duplicate group %v with counts %v", group, counts)
+	}
+}
+
+func TestReplaceTags(t *testing.T) {
+	scifi := &models.Tag{Id: 1, Name: "Sci-Fi"}
+	science := &models.Tag{Id: 2, Name: "Science Fiction"}
+	drama := &models.Tag{Id: 3, Name: "Drama"}
+	from := map[int]bool{scifi.Id: true}
+
+	tests := []struct {
+		name     string
+		tags     []*models.Tag
+		into     *models.Tag
+		expected []*models.Tag
+		replaced bool
+	}{
+		{"replace", []*models.Tag{scifi, drama}, science, []*models.Tag{drama, science}, true},
+		{"already has target", []*models.Tag{science, scifi}, science, []*models.Tag{science}, true},
+		{"untouched", []*models.Tag{drama}, science, []*models.Tag{drama}, false},
+		{"delete", []*models.Tag{scifi, drama}, nil, []*models.Tag{drama}, true},
+	}
+
+	for _, tt := range tests {
+		tags, replaced := replaceTags(tt.tags, from, tt.into)
+		if replaced != tt.replaced || !reflect.DeepEqual(tags, tt.expected) {
+			t.Errorf("%s: replaceTags() returned %v, %v, expected %v, %v", tt.name, tags, replaced, tt.expected, tt.replaced)
+		}
+	}
+}
+
+// brokenTags fails to look up tags.
+type brokenTags struct {
+	database.Database
+}
+
+func (brokenTags) FindTag(name string) (int, error) {
+	return 0, errors.New("disk on fire")
+}
+
+func TestGetTagMovies(t *testing.T) {
+	b, cleanup := newTestBackend(t)
+	defer cleanup()
+
+	drama := &models.Tag{Name: "Drama"}
+	id, err := b.data.AddTag(drama)
+	if err != nil {
+		t.Fatalf("AddTag() returned an error: %v", err)
+	}
+	drama.Id = id
+
+	movies := []*models.Movie{
+		{Name: "Zodiac", Tags: []*models.Tag{drama}},
+		{Name: "Amadeus", Tags: []*models.Tag{drama}},
+		{Name: "Pending", Tags: []*models.Tag{drama}, Pending: true},
+		{Name: "Untagged"},
+	}
+	for _, movie := range movies {
+		if _, err := b.data.AddMovie(movie); err != nil {
+			t.Fatalf("AddMovie() returned an error: %v", err)
+		}
+	}
+
+	tag, found, err := b.GetTagMovies("drama")
+	if err != nil || tag == nil || tag.Id != drama.Id {
+		t.Fatalf("GetTagMovies() returned %v, %v", tag, err)
+	}
+
+	names := []string{}
+	for _, movie := range found {
+		names = append(names, movie.Name)
+	}
+	if expected := []string{"Amadeus", "Zodiac"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("GetTagMovies() returned %v, expected %v", names, expected)
+	}
+
+	// A missing tag is not an error.
+	if tag, found, err := b.GetTagMovies("Western"); tag != nil || found != nil || err != nil {
+		t.Errorf("GetTagMovies() returned %v, %v, %v for a missing tag", tag, found, err)
+	}
+
+	// Other errors are passed on instead of looking like a missing tag.
+	b.data = brokenTags{b.data}
+	if _, _, err := b.GetTagMovies("Drama"); err == nil {
+		t.Errorf("GetTagMovies() hid a database error")
+	}
+}
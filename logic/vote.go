@@ -9,7 +9,12 @@ import (
 )
 
 func (b *backend) AddVote(userid int, movieid int) error {
-	return b.data.AddVote(userid, movieid)
+	if err := b.data.AddVote(userid, movieid); err != nil {
+		return err
+	}
+
+	metricVotes.Inc()
+	return nil
 }
 
 func (b *backend) DeleteVote(userid int, movieid int) error {
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/zorchenhimer/MoviePolls/database"
 	"github.com/zorchenhimer/MoviePolls/logger"
 	"github.com/zorchenhimer/MoviePolls/logic"
+	"github.com/zorchenhimer/MoviePolls/secrets"
+	"github.com/zorchenhimer/MoviePolls/storage"
 	"github.com/zorchenhimer/MoviePolls/web"
 )
 
@@ -17,24 +23,104 @@ var ReleaseVersion string
 func main() {
 	var logFile string
 	var logLevel string
+	var logLevels string
+	var logFormat string
+	var logMaxSize int64
+	var logRotate time.Duration
+	var logBackups int
+	var logCompress bool
 	var debug bool
 	var version bool
+	var posterStore string
+	var posterConn string
+	var metricsToken string
+	var shutdownTimeout time.Duration
+	var shutdownDrain time.Duration
+	var listen string
+	var tlsCert string
+	var tlsKey string
+	var acmeDomains string
+	var acmeCache string
+	var acmeEmail string
+	var httpRedirect string
+	var basePath string
+	var trustedProxies string
+	var masterKeyFile string
+	var oldMasterKeyFile string
+	var reencrypt bool
 
 	flag.StringVar(&logFile, "logfile", "logs/server.log", "File to write logs")
 	flag.StringVar(&logLevel, "loglevel", "debug", "Log verbosity")
+	flag.StringVar(&logLevels, "loglevels", "", "Log verbosity of single subsystems, eg database=error,oauth=debug")
+	flag.StringVar(&logFormat, "logformat", "text", "Log format (text or json)")
+	flag.Int64Var(&logMaxSize, "logmaxsize", 10, "Rotate the log file once it is bigger than this many MB (0 disables)")
+	flag.DurationVar(&logRotate, "logrotate", 0, "Rotate the log file this often, eg 24h (0 disables)")
+	flag.IntVar(&logBackups, "logbackups", 10, "Number of rotated log files to keep (0 keeps all)")
+	flag.BoolVar(&logCompress, "logcompress", true, "Compress rotated log files")
 	flag.BoolVar(&debug, "debug", false, "Enable debug code")
 	flag.BoolVar(&version, "version", true, "Show the version of the binary file")
+	flag.StringVar(&posterStore, "posterstore", "fs", "Where posters are saved (fs or s3)")
+	flag.StringVar(&posterConn, "posterconn", "posters", "Poster directory for fs, or the bucket URL for s3")
+	flag.DurationVar(&shutdownTimeout, "shutdowntimeout", 30*time.Second, "How long running requests and jobs are waited for on shutdown")
+	flag.DurationVar(&shutdownDrain, "shutdowndrain", 5*time.Second, "How long /readyz fails before the server stops accepting connections on shutdown")
+	flag.StringVar(&metricsToken, "metricstoken", "", "Bearer token required for /metrics (also read from MOVIEPOLLS_METRICS_TOKEN)")
+	flag.StringVar(&listen, "listen", ":8090", "Address to listen on, eg :443 with TLS")
+	flag.StringVar(&tlsCert, "tlscert", "", "TLS certificate file, turns on HTTPS")
+	flag.StringVar(&tlsKey, "tlskey", "", "TLS key file")
+	flag.StringVar(&acmeDomains, "acmedomains", "", "Comma separated domains to get Let's Encrypt certificates for, turns on HTTPS")
+	flag.StringVar(&acmeCache, "acmecache", "certs", "Directory to keep the Let's Encrypt certificates in")
+	flag.StringVar(&acmeEmail, "acmeemail", "", "Contact address for the Let's Encrypt account")
+	flag.StringVar(&httpRedirect, "httpredirect", "", "Address of a plain HTTP listener redirecting to HTTPS, eg :80")
+	flag.StringVar(&basePath, "basepath", "", "Path the site is hosted under, eg /movies")
+	flag.StringVar(&trustedProxies, "trustedproxies", "", "Comma separated IPs and CIDR ranges of reverse proxies whose X-Forwarded-* headers are used")
+	flag.StringVar(&masterKeyFile, "masterkeyfile", "", "File with the base64 master key encrypting OAuth tokens and secrets (also read from MOVIEPOLLS_MASTER_KEY)")
+	flag.StringVar(&oldMasterKeyFile, "oldmasterkeyfile", "", "File with the previous master key for -reencrypt (also read from MOVIEPOLLS_OLD_MASTER_KEY)")
+	flag.BoolVar(&reencrypt, "reencrypt", false, "Encrypt the secrets again with the current master key and exit")
 	flag.Parse()
 
-	log, err := logger.NewLogger(logger.LogLevel(logLevel), logFile)
+	if metricsToken == "" {
+		metricsToken = os.Getenv("MOVIEPOLLS_METRICS_TOKEN")
+	}
+
+	levels, err := logger.ParseLevels(logLevels)
+	if err != nil {
+		fmt.Printf("Unable to load logger: %v\n", err)
+		os.Exit(1)
+	}
+
+	log, err := logger.New(logger.Options{
+		Level:  logger.LogLevel(logLevel),
+		Levels: levels,
+		Format: logger.Format(logFormat),
+		File:   logFile,
+		Rotate: logger.RotateOptions{
+			MaxSize:    logMaxSize * 1024 * 1024,
+			Interval:   logRotate,
+			MaxBackups: logBackups,
+			Compress:   logCompress,
+		},
+	})
 	if err != nil {
 		fmt.Printf("Unable to load logger: %v\n", err)
 		os.Exit(1)
 	}
 
 	config := web.Options{
-		Debug:  debug,
-		Listen: ":8090",
+		Debug:        debug,
+		Listen:       listen,
+		MetricsToken: metricsToken,
+
+		TLSCert:      tlsCert,
+		TLSKey:       tlsKey,
+		ACMEDomains:  splitList(acmeDomains),
+		ACMECache:    acmeCache,
+		ACMEEmail:    acmeEmail,
+		HTTPRedirect: httpRedirect,
+
+		BasePath:       basePath,
+		TrustedProxies: splitList(trustedProxies),
+
+		ShutdownDrain: shutdownDrain,
 	}
 
 	if version {
@@ -44,15 +130,50 @@ func main() {
 		log.Info("Debug mode turned on")
 	}
 
+	masterKey, err := secrets.ReadMasterKey(masterKeyFile, "MOVIEPOLLS_MASTER_KEY")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if reencrypt {
+		oldMasterKey, err := secrets.ReadMasterKey(oldMasterKeyFile, "MOVIEPOLLS_OLD_MASTER_KEY")
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		err = database.Reencrypt("json", "db/data.json", oldMasterKey, masterKey, log.Sub("database"))
+		if err != nil {
+			fmt.Printf("Unable to encrypt the secrets again: %v\n", err)
+			os.Exit(1)
+		}
+
+		if masterKey == nil {
+			fmt.Println("Secrets saved in plain text")
+		} else {
+			fmt.Println("Secrets encrypted with the new master key")
+		}
+		log.Close()
+		return
+	}
+
 	// init database
-	data, err := database.GetDatabase("json", "db/data.json", log)
+	data, err := database.GetDatabase("json", "db/data.json", masterKey, log.Sub("database"))
 	if err != nil {
 		fmt.Printf("Unable to load json data: %v\n", err)
 		os.Exit(1)
 	}
 
+	// init poster storage
+	posters, err := storage.GetPosterStore(posterStore, posterConn, log)
+	if err != nil {
+		fmt.Printf("Unable to load poster store: %v\n", err)
+		os.Exit(1)
+	}
+
 	// init logic
-	backend, err := logic.New(data, log)
+	backend, err := logic.New(data, posters, log)
 	if err != nil {
 		fmt.Printf("Unable to load backend: %v\n", err)
 		os.Exit(1)
@@ -66,11 +187,47 @@ func main() {
 	}
 
 	// run frontend
-	err = frontend.ListenAndServe()
-	if err != http.ErrServerClosed {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- frontend.ListenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err = <-serveErr:
 		fmt.Printf("Error serving: %v\n", err)
+		backend.Close(context.Background())
 		os.Exit(1)
+
+	case sig := <-signals:
+		// A second signal kills the server right away.
+		signal.Reset(os.Interrupt, syscall.SIGTERM)
+		log.Info("Received %s, shutting down", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := frontend.Shutdown(ctx); err != nil {
+		log.Error("Requests still running at shutdown: %v", err)
 	}
+	if err := backend.Close(ctx); err != nil {
+		log.Error("Unable to close the backend: %v", err)
+	}
+	log.Close()
 
 	fmt.Println("goodbye")
 }
+
+// splitList splits a comma separated flag value, ignoring empty entries.
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Handler serves the metrics of the Default registry.  If token isn't
+// empty, requests need an "Authorization: Bearer <token>" header.
+func Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && !validBearer(r.Header.Get("Authorization"), token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Default.WriteTo(w)
+	})
+}
+
+func validBearer(header, token string) bool {
+	const prefix = "bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return false
+	}
+
+	given := strings.TrimSpace(header[len(prefix):])
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
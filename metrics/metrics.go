@@ -0,0 +1,298 @@
+// Package metrics keeps counters, gauges and histograms and serves them in
+// the Prometheus text format.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the histogram buckets for durations in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry served by Handler.  The package level New*
+// functions add their metrics to it.
+var Default = NewRegistry()
+
+type metric interface {
+	write(buf *bytes.Buffer)
+}
+
+// Registry is a set of uniquely named metrics.
+type Registry struct {
+	lock    sync.Mutex
+	metrics map[string]metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+// register panics if the name is taken, metrics are registered once when
+// the program starts.
+func (r *Registry) register(name string, m metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metric %s registered twice", name))
+	}
+	r.metrics[name] = m
+}
+
+// WriteTo writes every metric in the Prometheus text format, sorted by name.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.lock.Lock()
+	names := []string{}
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := &bytes.Buffer{}
+	for _, name := range names {
+		r.metrics[name].write(buf)
+	}
+	r.lock.Unlock()
+
+	return buf.WriteTo(w)
+}
+
+// series holds the values of one combination of label values.
+type series struct {
+	labels []string
+	value  float64
+
+	// histograms only
+	buckets []uint64
+	count   uint64
+}
+
+// family is the part shared by every metric type: the name, help text,
+// label names and the series by label values.
+type family struct {
+	lock   sync.Mutex
+	name   string
+	help   string
+	kind   string
+	labels []string
+	series map[string]*series
+}
+
+func newFamily(name, help, kind string, labels []string) family {
+	return family{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: map[string]*series{},
+	}
+}
+
+// get returns the series of the label values, creating it if needed.  The
+// caller must hold the lock.
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s takes %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\x00")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: append([]string{}, values...)}
+		f.series[key] = s
+	}
+	return s
+}
+
+// sorted returns the series ordered by their label values.  The caller must
+// hold the lock.
+func (f *family) sorted() []*series {
+	keys := []string{}
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	list := []*series{}
+	for _, key := range keys {
+		list = append(list, f.series[key])
+	}
+	return list
+}
+
+func (f *family) writeHeader(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(buf, "# TYPE %s %s\n", f.name, f.kind)
+}
+
+// labelString formats the labels of s, with an optional extra label, eg
+// {handler="/",le="0.5"}.
+func (f *family) labelString(s *series, extraName, extraValue string) string {
+	pairs := []string{}
+	for i, name := range f.labels {
+		pairs = append(pairs, name+`="`+escapeLabel(s.labels[i])+`"`)
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+escapeLabel(extraValue)+`"`)
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a value that only goes up, like the number of requests.
+type Counter struct {
+	family
+}
+
+// NewCounter registers a counter with the given label names in Default.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return Default.NewCounter(name, help, labels...)
+}
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newFamily(name, help, "counter", labels)}
+	if len(labels) == 0 {
+		// Without labels there is only one series, and it starts at zero.
+		c.get(nil)
+	}
+	r.register(name, c)
+	return c
+}
+
+// Inc adds one to the series of the label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("counter %s cannot decrease", c.name))
+	}
+
+	c.lock.Lock()
+	c.get(labelValues).value += v
+	c.lock.Unlock()
+}
+
+func (c *Counter) write(buf *bytes.Buffer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.writeHeader(buf)
+	for _, s := range c.sorted() {
+		fmt.Fprintf(buf, "%s%s %s\n", c.name, c.labelString(s, "", ""), formatFloat(s.value))
+	}
+}
+
+// GaugeFunc is a value that is read when the metrics are collected, like
+// the number of active users.
+type GaugeFunc struct {
+	family
+	fn func() float64
+}
+
+// NewGaugeFunc registers a gauge in Default.
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return Default.NewGaugeFunc(name, help, fn)
+}
+
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{newFamily(name, help, "gauge", nil), fn}
+	r.register(name, g)
+	return g
+}
+
+func (g *GaugeFunc) write(buf *bytes.Buffer) {
+	g.writeHeader(buf)
+	fmt.Fprintf(buf, "%s %s\n", g.name, formatFloat(g.fn()))
+}
+
+// Histogram counts observations, like request durations, in buckets.
+type Histogram struct {
+	family
+	bounds []float64
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds,
+// which have to be sorted, in Default.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{newFamily(name, help, "histogram", labels), buckets}
+	r.register(name, h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	s := h.get(labelValues)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(h.bounds))
+	}
+
+	for i, bound := range h.bounds {
+		if v <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+// ObserveSince observes the seconds passed since start.
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *Histogram) write(buf *bytes.Buffer) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.writeHeader(buf)
+	for _, s := range h.sorted() {
+		for i, bound := range h.bounds {
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", h.name, h.labelString(s, "le", formatFloat(bound)), s.buckets[i])
+		}
+		fmt.Fprintf(buf, "%s_bucket%s %d\n", h.name, h.labelString(s, "le", "+Inf"), s.count)
+		fmt.Fprintf(buf, "%s_sum%s %s\n", h.name, h.labelString(s, "", ""), formatFloat(s.value))
+		fmt.Fprintf(buf, "%s_count%s %d\n", h.name, h.labelString(s, "", ""), s.count)
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
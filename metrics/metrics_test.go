@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func registryString(r *Registry) string {
+	buf := &bytes.Buffer{}
+	r.WriteTo(buf)
+	return buf.String()
+}
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	plain := r.NewCounter("test_votes_total", "Votes cast.")
+	labeled := r.NewCounter("test_requests_total", "Requests.", "handler", "code")
+
+	plain.Inc()
+	plain.Add(2)
+	labeled.Inc("/vote/", "303")
+	labeled.Inc("/", "200")
+	labeled.Inc("/", "200")
+	labeled.Inc("/q\"\\\n", "404")
+
+	expected := `# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{handler="/",code="200"} 2
+test_requests_total{handler="/q\"\\\n",code="404"} 1
+test_requests_total{handler="/vote/",code="303"} 1
+# HELP test_votes_total Votes cast.
+# TYPE test_votes_total counter
+test_votes_total 3
+`
+	if out := registryString(r); out != expected {
+		t.Errorf("Unexpected output:\n%s\nexpected:\n%s", out, expected)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("test_duration_seconds", "Durations.", []float64{0.1, 1}, "op")
+
+	h.Observe(0.05, "save")
+	h.Observe(0.5, "save")
+	h.Observe(3, "save")
+
+	expected := `# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{op="save",le="0.1"} 1
+test_duration_seconds_bucket{op="save",le="1"} 2
+test_duration_seconds_bucket{op="save",le="+Inf"} 3
+test_duration_seconds_sum{op="save"} 3.55
+test_duration_seconds_count{op="save"} 3
+`
+	if out := registryString(r); out != expected {
+		t.Errorf("Unexpected output:\n%s\nexpected:\n%s", out, expected)
+	}
+}
+
+func TestGaugeFunc(t *testing.T) {
+	r := NewRegistry()
+	value := 4.0
+	r.NewGaugeFunc("test_active_users", "Active users.", func() float64 { return value })
+
+	if out := registryString(r); !strings.HasSuffix(out, "\ntest_active_users 4\n") {
+		t.Errorf("Unexpected output: %q", out)
+	}
+
+	value = 2
+	if out := registryString(r); !strings.HasSuffix(out, "\ntest_active_users 2\n") {
+		t.Errorf("The gauge wasn't read again: %q", out)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Registering a name twice didn't panic")
+		}
+	}()
+	r.NewCounter("test_total", "")
+}
+
+func TestHandlerToken(t *testing.T) {
+	tests := []struct {
+		token    string
+		header   string
+		expected int
+	}{
+		{"", "", http.StatusOK},
+		{"secret", "", http.StatusUnauthorized},
+		{"secret", "Bearer wrong", http.StatusUnauthorized},
+		{"secret", "Basic secret", http.StatusUnauthorized},
+		{"secret", "Bearer secret", http.StatusOK},
+		{"secret", "bearer secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+
+		rec := httptest.NewRecorder()
+		Handler(tt.token).ServeHTTP(rec, req)
+		if rec.Code != tt.expected {
+			t.Errorf("Token %q with header %q returned %d, expected %d", tt.token, tt.header, rec.Code, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AUDIT_USER_DELETE   AuditAction = "UserDelete"
+	AUDIT_USER_PURGE    AuditAction = "UserPurge"
+	AUDIT_USER_BAN      AuditAction = "UserBan"
+	AUDIT_USER_UNBAN    AuditAction = "UserUnban"
+	AUDIT_USER_ROLE     AuditAction = "UserRole"
+	AUDIT_USER_PASSWORD AuditAction = "UserPasswordReset"
+	AUDIT_USER_SESSIONS AuditAction = "UserSessionsRevoke"
+	AUDIT_MOVIE_REMOVE  AuditAction = "MovieRemove"
+	AUDIT_MOVIE_APPROVE AuditAction = "MovieApprove"
+	AUDIT_MOVIE_DENY    AuditAction = "MovieDeny"
+	AUDIT_MOVIE_REFRESH AuditAction = "MovieRefresh"
+	AUDIT_CONFIG_CHANGE AuditAction = "ConfigChange"
+	AUDIT_CYCLE_START   AuditAction = "CycleStart"
+	AUDIT_CYCLE_UPDATE  AuditAction = "CycleUpdate"
+	AUDIT_CYCLE_END     AuditAction = "CycleEnd"
+	AUDIT_ROLE_ADD      AuditAction = "RoleAdd"
+	AUDIT_ROLE_UPDATE   AuditAction = "RoleUpdate"
+	AUDIT_ROLE_DELETE   AuditAction = "RoleDelete"
+	AUDIT_TAG_RENAME    AuditAction = "TagRename"
+	AUDIT_TAG_MERGE     AuditAction = "TagMerge"
+	AUDIT_TAG_DELETE    AuditAction = "TagDelete"
+	AUDIT_CLEANUP       AuditAction = "Cleanup"
+	AUDIT_LOG_LEVEL     AuditAction = "LogLevel"
+	AUDIT_SESSION_KEYS  AuditAction = "SessionKeyRotate"
+)
+
+// All audit actions, in display order.
+var AuditActions = []AuditAction{
+	AUDIT_USER_DELETE,
+	AUDIT_USER_PURGE,
+	AUDIT_USER_BAN,
+	AUDIT_USER_UNBAN,
+	AUDIT_USER_ROLE,
+	AUDIT_USER_PASSWORD,
+	AUDIT_USER_SESSIONS,
+	AUDIT_MOVIE_REMOVE,
+	AUDIT_MOVIE_APPROVE,
+	AUDIT_MOVIE_DENY,
+	AUDIT_MOVIE_REFRESH,
+	AUDIT_CONFIG_CHANGE,
+	AUDIT_CYCLE_START,
+	AUDIT_CYCLE_UPDATE,
+	AUDIT_CYCLE_END,
+	AUDIT_ROLE_ADD,
+	AUDIT_ROLE_UPDATE,
+	AUDIT_ROLE_DELETE,
+	AUDIT_TAG_RENAME,
+	AUDIT_TAG_MERGE,
+	AUDIT_TAG_DELETE,
+	AUDIT_CLEANUP,
+	AUDIT_LOG_LEVEL,
+	AUDIT_SESSION_KEYS,
+}
+
+// An AuditEntry records a single administrative action.  The actor's name is
+// copied so the entry stays readable after the actor's account is gone.
+type AuditEntry struct {
+	Id        int
+	Timestamp time.Time
+	ActorId   int
+	ActorName string
+	Action    AuditAction
+
+	TargetType string // "User", "Movie", "Config", "Cycle" or "Role"
+	TargetId   int
+	TargetName string
+
+	Before string
+	After  string
+}
+
+func (a AuditEntry) Target() string {
+	if a.TargetId == 0 {
+		return fmt.Sprintf("%s %s", a.TargetType, a.TargetName)
+	}
+	if a.TargetName == "" {
+		return fmt.Sprintf("%s %d", a.TargetType, a.TargetId)
+	}
+	return fmt.Sprintf("%s %d (%s)", a.TargetType, a.TargetId, a.TargetName)
+}
+
+// AuditFilter selects audit entries.  Zero values match everything.
+type AuditFilter struct {
+	Actor  string // case insensitive substring of the actor's name
+	Action AuditAction
+	Target string // case insensitive substring of Target()
+	Since  *time.Time
+	Until  *time.Time
+}
+
+func (f AuditFilter) Matches(a *AuditEntry) bool {
+	if f.Actor != "" && !strings.Contains(strings.ToLower(a.ActorName), strings.ToLower(f.Actor)) {
+		return false
+	}
+
+	if f.Action != "" && a.Action != f.Action {
+		return false
+	}
+
+	if f.Target != "" && !strings.Contains(strings.ToLower(a.Target()), strings.ToLower(f.Target)) {
+		return false
+	}
+
+	if f.Since != nil && a.Timestamp.Before(*f.Since) {
+		return false
+	}
+
+	if f.Until != nil && !a.Timestamp.Before(*f.Until) {
+		return false
+	}
+
+	return true
+}
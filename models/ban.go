@@ -0,0 +1,72 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// A Ban keeps a user from voting, adding movies and creating new accounts.
+// Banned users can still browse the site.  The ban matches on the user's ID,
+// their email and every external OAuth ID they had at the time of the ban.
+type Ban struct {
+	Id       int
+	UserId   int
+	Email    string
+	ExtIds   []BanExtId
+	Reason   string
+	Created  time.Time
+	Expires  *time.Time // nil for a permanent ban
+	BannedBy int
+}
+
+type BanExtId struct {
+	Type  AuthType
+	ExtId string
+}
+
+// Active returns false once the ban has expired.
+func (b Ban) Active() bool {
+	return b.Expires == nil || time.Now().Before(*b.Expires)
+}
+
+// MatchesOauth returns true if the given external ID is covered by this ban.
+func (b Ban) MatchesOauth(extId string, authType AuthType) bool {
+	for _, e := range b.ExtIds {
+		if e.Type == authType && e.ExtId == extId {
+			return true
+		}
+	}
+	return false
+}
+
+func (b Ban) String() string {
+	expires := "never"
+	if b.Expires != nil {
+		expires = b.Expires.Format("2006-01-02 15:04")
+	}
+	return fmt.Sprintf("Ban{Id:%d UserId:%d Expires:%s}", b.Id, b.UserId, expires)
+}
+
+// Message returns the text shown to the banned user.
+func (b Ban) Message() string {
+	msg := "You have been banned"
+	if b.Expires != nil {
+		msg += " until " + b.Expires.Format("Jan 2, 2006 15:04 MST")
+	}
+	if b.Reason != "" {
+		msg += ". Reason: " + b.Reason
+	}
+	return msg
+}
+
+// Summary describes the ban for the admin pages and the audit log.
+func (b Ban) Summary() string {
+	msg := "Permanent"
+	if b.Expires != nil {
+		msg = "Until " + b.Expires.Format("2006-01-02 15:04")
+	}
+	if b.Reason != "" {
+		msg += ": " + b.Reason
+	}
+	return msg
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBanActive(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		expires *time.Time
+		active  bool
+	}{
+		{"permanent", nil, true},
+		{"expired", &past, false},
+		{"temporary", &future, true},
+	}
+
+	for _, tt := range tests {
+		ban := Ban{Expires: tt.expires}
+		if active := ban.Active(); active != tt.active {
+			t.Errorf("%s ban: Active() returned %v", tt.name, active)
+		}
+	}
+}
+
+func TestBanMatchesOauth(t *testing.T) {
+	ban := Ban{
+		ExtIds: []BanExtId{
+			{Type: AUTH_DISCORD, ExtId: "1234"},
+			{Type: AUTH_TWITCH, ExtId: "abcd"},
+		},
+	}
+
+	tests := []struct {
+		extId    string
+		authType AuthType
+		matches  bool
+	}{
+		{"1234", AUTH_DISCORD, true},
+		{"abcd", AUTH_TWITCH, true},
+		{"1234", AUTH_TWITCH, false}, // same ID on another site
+		{"abcd", AUTH_PATREON, false},
+		{"5678", AUTH_DISCORD, false},
+		{"", AUTH_DISCORD, false},
+	}
+
+	for _, tt := range tests {
+		if matches := ban.MatchesOauth(tt.extId, tt.authType); matches != tt.matches {
+			t.Errorf("MatchesOauth(%q, %s) returned %v", tt.extId, tt.authType, matches)
+		}
+	}
+
+	if (Ban{}).MatchesOauth("1234", AUTH_DISCORD) {
+		t.Errorf("A ban without external IDs matched")
+	}
+}
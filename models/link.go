@@ -89,10 +89,30 @@ func (l *Link) determineLinkType() error {
 		l.Type = "IMDb"
 		return nil
 	}
+	if strings.Contains(l.Url, "themoviedb.org") {
+		l.Type = "TMDB"
+		return nil
+	}
 	if strings.Contains(l.Url, "myanimelist") {
 		l.Type = "MyAnimeList"
 		return nil
 	}
+	if strings.Contains(l.Url, "letterboxd.com") || strings.Contains(l.Url, "boxd.it") {
+		l.Type = "Letterboxd"
+		return nil
+	}
+	if strings.Contains(l.Url, "anilist.co") {
+		l.Type = "AniList"
+		return nil
+	}
+	if strings.Contains(l.Url, "kitsu.io") || strings.Contains(l.Url, "kitsu.app") {
+		l.Type = "Kitsu"
+		return nil
+	}
+	if strings.Contains(l.Url, "thetvdb.com") {
+		l.Type = "TVDB"
+		return nil
+	}
 
 	l.Type = "Misc"
 	return nil
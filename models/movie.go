@@ -2,30 +2,37 @@ package models
 
 import (
 	"fmt"
+	"regexp"
 	//"time"
 	"sort"
+	"strings"
 )
 
 type Movie struct {
-	Id          int
-	Name        string
-	Links       []*Link
-	Description string
-	Remarks     string
-	Duration    string
-	Rating      float32
+	Id            int
+	Name          string
+	OriginalTitle string // title in the original language, if it differs from Name
+	Links         []*Link
+	Description   string
+	Remarks       string
+	Duration      string
+	Rating        float32
 
 	CycleAdded   *Cycle
 	CycleWatched *Cycle
 
-	Removed  bool // Removed by a mod or admin
-	Approved bool // Approved by a mod or admin (if required by config)
+	Removed    bool   // Removed by a mod or admin
+	Approved   bool   // Approved by a mod or admin (if required by config)
+	Pending    bool   // Waiting in the approval queue
+	Denied     bool   // Denied by a mod or admin
+	DenyReason string // Shown to the user that added the movie
 
 	Votes []*Vote
 	Tags  []*Tag
 
-	Poster  string // TODO: make this procedural
-	AddedBy *User
+	Poster       string // key in the poster store
+	PosterSource string // URL the poster was downloaded from, if any
+	AddedBy      *User
 }
 
 func (m Movie) UserVoted(userId int) bool {
@@ -40,6 +47,46 @@ func (m Movie) UserVoted(userId int) bool {
 	return false
 }
 
+var re_hashedPoster = regexp.MustCompile(`^[0-9a-f]{64}\.jpg$`)
+
+// Thumbnail returns the store key of the small version of the poster.
+// Posters saved before thumbnails were generated only have the full size
+// image.
+func (m Movie) Thumbnail() string {
+	if re_hashedPoster.MatchString(m.Poster) {
+		return ThumbnailKey(m.Poster)
+	}
+	return m.Poster
+}
+
+// ThumbnailKey returns the key of the thumbnail that belongs to a poster.
+func ThumbnailKey(poster string) string {
+	return strings.TrimSuffix(poster, ".jpg") + "-thumb.jpg"
+}
+
+// PosterKey turns a poster path saved by older versions, like
+// "posters/unknown.jpg", into a poster store key.
+func PosterKey(poster string) string {
+	return strings.TrimPrefix(poster, "posters/")
+}
+
+// Visible returns false for movies that are still in the approval queue or
+// have been denied.  These are hidden from the movie list and voting.
+func (m Movie) Visible() bool {
+	return !m.Pending && !m.Denied
+}
+
+// Status returns a short description of the movie's approval state.
+func (m Movie) Status() string {
+	switch {
+	case m.Pending:
+		return "Pending approval"
+	case m.Denied:
+		return "Denied"
+	}
+	return ""
+}
+
 func (m Movie) String() string {
 	votes := []string{}
 	for _, v := range m.Votes {
@@ -109,3 +156,15 @@ func SortMoviesByName(list []*Movie) []*Movie {
 	sort.Sort(s)
 	return s
 }
+
+type movieIdSort []*Movie
+
+func (ml movieIdSort) Len() int           { return len(ml) }
+func (ml movieIdSort) Less(i, j int) bool { return ml[i].Id < ml[j].Id }
+func (ml movieIdSort) Swap(i, j int)      { ml[i], ml[j] = ml[j], ml[i] }
+
+func SortMoviesById(list []*Movie) []*Movie {
+	s := movieIdSort(list)
+	sort.Sort(s)
+	return s
+}
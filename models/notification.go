@@ -0,0 +1,22 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// A Notification is a message for a single user, shown on their account
+// page.  They are currently used to tell users the outcome of a movie they
+// submitted for approval.
+type Notification struct {
+	Id      int
+	UserId  int
+	Message string
+	Link    string // optional
+	Created time.Time
+	Read    bool
+}
+
+func (n Notification) String() string {
+	return fmt.Sprintf("Notification{Id:%d UserId:%d Read:%t Message:%q}", n.Id, n.UserId, n.Read, n.Message)
+}
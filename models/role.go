@@ -0,0 +1,54 @@
+package models
+
+type Permission string
+
+const (
+	PERM_APPROVE_MOVIES Permission = "ApproveMovies"
+	PERM_REMOVE_MOVIES  Permission = "RemoveMovies"
+	PERM_END_CYCLES     Permission = "EndCycles"
+	PERM_EDIT_CONFIG    Permission = "EditConfig"
+	PERM_MANAGE_USERS   Permission = "ManageUsers"
+	PERM_MANAGE_TAGS    Permission = "ManageTags"
+)
+
+// All permissions that can be granted through a role, in display order.
+var Permissions = []Permission{
+	PERM_APPROVE_MOVIES,
+	PERM_REMOVE_MOVIES,
+	PERM_END_CYCLES,
+	PERM_EDIT_CONFIG,
+	PERM_MANAGE_USERS,
+	PERM_MANAGE_TAGS,
+}
+
+// Permissions every PRIV_MOD user has, with or without a role.
+var ModPermissions = []Permission{
+	PERM_APPROVE_MOVIES,
+	PERM_REMOVE_MOVIES,
+}
+
+func (p Permission) Valid() bool {
+	for _, perm := range Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// A Role is a named set of permissions that can be assigned to users.  Only
+// admins can define roles and assign them.
+type Role struct {
+	Id          int
+	Name        string
+	Permissions []Permission
+}
+
+func (r Role) Has(perm Permission) bool {
+	for _, p := range r.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
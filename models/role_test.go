@@ -0,0 +1,41 @@
+package models
+
+import "testing"
+
+func TestPermissionValid(t *testing.T) {
+	for _, perm := range Permissions {
+		if !perm.Valid() {
+			t.Errorf("%s is not valid", perm)
+		}
+	}
+
+	for _, perm := range []Permission{"", "Admin", "approvemovies"} {
+		if perm.Valid() {
+			t.Errorf("%q is valid", perm)
+		}
+	}
+
+	for _, perm := range ModPermissions {
+		if !perm.Valid() {
+			t.Errorf("Mod permission %s is not valid", perm)
+		}
+	}
+}
+
+func TestRoleHas(t *testing.T) {
+	role := Role{Name: "Tagger", Permissions: []Permission{PERM_MANAGE_TAGS, PERM_END_CYCLES}}
+
+	for _, perm := range Permissions {
+		expected := perm == PERM_MANAGE_TAGS || perm == PERM_END_CYCLES
+		if has := role.Has(perm); has != expected {
+			t.Errorf("Has(%s) returned %v", perm, has)
+		}
+	}
+
+	empty := Role{Name: "Empty"}
+	for _, perm := range Permissions {
+		if empty.Has(perm) {
+			t.Errorf("A role without permissions has %s", perm)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// A Session is a login on one device.  The cookie only holds a random token,
+// everything else is kept on the server so users can see where they are
+// logged in and sessions can be revoked.
+type Session struct {
+	Id        int
+	UserId    int
+	TokenHash string   // hash of the cookie token, the token itself isn't stored
+	AuthType  AuthType // the method used to log in
+	AuthDate  string   // hash of the auth method's Date at login
+	UserAgent string
+	Address   string // client IP at login
+	Created   time.Time
+	LastSeen  time.Time
+
+	// Last check of the Discord guild membership, Discord logins only.
+	MemberChecked time.Time
+}
+
+// Expired returns true if the session hasn't been used for longer than
+// maxAge.
+func (s Session) Expired(maxAge time.Duration, now time.Time) bool {
+	return now.Sub(s.LastSeen) > maxAge
+}
+
+func (s Session) String() string {
+	return fmt.Sprintf("Session{Id:%d UserId:%d AuthType:%s LastSeen:%s}", s.Id, s.UserId, s.AuthType, s.LastSeen.Format("2006-01-02 15:04"))
+}
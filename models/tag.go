@@ -1,6 +1,13 @@
 package models
 
+import "net/url"
+
 type Tag struct {
 	Id   int
 	Name string
 }
+
+// Url returns the path of the tag's page.
+func (t Tag) Url() string {
+	return "/tag/" + url.PathEscape(t.Name)
+}
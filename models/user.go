@@ -20,6 +20,7 @@ type User struct {
 	NotifyCycleEnd      bool
 	NotifyVoteSelection bool
 	Privilege           PrivilegeLevel
+	Role                *Role // nil if no role is assigned
 
 	AuthMethods []*AuthMethod
 }
@@ -40,7 +41,40 @@ func (u User) IsAdmin() bool {
 }
 
 func (u User) IsMod() bool {
-	return u.Privilege >= PRIV_ADMIN
+	return u.Privilege >= PRIV_MOD
+}
+
+// HasPermission returns true if the user is an admin, holds a role granting
+// the permission, or is a mod and the permission is part of ModPermissions.
+func (u User) HasPermission(perm Permission) bool {
+	if u.IsAdmin() {
+		return true
+	}
+
+	if u.Role != nil && u.Role.Has(perm) {
+		return true
+	}
+
+	if u.IsMod() {
+		for _, p := range ModPermissions {
+			if p == perm {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// HasAnyPermission returns true if the user can access at least one part of
+// the admin pages.
+func (u User) HasAnyPermission() bool {
+	for _, perm := range Permissions {
+		if u.HasPermission(perm) {
+			return true
+		}
+	}
+	return false
 }
 
 func (u User) GetAuthMethod(method AuthType) (*AuthMethod, error) {
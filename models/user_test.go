@@ -0,0 +1,68 @@
+package models
+
+import "testing"
+
+func TestUserPrivilege(t *testing.T) {
+	tests := []struct {
+		priv  PrivilegeLevel
+		mod   bool
+		admin bool
+	}{
+		{PRIV_USER, false, false},
+		{PRIV_MOD, true, false},
+		{PRIV_ADMIN, true, true},
+	}
+
+	for _, tt := range tests {
+		u := User{Privilege: tt.priv}
+		if u.IsMod() != tt.mod || u.CheckPriv("MOD") != tt.mod {
+			t.Errorf("Privilege %d: IsMod() returned %v, CheckPriv(\"MOD\") %v", tt.priv, u.IsMod(), u.CheckPriv("MOD"))
+		}
+		if u.IsAdmin() != tt.admin || u.CheckPriv("ADMIN") != tt.admin {
+			t.Errorf("Privilege %d: IsAdmin() returned %v, CheckPriv(\"ADMIN\") %v", tt.priv, u.IsAdmin(), u.CheckPriv("ADMIN"))
+		}
+		if u.CheckPriv("USER") {
+			t.Errorf("Privilege %d: CheckPriv() accepted an unknown level", tt.priv)
+		}
+	}
+}
+
+func TestUserHasPermission(t *testing.T) {
+	tagger := &Role{Name: "Tagger", Permissions: []Permission{PERM_MANAGE_TAGS}}
+	empty := &Role{Name: "Empty"}
+
+	isModPerm := func(perm Permission) bool {
+		return perm == PERM_APPROVE_MOVIES || perm == PERM_REMOVE_MOVIES
+	}
+
+	tests := []struct {
+		name     string
+		user     User
+		expected func(Permission) bool
+		any      bool
+	}{
+		{"user", User{Privilege: PRIV_USER}, func(Permission) bool { return false }, false},
+		{"user with an empty role", User{Privilege: PRIV_USER, Role: empty}, func(Permission) bool { return false }, false},
+		{"user with a role", User{Privilege: PRIV_USER, Role: tagger}, func(p Permission) bool { return p == PERM_MANAGE_TAGS }, true},
+		{"mod", User{Privilege: PRIV_MOD}, isModPerm, true},
+		{"mod with a role", User{Privilege: PRIV_MOD, Role: tagger}, func(p Permission) bool { return isModPerm(p) || p == PERM_MANAGE_TAGS }, true},
+		{"admin", User{Privilege: PRIV_ADMIN}, func(Permission) bool { return true }, true},
+		{"admin with an empty role", User{Privilege: PRIV_ADMIN, Role: empty}, func(Permission) bool { return true }, true},
+	}
+
+	for _, tt := range tests {
+		for _, perm := range Permissions {
+			if has := tt.user.HasPermission(perm); has != tt.expected(perm) {
+				t.Errorf("%s: HasPermission(%s) returned %v", tt.name, perm, has)
+			}
+		}
+
+		if any := tt.user.HasAnyPermission(); any != tt.any {
+			t.Errorf("%s: HasAnyPermission() returned %v", tt.name, any)
+		}
+
+		if tt.user.HasPermission("Unknown") != tt.user.IsAdmin() {
+			t.Errorf("%s: HasPermission() of an unknown permission returned %v", tt.name, !tt.user.IsAdmin())
+		}
+	}
+}
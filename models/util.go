@@ -40,6 +40,15 @@ func IntSliceContains(needle int, haystack []int) bool {
 	return false
 }
 
+func StringSliceContains(needle string, haystack []string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
+
 // This function filters the given movies by the supplied tags
 // To be returned a movie has to match ALL supplied tags
 func FilterMoviesByTags(movies []*Movie, tags []string) ([]*Movie, error) {
@@ -72,6 +81,27 @@ func FilterMoviesByTags(movies []*Movie, tags []string) ([]*Movie, error) {
 	return found, nil
 }
 
+// FilterMoviesByAnyTag returns the movies that have at least one of the
+// supplied tags.  The order of the movies is kept.
+func FilterMoviesByAnyTag(movies []*Movie, tags []string) []*Movie {
+	found := []*Movie{}
+	for _, movie := range movies {
+		for _, tag := range tags {
+			if movieContainsTag(movie, tag) {
+				found = append(found, movie)
+				break
+			}
+		}
+	}
+	return found
+}
+
+// HasTag reports whether the movie has a tag with the given name, ignoring
+// case.
+func (m Movie) HasTag(tag string) bool {
+	return movieContainsTag(&m, tag)
+}
+
 // checks if a movie contains a certain tag - returns either true or false
 func movieContainsTag(movie *Movie, tag string) bool {
 
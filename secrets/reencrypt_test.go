@@ -0,0 +1,103 @@
+package secrets_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zorchenhimer/MoviePolls/database"
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+// The database package has its own tests, but they don't build.  Encrypting
+// the data again is tested here instead.
+func TestReencrypt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The JSON connector creates a db directory in the working directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+	file := filepath.Join(dir, "data.json")
+
+	db, err := database.GetDatabase("json", file, oldKey, nil)
+	if err != nil {
+		t.Fatalf("GetDatabase() returned an error: %v", err)
+	}
+	if err := db.SetSecretSettings([]string{"TmdbToken"}); err != nil {
+		t.Fatalf("SetSecretSettings() returned an error: %v", err)
+	}
+	if err := db.SetCfgString("TmdbToken", "tmdb-secret"); err != nil {
+		t.Fatalf("SetCfgString() returned an error: %v", err)
+	}
+	if err := db.SetCfgString("NoticeBanner", "not a secret"); err != nil {
+		t.Fatalf("SetCfgString() returned an error: %v", err)
+	}
+	authId, err := db.AddAuthMethod(&models.AuthMethod{Type: models.AUTH_DISCORD, AuthToken: "oauth-secret"})
+	if err != nil {
+		t.Fatalf("AddAuthMethod() returned an error: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close() returned an error: %v", err)
+	}
+
+	checkFile := func() {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range []string{"tmdb-secret", "oauth-secret"} {
+			if bytes.Contains(raw, []byte(secret)) {
+				t.Errorf("%s was saved in plain text", secret)
+			}
+		}
+		if !bytes.Contains(raw, []byte("not a secret")) {
+			t.Errorf("NoticeBanner was encrypted")
+		}
+	}
+	checkFile()
+
+	if err := database.Reencrypt("json", file, newKey, newKey, nil); err == nil {
+		t.Errorf("Reencrypt() accepted the wrong old key")
+	}
+	if err := database.Reencrypt("json", file, oldKey, newKey, nil); err != nil {
+		t.Fatalf("Reencrypt() returned an error: %v", err)
+	}
+	checkFile()
+
+	if _, err := database.GetDatabase("json", file, oldKey, nil); err == nil {
+		t.Errorf("GetDatabase() accepted the old key after Reencrypt()")
+	}
+
+	if _, err := database.GetDatabase("json", file, nil, nil); err == nil {
+		t.Errorf("GetDatabase() accepted no key for encrypted data")
+	}
+
+	db, err = database.GetDatabase("json", file, newKey, nil)
+	if err != nil {
+		t.Fatalf("GetDatabase() returned an error for the new key: %v", err)
+	}
+	defer db.Close()
+
+	if val, err := db.GetCfgString("TmdbToken", ""); val != "tmdb-secret" || err != nil {
+		t.Errorf("GetCfgString() returned %q, %v", val, err)
+	}
+
+	if auth := db.GetAuthMethod(authId); auth == nil || auth.AuthToken != "oauth-secret" {
+		t.Errorf("GetAuthMethod() returned %v", auth)
+	}
+}
@@ -0,0 +1,167 @@
+// Package secrets encrypts single values, like OAuth tokens, with envelope
+// encryption.  Values are encrypted with a random data key.  The data key is
+// stored next to them, encrypted with a master key that never touches the
+// disk the data is on.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// MasterKeySize is the size of the master key in bytes, before it is base64
+// encoded.
+const MasterKeySize = 32
+
+// Encrypted values start with this, so they can be told apart from values
+// saved before encryption was turned on.
+const sealedPrefix = "enc:v1:"
+
+// The data key is authenticated with this label.
+const dataKeyLabel = "DataKey"
+
+// ParseMasterKey decodes a base64 encoded master key, eg one made with
+// `head -c 32 /dev/urandom | base64`.
+func ParseMasterKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("Master key is not valid base64: %v", err)
+	}
+	if len(key) != MasterKeySize {
+		return nil, fmt.Errorf("Master key has %d bytes, it needs %d", len(key), MasterKeySize)
+	}
+	return key, nil
+}
+
+// ReadMasterKey reads the master key from file.  If file is empty, the key is
+// taken from the environment variable env instead.  No key at all returns
+// nil, secrets are then stored in plain text.
+func ReadMasterKey(file, env string) ([]byte, error) {
+	if file != "" {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read master key: %v", err)
+		}
+		return ParseMasterKey(string(raw))
+	}
+
+	if value := strings.TrimSpace(os.Getenv(env)); value != "" {
+		return ParseMasterKey(value)
+	}
+	return nil, nil
+}
+
+// A Box encrypts and decrypts values with the data key.
+type Box struct {
+	aead    cipher.AEAD
+	dataKey string // the data key, encrypted with the master key
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// NewBox decrypts the data key with the master key.  A new data key is made
+// if dataKey is empty.
+func NewBox(masterKey []byte, dataKey string) (*Box, error) {
+	master, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid master key: %v", err)
+	}
+
+	var key []byte
+	if dataKey == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("Unable to generate data key: %v", err)
+		}
+
+		dataKey, err = seal(master, dataKeyLabel, key)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		key, err = open(master, dataKeyLabel, dataKey)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to decrypt the data key, is it the right master key? %v", err)
+		}
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid data key: %v", err)
+	}
+	return &Box{aead: aead, dataKey: dataKey}, nil
+}
+
+// DataKey returns the data key encrypted with the master key, to be saved
+// along with the values.
+func (b *Box) DataKey() string {
+	return b.dataKey
+}
+
+// Seal encrypts value for the field label.  Empty values stay empty.
+func (b *Box) Seal(label, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	return seal(b.aead, label, []byte(value))
+}
+
+// Open decrypts value of the field label.  Values that aren't encrypted are
+// returned as they are.
+func (b *Box) Open(label, value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+
+	plain, err := open(b.aead, label, value)
+	if err != nil {
+		return "", fmt.Errorf("Unable to decrypt %s: %v", label, err)
+	}
+	return string(plain), nil
+}
+
+// IsSealed returns true for values encrypted by a Box.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+// seal encrypts value.  The label is authenticated along with it, so a value
+// can't be moved to another field.
+func seal(aead cipher.AEAD, label string, value []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("Unable to generate nonce: %v", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, value, []byte(label))
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func open(aead cipher.AEAD, label, value string) ([]byte, error) {
+	if !IsSealed(value) {
+		return nil, fmt.Errorf("Value is not encrypted")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < aead.NonceSize() {
+		return nil, fmt.Errorf("Encrypted value is too short")
+	}
+
+	nonce := raw[:aead.NonceSize()]
+	return aead.Open(nil, nonce, raw[aead.NonceSize():], []byte(label))
+}
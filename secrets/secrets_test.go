@@ -0,0 +1,126 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func testKey(fill byte) []byte {
+	return bytes.Repeat([]byte{fill}, MasterKeySize)
+}
+
+func TestBoxRoundTrip(t *testing.T) {
+	box, err := NewBox(testKey(1), "")
+	if err != nil {
+		t.Fatalf("NewBox() returned an error: %v", err)
+	}
+
+	sealed, err := box.Seal("Settings.TmdbToken", "hunter2")
+	if err != nil {
+		t.Fatalf("Seal() returned an error: %v", err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "hunter2") {
+		t.Fatalf("Seal() returned %q", sealed)
+	}
+
+	// The data key has to be readable with the same master key later on.
+	again, err := NewBox(testKey(1), box.DataKey())
+	if err != nil {
+		t.Fatalf("NewBox() returned an error for a saved data key: %v", err)
+	}
+
+	plain, err := again.Open("Settings.TmdbToken", sealed)
+	if err != nil || plain != "hunter2" {
+		t.Errorf("Open() returned %q, %v", plain, err)
+	}
+
+	if sealed, err := box.Seal("Settings.TmdbToken", ""); sealed != "" || err != nil {
+		t.Errorf("Seal() returned %q, %v for an empty value", sealed, err)
+	}
+
+	if plain, err := box.Open("Settings.TmdbToken", "saved before encryption"); plain != "saved before encryption" || err != nil {
+		t.Errorf("Open() returned %q, %v for a plain value", plain, err)
+	}
+}
+
+func TestBoxWrongKey(t *testing.T) {
+	box, err := NewBox(testKey(1), "")
+	if err != nil {
+		t.Fatalf("NewBox() returned an error: %v", err)
+	}
+
+	if _, err := NewBox(testKey(2), box.DataKey()); err == nil {
+		t.Errorf("NewBox() accepted the wrong master key")
+	}
+
+	// A box with another data key can't read the values either.
+	sealed, err := box.Seal("AuthMethod.AuthToken", "token")
+	if err != nil {
+		t.Fatalf("Seal() returned an error: %v", err)
+	}
+
+	other, err := NewBox(testKey(1), "")
+	if err != nil {
+		t.Fatalf("NewBox() returned an error: %v", err)
+	}
+	if _, err := other.Open("AuthMethod.AuthToken", sealed); err == nil {
+		t.Errorf("Open() accepted a value from another data key")
+	}
+
+	if _, err := NewBox([]byte("short"), ""); err == nil {
+		t.Errorf("NewBox() accepted a short master key")
+	}
+}
+
+func TestBoxTampered(t *testing.T) {
+	box, err := NewBox(testKey(1), "")
+	if err != nil {
+		t.Fatalf("NewBox() returned an error: %v", err)
+	}
+
+	sealed, err := box.Seal("AuthMethod.AuthToken", "token")
+	if err != nil {
+		t.Fatalf("Seal() returned an error: %v", err)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedPrefix))
+	if err != nil {
+		t.Fatalf("Sealed value is not base64: %v", err)
+	}
+	raw[len(raw)-1] ^= 1
+	tampered := sealedPrefix + base64.StdEncoding.EncodeToString(raw)
+
+	if _, err := box.Open("AuthMethod.AuthToken", tampered); err == nil {
+		t.Errorf("Open() accepted a tampered value")
+	}
+
+	// The label is authenticated, values can't be moved to another field.
+	if _, err := box.Open("AuthMethod.RefreshToken", sealed); err == nil {
+		t.Errorf("Open() accepted a value under another label")
+	}
+
+	for _, bad := range []string{sealedPrefix, sealedPrefix + "AAAA", sealedPrefix + "not base64!"} {
+		if _, err := box.Open("AuthMethod.AuthToken", bad); err == nil {
+			t.Errorf("Open() accepted %q", bad)
+		}
+	}
+}
+
+func TestParseMasterKey(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(testKey(3))
+
+	key, err := ParseMasterKey(" " + encoded + "\n")
+	if err != nil || !bytes.Equal(key, testKey(3)) {
+		t.Errorf("ParseMasterKey() returned %v, %v", key, err)
+	}
+
+	if _, err := ParseMasterKey(base64.StdEncoding.EncodeToString([]byte("too short"))); err == nil {
+		t.Errorf("ParseMasterKey() accepted a short key")
+	}
+
+	if _, err := ParseMasterKey("not base64!"); err == nil {
+		t.Errorf("ParseMasterKey() accepted invalid base64")
+	}
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/logger"
+)
+
+func init() {
+	register("fs", func(connStr string, l *logger.Logger) (PosterStore, error) {
+		return newFsStore(connStr)
+	})
+}
+
+// fsStore keeps posters in a directory on the local disk.
+type fsStore struct {
+	dir string
+}
+
+func newFsStore(dir string) (*fsStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("No poster directory given")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Unable to create posters directory: %v", err)
+	}
+
+	return &fsStore{dir: dir}, nil
+}
+
+func (f *fsStore) path(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(f.dir, key), nil
+}
+
+// Put writes the poster to a temporary file first, so a poster is never
+// served half written.
+func (f *fsStore) Put(key string, data []byte, contentType string) error {
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(f.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Error while saving poster to disk: %v", err)
+	}
+	return nil
+}
+
+func (f *fsStore) Get(key string) (io.ReadCloser, error) {
+	path, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (f *fsStore) Exists(key string) (bool, error) {
+	path, err := f.path(key)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (f *fsStore) Delete(key string) error {
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (f *fsStore) Touch(key string) error {
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = os.Chtimes(path, now, now)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// List skips anything that isn't a valid key, like temporary files from
+// Put() and subdirectories.
+func (f *fsStore) List() ([]*PosterInfo, error) {
+	files, err := ioutil.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	posters := []*PosterInfo{}
+	for _, file := range files {
+		if file.IsDir() || !ValidKey(file.Name()) {
+			continue
+		}
+		posters = append(posters, &PosterInfo{Key: file.Name(), Modified: file.ModTime()})
+	}
+	return posters, nil
+}
+
+func (f *fsStore) RedirectUrl(key string) (string, error) {
+	return "", nil
+}
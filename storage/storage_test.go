@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// testStore runs the checks every PosterStore has to pass.
+func testStore(t *testing.T, store PosterStore) {
+	data := []byte("not really a jpeg")
+
+	if ok, err := store.Exists("poster.jpg"); err != nil || ok {
+		t.Errorf("Exists() returned %v, %v for a missing poster", ok, err)
+	}
+
+	if _, err := store.Get("poster.jpg"); err != ErrNotFound {
+		t.Errorf("Get() returned %v for a missing poster, expected ErrNotFound", err)
+	}
+
+	if err := store.Put("poster.jpg", data, "image/jpeg"); err != nil {
+		t.Fatalf("Put() returned an error: %v", err)
+	}
+
+	if ok, err := store.Exists("poster.jpg"); err != nil || !ok {
+		t.Errorf("Exists() returned %v, %v for a saved poster", ok, err)
+	}
+
+	posters, err := store.List()
+	if err != nil {
+		t.Fatalf("List() returned an error: %v", err)
+	}
+	if len(posters) != 1 || posters[0].Key != "poster.jpg" || time.Since(posters[0].Modified) > time.Minute {
+		t.Errorf("List() returned unexpected posters: %v", posters)
+	}
+
+	if err := store.Touch("poster.jpg"); err != nil {
+		t.Errorf("Touch() returned an error: %v", err)
+	}
+	if posters, _ := store.List(); len(posters) != 1 || time.Since(posters[0].Modified) > time.Minute {
+		t.Errorf("List() returned unexpected posters after Touch(): %v", posters)
+	}
+	if err := store.Touch("missing.jpg"); err != ErrNotFound {
+		t.Errorf("Touch() returned %v for a missing poster, expected ErrNotFound", err)
+	}
+
+	rc, err := store.Get("poster.jpg")
+	if err != nil {
+		t.Fatalf("Get() returned an error: %v", err)
+	}
+	saved, _ := ioutil.ReadAll(rc)
+	rc.Close()
+
+	if !bytes.Equal(saved, data) {
+		t.Errorf("Get() returned %q, expected %q", saved, data)
+	}
+
+	if err := store.Delete("poster.jpg"); err != nil {
+		t.Errorf("Delete() returned an error: %v", err)
+	}
+
+	if ok, _ := store.Exists("poster.jpg"); ok {
+		t.Errorf("Poster still exists after Delete()")
+	}
+
+	if err := store.Delete("poster.jpg"); err != nil {
+		t.Errorf("Deleting a missing poster returned an error: %v", err)
+	}
+
+	for _, key := range []string{"../db/data.json", "a/b.jpg", "..", ".hidden", ""} {
+		if err := store.Put(key, data, "image/jpeg"); err != ErrInvalidKey {
+			t.Errorf("Put(%q) returned %v, expected ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestFsStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mp-posters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := GetPosterStore("fs", dir+"/posters", nil)
+	if err != nil {
+		t.Fatalf("GetPosterStore() returned an error: %v", err)
+	}
+
+	testStore(t, store)
+
+	if u, err := store.RedirectUrl("poster.jpg"); err != nil || u != "" {
+		t.Errorf("RedirectUrl() returned %q, %v", u, err)
+	}
+
+	// Touch updates the modification time.
+	old := time.Now().Add(-24 * time.Hour)
+	store.Put("old.jpg", []byte("old"), "image/jpeg")
+	os.Chtimes(dir+"/posters/old.jpg", old, old)
+	if err := store.Touch("old.jpg"); err != nil {
+		t.Errorf("Touch() returned an error: %v", err)
+	}
+	if info, err := os.Stat(dir + "/posters/old.jpg"); err != nil || !info.ModTime().After(old) {
+		t.Errorf("Touch() didn't update the modification time")
+	}
+	store.Delete("old.jpg")
+
+	// No temporary files are left behind.
+	files, _ := ioutil.ReadDir(dir + "/posters")
+	if len(files) != 0 {
+		t.Errorf("Expected an empty directory, found %d files", len(files))
+	}
+}
+
+func TestGetPosterStoreUnknown(t *testing.T) {
+	if _, err := GetPosterStore("floppy", "", nil); err == nil {
+		t.Errorf("Expected an error for an unknown store")
+	}
+}
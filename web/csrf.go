@@ -0,0 +1,96 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+const (
+	csrfSessionKey = "CsrfToken"
+	csrfFieldName  = "csrf_token"   // hidden field in every POST form
+	csrfHeaderName = "X-Csrf-Token" // alternative for scripts
+)
+
+func newCsrfToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// csrfToken returns the token of the session, creating it if the session
+// doesn't have one yet.  It has to be called before anything is written to w.
+func (s *webServer) csrfToken(w http.ResponseWriter, r *http.Request) string {
+	// A session that can't be decoded is replaced by a new one.
+	session, _ := s.getSession(r)
+
+	if token, ok := session.Values[csrfSessionKey].(string); ok && token != "" {
+		return token
+	}
+
+	token, err := newCsrfToken()
+	if err != nil {
+		s.l.Error("Unable to generate CSRF token: %v", err)
+		return ""
+	}
+
+	session.Values[csrfSessionKey] = token
+	if err := session.Save(r, w); err != nil {
+		s.l.Error("Unable to save session: %v", err)
+		return ""
+	}
+	return token
+}
+
+// validCsrfToken compares the token sent with the request to the one in the
+// session.
+func (s *webServer) validCsrfToken(r *http.Request) bool {
+	session, err := s.getSession(r)
+	if err != nil {
+		return false
+	}
+
+	expected, _ := session.Values[csrfSessionKey].(string)
+	if expected == "" {
+		return false
+	}
+
+	token := r.Header.Get(csrfHeaderName)
+	if token == "" {
+		// Parse uploads the same way the handlers do, so the files don't end
+		// up in memory.
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			if err := r.ParseMultipartForm(4096); err != nil {
+				return false
+			}
+		}
+		token = r.PostFormValue(csrfFieldName)
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// csrfProtect rejects every request that can change something, unless it
+// carries the token of the session.  Safe methods are passed through, so GET
+// handlers must never change anything.
+func (s *webServer) csrfProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !s.validCsrfToken(r) {
+			s.l.Info("Rejected %s %s without a valid CSRF token", r.Method, r.URL.Path)
+			s.doError(http.StatusForbidden, "This form has expired.  Go back, reload the page and try again.", w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
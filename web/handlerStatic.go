@@ -1,11 +1,16 @@
 package web
 
 import (
+	"io"
+	"mime"
 	"net/http"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/zorchenhimer/MoviePolls/models"
+	"github.com/zorchenhimer/MoviePolls/storage"
 )
 
 func (s *webServer) handlerStatic(w http.ResponseWriter, r *http.Request) {
@@ -17,18 +22,60 @@ func (s *webServer) handlerStatic(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, file)
 }
 
+// posterExtensions are the only files served from the poster directory.
+var posterExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+}
+
 func (s *webServer) handlerPosters(w http.ResponseWriter, r *http.Request) {
-	file := strings.TrimLeft(filepath.Clean("/"+r.URL.Path), "/\\")
+	key := strings.TrimPrefix(r.URL.Path, "/posters/")
 	if s.debug {
-		s.l.Info("Attempting to serve file %q", file)
+		s.l.Info("Attempting to serve poster %q", key)
 	}
 
-	// Dirty but might work out fine
-	if file != "/posters" {
-		http.ServeFile(w, r, file)
-	} else {
+	ext := strings.ToLower(path.Ext(key))
+	if !storage.ValidKey(key) || !posterExtensions[ext] {
 		http.Error(w, "Nothing to see here. Go away!", http.StatusForbidden)
+		return
+	}
+
+	redirect, err := s.backend.GetPosterRedirect(key)
+	if err != nil {
+		s.l.Error("Unable to get redirect for poster %q: %v", key, err)
+		http.Error(w, "Something went wrong :C", http.StatusInternalServerError)
+		return
+	}
+
+	if redirect != "" {
+		http.Redirect(w, r, redirect, http.StatusFound)
+		return
+	}
+
+	poster, err := s.backend.GetPoster(key)
+	if err == storage.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		s.l.Error("Unable to get poster %q: %v", key, err)
+		http.Error(w, "Something went wrong :C", http.StatusInternalServerError)
+		return
+	}
+	defer poster.Close()
+
+	// Never let the browser guess that a poster is something else.
+	w.Header().Set("Content-Type", mime.TypeByExtension(ext))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// Files on disk support range and conditional requests.
+	if rs, ok := poster.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, key, time.Time{}, rs)
+		return
 	}
+	io.Copy(w, poster)
 }
 
 func (s *webServer) handlerFavicon(w http.ResponseWriter, r *http.Request) {
@@ -3,13 +3,34 @@ package web
 import (
 	"fmt"
 	"net/http"
+
+	"github.com/zorchenhimer/MoviePolls/logic"
 )
 
 // This is here since i didnt find a better place ...
 func (s *webServer) handlerVote(w http.ResponseWriter, r *http.Request) {
-	user := s.getSessionUser(w, r)
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, s.sitePath("/"), http.StatusSeeOther)
+		return
+	}
+
+	session, user := s.getLoginSession(w, r)
 	if user == nil {
-		http.Redirect(w, r, "/login", http.StatusFound)
+		http.Redirect(w, r, s.sitePath("/login"), http.StatusFound)
+		return
+	}
+
+	if err := s.backend.CheckDiscordMembership(session); err != nil {
+		if err == logic.ErrDiscordMembership {
+			s.doError(http.StatusForbidden, err.Error(), w, r)
+		} else {
+			s.doError(http.StatusInternalServerError, "Something went wrong :c", w, r)
+			s.l.Error("Unable to check Discord membership: %v", err)
+		}
+		return
+	}
+
+	if s.rejectBannedUser(user, w, r) {
 		return
 	}
 
@@ -31,6 +52,10 @@ func (s *webServer) handlerVote(w http.ResponseWriter, r *http.Request) {
 	}
 
 	movie := s.backend.GetMovie(movieId)
+	if movie == nil {
+		s.doError(http.StatusNotFound, "Movie not found", w, r)
+		return
+	}
 
 	if movie.CycleWatched != nil {
 		s.doError(http.StatusBadRequest, "Movie already watched", w, r)
@@ -38,6 +63,12 @@ func (s *webServer) handlerVote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !movie.Visible() {
+		s.doError(http.StatusBadRequest, "Movie has not been approved", w, r)
+		s.l.Error("Attempted to vote on unapproved movie ID %d", movieId)
+		return
+	}
+
 	userVoted, err := s.backend.UserVotedForMovie(user.Id, movieId)
 	if err != nil {
 		s.doError(http.StatusBadRequest, "Something went wrong :c", w, r)
@@ -109,7 +140,8 @@ func (s *webServer) handlerVote(w http.ResponseWriter, r *http.Request) {
 
 	ref := r.Header.Get("Referer")
 	if ref == "" {
-		http.Redirect(w, r, "/", http.StatusFound)
+		http.Redirect(w, r, s.sitePath("/"), http.StatusFound)
+		return
 	}
 	http.Redirect(w, r, ref, http.StatusFound)
 }
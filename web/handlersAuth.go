@@ -70,6 +70,10 @@ func (s *webServer) initOauth() error {
 		}
 	}
 
+	// The host address doesn't include the base path, the callbacks are
+	// below it like every other route.
+	baseUrl = strings.TrimSuffix(baseUrl, "/")
+
 	if twitchOauthEnabled {
 		twitchClientID, err := s.backend.GetTwitchOauthClientID()
 		if err != nil {
@@ -89,7 +93,7 @@ func (s *webServer) initOauth() error {
 		}
 
 		twitchOAuthConfig = &oauth2.Config{
-			RedirectURL:  baseUrl + "/oauth/twitch/callback",
+			RedirectURL:  baseUrl + s.sitePath("/oauth/twitch/callback"),
 			ClientID:     twitchClientID,
 			ClientSecret: twitchClientSecret,
 			Scopes:       []string{"user:read:email"},
@@ -116,11 +120,23 @@ func (s *webServer) initOauth() error {
 			return fmt.Errorf("Config Value for DiscordOauthClientSecret cannot be empty to use OAuth")
 		}
 
+		discordScopes := []string{"email", "identify"}
+
+		discordGuildID, err := s.backend.GetDiscordGuildID()
+		if err != nil {
+			return err
+		}
+
+		// Reading the guild member object (and its roles) needs an extra scope
+		if discordGuildID != "" {
+			discordScopes = append(discordScopes, "guilds.members.read")
+		}
+
 		discordOAuthConfig = &oauth2.Config{
-			RedirectURL:  baseUrl + "/oauth/discord/callback",
+			RedirectURL:  baseUrl + s.sitePath("/oauth/discord/callback"),
 			ClientID:     discordClientID,
 			ClientSecret: discordClientSecret,
-			Scopes:       []string{"email", "identify"},
+			Scopes:       discordScopes,
 			Endpoint:     discordEndpoint,
 		}
 	}
@@ -145,7 +161,7 @@ func (s *webServer) initOauth() error {
 		}
 
 		patreonOAuthConfig = &oauth2.Config{
-			RedirectURL:  baseUrl + "/oauth/patreon/callback",
+			RedirectURL:  baseUrl + s.sitePath("/oauth/patreon/callback"),
 			ClientID:     patreonClientID,
 			ClientSecret: patreonClientSecret,
 			Scopes:       []string{"identity", "identity[email]"},
@@ -160,19 +176,24 @@ func (s *webServer) initOauth() error {
 func (s *webServer) handlerLocalAuthRemove(w http.ResponseWriter, r *http.Request) {
 	s.l.Debug("local remove")
 
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
+		return
+	}
+
 	user := s.getSessionUser(w, r)
 
 	auth, err := user.GetAuthMethod(models.AUTH_LOCAL)
 
 	if err != nil {
 		s.l.Info("User %s does not have a password associated with him", user.Name)
-		http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 		return
 	}
 
 	if len(user.AuthMethods) == 1 {
 		s.l.Info("User %v only has the local Authmethod associated with him", user.Name)
-		http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 		return
 	}
 
@@ -180,14 +201,14 @@ func (s *webServer) handlerLocalAuthRemove(w http.ResponseWriter, r *http.Reques
 
 	if err != nil {
 		s.l.Info("Could not remove password from user. %s", err.Error())
-		http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 		return
 	}
 
 	err = s.backend.UpdateUser(user)
 	if err != nil {
 		s.l.Info("Could not update user %s", user.Name)
-		http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 		return
 	}
 
@@ -195,14 +216,14 @@ func (s *webServer) handlerLocalAuthRemove(w http.ResponseWriter, r *http.Reques
 	err = s.logout(w, r)
 	if err != nil {
 		s.l.Info("Could not logout user %s", user.Name)
-		http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 		return
 	}
 
 	// Logging the user back in
 	s.saveLoginUser(user, w, r)
 
-	http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+	http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 }
 
 func (s *webServer) saveLoginUser(user *models.User, w http.ResponseWriter, r *http.Request) {
@@ -242,7 +263,7 @@ func (s *webServer) handlerTwitchOAuth(w http.ResponseWriter, r *http.Request) {
 		url := twitchOAuthConfig.AuthCodeURL(oauthStateString)
 		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 
-		s.l.Debug("twitch login")
+		s.oauthLog.Debug("twitch login")
 
 	case signupSwitchString:
 		// Generate a new state string for each login attempt and store it in the state list
@@ -253,7 +274,7 @@ func (s *webServer) handlerTwitchOAuth(w http.ResponseWriter, r *http.Request) {
 		url := twitchOAuthConfig.AuthCodeURL(oauthStateString)
 		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 
-		s.l.Debug("twitch sign up")
+		s.oauthLog.Debug("twitch sign up")
 
 	case addSwitchString:
 		// Generate a new state string for each login attempt and store it in the state list
@@ -264,52 +285,57 @@ func (s *webServer) handlerTwitchOAuth(w http.ResponseWriter, r *http.Request) {
 		url := twitchOAuthConfig.AuthCodeURL(oauthStateString)
 		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 
-		s.l.Debug("twitch add")
+		s.oauthLog.Debug("twitch add")
 
 	case removeSwitchString:
+		if r.Method != http.MethodPost {
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
+			return
+		}
+
 		user := s.getSessionUser(w, r)
 
 		auth, err := user.GetAuthMethod(models.AUTH_TWITCH)
 
 		if err != nil {
-			s.l.Info("User %s does not have Twitch Oauth associated with him", user.Name)
-			http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("User %s does not have Twitch Oauth associated with him", user.Name)
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 			return
 		}
 
 		if len(user.AuthMethods) == 1 {
-			s.l.Info("User %v only has Twitch Oauth associated with him", user.Name)
-			http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("User %v only has Twitch Oauth associated with him", user.Name)
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 			return
 		}
 
 		user, err = s.backend.RemoveAuthMethodFromUser(auth, user)
 
 		if err != nil {
-			s.l.Info("Could not remove Twitch Oauth from user. %s", err.Error())
-			http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("Could not remove Twitch Oauth from user. %s", err.Error())
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 			return
 		}
 
 		err = s.backend.UpdateUser(user)
 		if err != nil {
-			s.l.Info("Could not update user %s", user.Name)
-			http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("Could not update user %s", user.Name)
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 			return
 		}
 
 		// Log the user out to ensure he uses an existing AuthMethod
 		err = s.logout(w, r)
 		if err != nil {
-			s.l.Info("Could not logout user %s", user.Name)
-			http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("Could not logout user %s", user.Name)
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 			return
 		}
 
 		// Find a new AuthMethod to log the user back in
 		s.saveLoginUser(user, w, r)
 
-		http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 		return
 	}
 }
@@ -325,16 +351,16 @@ func (s *webServer) handlerTwitchOAuthCallback(w http.ResponseWriter, r *http.Re
 		}
 	}
 	if !ok {
-		s.l.Info("Invalid/Unknown OAuth state string: '%s'", state)
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info("Invalid/Unknown OAuth state string: '%s'", state)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
 	code := r.FormValue("code")
 	token, err := twitchOAuthConfig.Exchange(context.Background(), code)
 	if err != nil {
-		s.l.Info("Code exchange failed: %s", err)
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info("Code exchange failed: %s", err)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
@@ -342,8 +368,8 @@ func (s *webServer) handlerTwitchOAuthCallback(w http.ResponseWriter, r *http.Re
 	req, err := http.NewRequest("GET", "https://api.twitch.tv/helix/users", nil)
 
 	if err != nil {
-		s.l.Info("Could not retrieve Userdata from Twitch API: %s", err)
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info("Could not retrieve Userdata from Twitch API: %s", err)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
@@ -353,31 +379,35 @@ func (s *webServer) handlerTwitchOAuthCallback(w http.ResponseWriter, r *http.Re
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		s.l.Info("Could not retrieve Userdata from Twitch API: %s", err)
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info("Could not retrieve Userdata from Twitch API: %s", err)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		s.l.Info("Status Code is not 200, its %v", resp.Status)
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info("Status Code is not 200, its %v", resp.Status)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		s.l.Info(err.Error())
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info(err.Error())
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
 	var data map[string][]map[string]interface{}
 
 	if err := json.Unmarshal(body, &data); err != nil {
-		s.l.Info(err.Error())
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info(err.Error())
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
+		return
+	}
+
+	if s.rejectOauthBan(data["data"][0]["id"].(string), models.AUTH_TWITCH, w, r) {
 		return
 	}
 
@@ -405,8 +435,8 @@ func (s *webServer) handlerTwitchOAuthCallback(w http.ResponseWriter, r *http.Re
 			newUser.Id, err = s.backend.AddUser(newUser)
 
 			if err != nil {
-				s.l.Info(err.Error())
-				http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+				s.oauthLog.Info(err.Error())
+				http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 				return
 			}
 
@@ -414,8 +444,8 @@ func (s *webServer) handlerTwitchOAuthCallback(w http.ResponseWriter, r *http.Re
 			newUser, err = s.backend.AddAuthMethodToUser(auth, newUser)
 
 			if err != nil {
-				s.l.Info(err.Error())
-				http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+				s.oauthLog.Info(err.Error())
+				http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 				return
 			}
 
@@ -423,45 +453,45 @@ func (s *webServer) handlerTwitchOAuthCallback(w http.ResponseWriter, r *http.Re
 			err = s.backend.UpdateUser(newUser)
 
 			if err != nil {
-				s.l.Info(err.Error())
-				http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+				s.oauthLog.Info(err.Error())
+				http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 				return
 			}
 
-			s.l.Debug("logging in %v", newUser.Name)
+			s.oauthLog.Debug("logging in %v", newUser.Name)
 
 			err := s.login(newUser, models.AUTH_TWITCH, w, r)
 
 			if err != nil {
-				s.l.Info(err.Error())
-				http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+				s.oauthLog.Info(err.Error())
+				http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 				return
 			}
 
-			http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+			http.Redirect(w, r, s.sitePath("/"), http.StatusTemporaryRedirect)
 		} else {
-			s.l.Debug("AuthMethod already used")
-			http.Redirect(w, r, "/user/new", http.StatusTemporaryRedirect)
+			s.oauthLog.Debug("AuthMethod already used")
+			http.Redirect(w, r, s.sitePath("/user/new"), http.StatusTemporaryRedirect)
 		}
 	} else if strings.HasPrefix(state, "login_") {
 		// Handle Twitch Login
 		user, err := s.backend.UserTwitchLogin(data["data"][0]["id"].(string))
 		if err != nil {
-			s.l.Info(err.Error())
-			http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+			s.oauthLog.Info(err.Error())
+			http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 			return
 		}
-		s.l.Debug("logging in %v", user.Name)
+		s.oauthLog.Debug("logging in %v", user.Name)
 
 		err = s.login(user, models.AUTH_TWITCH, w, r)
 
 		if err != nil {
-			s.l.Info(err.Error())
-			http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+			s.oauthLog.Info(err.Error())
+			http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 			return
 		}
 
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.sitePath("/"), http.StatusTemporaryRedirect)
 	} else if strings.HasPrefix(state, "add_") {
 		// Handle adding a Twitch AuthMethod to the logged in user
 
@@ -484,32 +514,32 @@ func (s *webServer) handlerTwitchOAuthCallback(w http.ResponseWriter, r *http.Re
 				_, err = s.backend.AddAuthMethodToUser(auth, user)
 
 				if err != nil {
-					s.l.Info(err.Error())
-					http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+					s.oauthLog.Info(err.Error())
+					http.Redirect(w, r, s.sitePath("/user"), http.StatusTemporaryRedirect)
 					return
 				}
 
 				err = s.backend.UpdateUser(user)
 
 				if err != nil {
-					s.l.Info(err.Error())
-					http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+					s.oauthLog.Info(err.Error())
+					http.Redirect(w, r, s.sitePath("/user"), http.StatusTemporaryRedirect)
 					return
 				}
 			} else {
-				s.l.Info("User %s already has %s Oauth associated", user.Name, auth.Type)
-				http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+				s.oauthLog.Info("User %s already has %s Oauth associated", user.Name, auth.Type)
+				http.Redirect(w, r, s.sitePath("/user"), http.StatusTemporaryRedirect)
 				return
 			}
 		} else {
-			s.l.Info("The provided Oauth login is already used")
+			s.oauthLog.Info("The provided Oauth login is already used")
 
 			s.callbackError = callbackError{
 				user:    user.Id,
 				message: "The provided Oauth login is already used",
 			}
 		}
-		http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.sitePath("/user"), http.StatusTemporaryRedirect)
 		return
 	}
 }
@@ -528,7 +558,7 @@ func (s *webServer) handlerDiscordOAuth(w http.ResponseWriter, r *http.Request)
 		url := discordOAuthConfig.AuthCodeURL(oauthStateString)
 		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 
-		s.l.Debug("discord login")
+		s.oauthLog.Debug("discord login")
 
 	case signupSwitchString:
 		// Generate a new state string for each login attempt and store it in the state list
@@ -539,7 +569,7 @@ func (s *webServer) handlerDiscordOAuth(w http.ResponseWriter, r *http.Request)
 		url := discordOAuthConfig.AuthCodeURL(oauthStateString)
 		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 
-		s.l.Debug("discord signup")
+		s.oauthLog.Debug("discord signup")
 
 	case addSwitchString:
 		// Generate a new state string for each login attempt and store it in the state list
@@ -550,52 +580,57 @@ func (s *webServer) handlerDiscordOAuth(w http.ResponseWriter, r *http.Request)
 		url := discordOAuthConfig.AuthCodeURL(oauthStateString)
 		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 
-		s.l.Debug("discord add")
+		s.oauthLog.Debug("discord add")
 
 	case removeSwitchString:
+		if r.Method != http.MethodPost {
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
+			return
+		}
+
 		user := s.getSessionUser(w, r)
 
 		auth, err := user.GetAuthMethod(models.AUTH_DISCORD)
 
 		if err != nil {
-			s.l.Info("User %s does not have Discord Oauth associated with him", user.Name)
-			http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("User %s does not have Discord Oauth associated with him", user.Name)
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 			return
 		}
 
 		if len(user.AuthMethods) == 1 {
-			s.l.Info("User %v only has Discord Oauth associated with him", user.Name)
-			http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("User %v only has Discord Oauth associated with him", user.Name)
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 			return
 		}
 
 		user, err = s.backend.RemoveAuthMethodFromUser(auth, user)
 
 		if err != nil {
-			s.l.Info("Could not remove Discord Oauth from user. %s", err.Error())
-			http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("Could not remove Discord Oauth from user. %s", err.Error())
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 			return
 		}
 
 		err = s.backend.UpdateUser(user)
 		if err != nil {
-			s.l.Info("Could not update user %s", user.Name)
-			http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("Could not update user %s", user.Name)
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 			return
 		}
 
 		// Log the user out to ensure he is logged in with an existing AuthMethod
 		err = s.logout(w, r)
 		if err != nil {
-			s.l.Info("Could not logout user %s", user.Name)
-			http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("Could not logout user %s", user.Name)
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 			return
 		}
 
 		// Try to log the user back in
 		s.saveLoginUser(user, w, r)
 
-		http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 		return
 	}
 }
@@ -611,16 +646,16 @@ func (s *webServer) handlerDiscordOAuthCallback(w http.ResponseWriter, r *http.R
 		}
 	}
 	if !ok {
-		s.l.Info("Invalid/Unknown OAuth state string: '%s'", state)
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info("Invalid/Unknown OAuth state string: '%s'", state)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
 	code := r.FormValue("code")
 	token, err := discordOAuthConfig.Exchange(context.Background(), code)
 	if err != nil {
-		s.l.Info("Code exchange failed: %s", err)
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info("Code exchange failed: %s", err)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
@@ -628,8 +663,8 @@ func (s *webServer) handlerDiscordOAuthCallback(w http.ResponseWriter, r *http.R
 	req, err := http.NewRequest("GET", "https://discord.com/api/users/@me", nil)
 
 	if err != nil {
-		s.l.Info("Could not retrieve Userdata from Discord API: %s", err)
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info("Could not retrieve Userdata from Discord API: %s", err)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
@@ -638,29 +673,51 @@ func (s *webServer) handlerDiscordOAuthCallback(w http.ResponseWriter, r *http.R
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		s.l.Info("Could not retrieve Userdata from Discord API: %s", err)
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info("Could not retrieve Userdata from Discord API: %s", err)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		s.l.Info("Status Code is not 200, its %v", resp.Status)
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info("Status Code is not 200, its %v", resp.Status)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		s.l.Info(err.Error())
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info(err.Error())
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
 	var data map[string]interface{}
 
 	if err := json.Unmarshal(body, &data); err != nil {
-		s.l.Info(err.Error())
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info(err.Error())
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
+		return
+	}
+
+	if s.rejectOauthBan(data["id"].(string), models.AUTH_DISCORD, w, r) {
+		return
+	}
+
+	isMod, err := s.backend.CheckDiscordGuild(token.AccessToken)
+	if err != nil {
+		s.oauthLog.Info("Discord user %v rejected: %v", data["id"], err)
+		if strings.HasPrefix(state, "add_") {
+			user := s.getSessionUser(w, r)
+			if user != nil {
+				s.callbackError = callbackError{
+					user:    user.Id,
+					message: err.Error(),
+				}
+			}
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusTemporaryRedirect)
+			return
+		}
+		s.doError(http.StatusForbidden, err.Error(), w, r)
 		return
 	}
 
@@ -685,58 +742,62 @@ func (s *webServer) handlerDiscordOAuthCallback(w http.ResponseWriter, r *http.R
 			newUser.Id, err = s.backend.AddUser(newUser)
 
 			if err != nil {
-				s.l.Info(err.Error())
-				http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+				s.oauthLog.Info(err.Error())
+				http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 				return
 			}
 
 			newUser, err = s.backend.AddAuthMethodToUser(auth, newUser)
 
 			if err != nil {
-				s.l.Info(err.Error())
-				http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+				s.oauthLog.Info(err.Error())
+				http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 				return
 			}
 
 			err = s.backend.UpdateUser(newUser)
 
 			if err != nil {
-				s.l.Info(err.Error())
-				http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+				s.oauthLog.Info(err.Error())
+				http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 				return
 			}
 
-			s.l.Debug("logging in %v", newUser.Name)
+			s.syncDiscordMod(newUser, isMod)
+
+			s.oauthLog.Debug("logging in %v", newUser.Name)
 			err := s.login(newUser, models.AUTH_DISCORD, w, r)
 
 			if err != nil {
-				s.l.Info(err.Error())
-				http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+				s.oauthLog.Info(err.Error())
+				http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 				return
 			}
 
-			http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+			http.Redirect(w, r, s.sitePath("/"), http.StatusTemporaryRedirect)
 		} else {
-			s.l.Debug("AuthMethod already used")
-			http.Redirect(w, r, "/user/new", http.StatusTemporaryRedirect)
+			s.oauthLog.Debug("AuthMethod already used")
+			http.Redirect(w, r, s.sitePath("/user/new"), http.StatusTemporaryRedirect)
 		}
 	} else if strings.HasPrefix(state, "login_") {
 		user, err := s.backend.UserDiscordLogin(data["id"].(string))
 		if err != nil {
-			s.l.Info(err.Error())
-			http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+			s.oauthLog.Info(err.Error())
+			http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 			return
 		}
-		s.l.Debug("logging in %v", user.Name)
+		s.syncDiscordMod(user, isMod)
+
+		s.oauthLog.Debug("logging in %v", user.Name)
 		err = s.login(user, models.AUTH_DISCORD, w, r)
 
 		if err != nil {
-			s.l.Info(err.Error())
-			http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+			s.oauthLog.Info(err.Error())
+			http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 			return
 		}
 
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.sitePath("/"), http.StatusTemporaryRedirect)
 	} else if strings.HasPrefix(state, "add_") {
 		user := s.getSessionUser(w, r)
 
@@ -754,34 +815,51 @@ func (s *webServer) handlerDiscordOAuthCallback(w http.ResponseWriter, r *http.R
 				_, err = s.backend.AddAuthMethodToUser(auth, user)
 
 				if err != nil {
-					s.l.Info(err.Error())
-					http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+					s.oauthLog.Info(err.Error())
+					http.Redirect(w, r, s.sitePath("/user"), http.StatusTemporaryRedirect)
 					return
 				}
 
 				err = s.backend.UpdateUser(user)
 
 				if err != nil {
-					s.l.Info(err.Error())
-					http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+					s.oauthLog.Info(err.Error())
+					http.Redirect(w, r, s.sitePath("/user"), http.StatusTemporaryRedirect)
 					return
 				}
+
+				s.syncDiscordMod(user, isMod)
 			} else {
-				s.l.Info("User %s already has %s Oauth associated", user.Name, auth.Type)
-				http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+				s.oauthLog.Info("User %s already has %s Oauth associated", user.Name, auth.Type)
+				http.Redirect(w, r, s.sitePath("/user"), http.StatusTemporaryRedirect)
 				return
 			}
 		} else {
-			s.l.Info("The provided Oauth login is already used")
+			s.oauthLog.Info("The provided Oauth login is already used")
 
 			s.callbackError = callbackError{
 				user:    user.Id,
 				message: "The provided Oauth login is already used",
 			}
 		}
-		http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.sitePath("/user"), http.StatusTemporaryRedirect)
+		return
+	}
+}
+
+// Promote the user to PRIV_MOD if they hold a Discord mod role.  Users are
+// never demoted here so manual promotions stay untouched.
+func (s *webServer) syncDiscordMod(user *models.User, isMod bool) {
+	if !isMod || user.Privilege >= models.PRIV_MOD {
+		return
+	}
+
+	user.Privilege = models.PRIV_MOD
+	if err := s.backend.UpdateUser(user); err != nil {
+		s.oauthLog.Error("Unable to promote %s to mod: %v", user.Name, err)
 		return
 	}
+	s.oauthLog.Info("Promoted %s to mod through a Discord role", user.Name)
 }
 
 func (s *webServer) handlerPatreonOAuth(w http.ResponseWriter, r *http.Request) {
@@ -797,7 +875,7 @@ func (s *webServer) handlerPatreonOAuth(w http.ResponseWriter, r *http.Request)
 		url := patreonOAuthConfig.AuthCodeURL(oauthStateString)
 		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 
-		s.l.Debug("patreon login")
+		s.oauthLog.Debug("patreon login")
 
 	case signupSwitchString:
 		// Generate a new state string for each login attempt and store it in the state list
@@ -808,7 +886,7 @@ func (s *webServer) handlerPatreonOAuth(w http.ResponseWriter, r *http.Request)
 		url := patreonOAuthConfig.AuthCodeURL(oauthStateString)
 		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 
-		s.l.Debug("patreon signup")
+		s.oauthLog.Debug("patreon signup")
 
 	case addSwitchString:
 		// Generate a new state string for each login attempt and store it in the state list
@@ -819,73 +897,78 @@ func (s *webServer) handlerPatreonOAuth(w http.ResponseWriter, r *http.Request)
 		url := patreonOAuthConfig.AuthCodeURL(oauthStateString)
 		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 
-		s.l.Debug("patreon add")
+		s.oauthLog.Debug("patreon add")
 
 	case removeSwitchString:
+		if r.Method != http.MethodPost {
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
+			return
+		}
+
 		user := s.getSessionUser(w, r)
 
 		auth, err := user.GetAuthMethod(models.AUTH_PATREON)
 
 		if err != nil {
-			s.l.Info("User %s does not have Patreon Oauth associated with him", user.Name)
-			http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("User %s does not have Patreon Oauth associated with him", user.Name)
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 			return
 		}
 
 		if len(user.AuthMethods) == 1 {
-			s.l.Info("User %v only has Patreon Oauth associated with him", user.Name)
-			http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("User %v only has Patreon Oauth associated with him", user.Name)
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 			return
 		}
 
 		user, err = s.backend.RemoveAuthMethodFromUser(auth, user)
 
 		if err != nil {
-			s.l.Info("Could not remove Patreon Oauth from user. %s", err.Error())
-			http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("Could not remove Patreon Oauth from user. %s", err.Error())
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 			return
 		}
 
 		err = s.backend.UpdateUser(user)
 		if err != nil {
-			s.l.Info("Could not update user %s", user.Name)
-			http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("Could not update user %s", user.Name)
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 			return
 		}
 
 		err = s.logout(w, r)
 		if err != nil {
-			s.l.Info("Could not logout user %s", user.Name)
-			http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("Could not logout user %s", user.Name)
+			http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 			return
 		}
 
 		if _, err := user.GetAuthMethod(models.AUTH_TWITCH); err == nil {
 			err = s.login(user, models.AUTH_TWITCH, w, r)
 			if err != nil {
-				s.l.Info("Could not login user %s", user.Name)
-				http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+				s.oauthLog.Info("Could not login user %s", user.Name)
+				http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 				return
 			}
 		} else if _, err := user.GetAuthMethod(models.AUTH_DISCORD); err == nil {
 			err = s.login(user, models.AUTH_DISCORD, w, r)
 			if err != nil {
-				s.l.Info("Could not login user %s", user.Name)
-				http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+				s.oauthLog.Info("Could not login user %s", user.Name)
+				http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 				return
 			}
 		} else if _, err := user.GetAuthMethod(models.AUTH_LOCAL); err == nil {
 			err = s.login(user, models.AUTH_LOCAL, w, r)
 			if err != nil {
-				s.l.Info("Could not login user %s", user.Name)
-				http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+				s.oauthLog.Info("Could not login user %s", user.Name)
+				http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 				return
 			}
 		}
 
-		s.l.Debug("patreon remove")
+		s.oauthLog.Debug("patreon remove")
 
-		http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 		return
 	}
 }
@@ -900,16 +983,16 @@ func (s *webServer) handlerPatreonOAuthCallback(w http.ResponseWriter, r *http.R
 		}
 	}
 	if !ok {
-		s.l.Info("Invalid/Unknown OAuth state string: '%s'", state)
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info("Invalid/Unknown OAuth state string: '%s'", state)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
 	code := r.FormValue("code")
 	token, err := patreonOAuthConfig.Exchange(context.Background(), code)
 	if err != nil {
-		s.l.Info("Code exchange failed: %s", err)
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info("Code exchange failed: %s", err)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
@@ -917,8 +1000,8 @@ func (s *webServer) handlerPatreonOAuthCallback(w http.ResponseWriter, r *http.R
 	req, err := http.NewRequest("GET", "https://www.patreon.com/api/oauth2/v2/identity?fields"+u.QueryEscape("[user]")+"=email,first_name,full_name,last_name,vanity", nil)
 
 	if err != nil {
-		s.l.Info("Could not retrieve Userdata from Patreon API: %s", err)
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info("Could not retrieve Userdata from Patreon API: %s", err)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
@@ -927,34 +1010,38 @@ func (s *webServer) handlerPatreonOAuthCallback(w http.ResponseWriter, r *http.R
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		s.l.Info("Could not retrieve Userdata from Patreon API: %s", err)
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info("Could not retrieve Userdata from Patreon API: %s", err)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		s.l.Info("Status Code is not 200, its %v", resp.Status)
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info("Status Code is not 200, its %v", resp.Status)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		s.l.Info(err.Error())
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info(err.Error())
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
 	var data map[string]interface{}
 
 	if err := json.Unmarshal(body, &data); err != nil {
-		s.l.Info(err.Error())
-		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+		s.oauthLog.Info(err.Error())
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 		return
 	}
 
 	data = data["data"].(map[string]interface{})
 
+	if s.rejectOauthBan(data["id"].(string), models.AUTH_PATREON, w, r) {
+		return
+	}
+
 	if strings.HasPrefix(state, "signup_") {
 
 		auth := &models.AuthMethod{
@@ -977,56 +1064,56 @@ func (s *webServer) handlerPatreonOAuthCallback(w http.ResponseWriter, r *http.R
 			newUser.Id, err = s.backend.AddUser(newUser)
 
 			if err != nil {
-				s.l.Info(err.Error())
-				http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+				s.oauthLog.Info(err.Error())
+				http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 				return
 			}
 
 			newUser, err = s.backend.AddAuthMethodToUser(auth, newUser)
 
 			if err != nil {
-				s.l.Info(err.Error())
-				http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+				s.oauthLog.Info(err.Error())
+				http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 				return
 			}
 
 			err = s.backend.UpdateUser(newUser)
 
 			if err != nil {
-				s.l.Info(err.Error())
-				http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+				s.oauthLog.Info(err.Error())
+				http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 				return
 			}
 
-			s.l.Debug("logging in %v", newUser.Name)
+			s.oauthLog.Debug("logging in %v", newUser.Name)
 
 			err = s.login(newUser, models.AUTH_PATREON, w, r)
 
 			if err != nil {
-				s.l.Info(err.Error())
-				http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+				s.oauthLog.Info(err.Error())
+				http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 				return
 			}
-			http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+			http.Redirect(w, r, s.sitePath("/"), http.StatusTemporaryRedirect)
 		} else {
-			s.l.Info("AuthMethod already used")
-			http.Redirect(w, r, "/user/new", http.StatusTemporaryRedirect)
+			s.oauthLog.Info("AuthMethod already used")
+			http.Redirect(w, r, s.sitePath("/user/new"), http.StatusTemporaryRedirect)
 		}
 	} else if strings.HasPrefix(state, "login_") {
 		user, err := s.backend.UserPatreonLogin(data["id"].(string))
 		if err != nil {
-			s.l.Info(err.Error())
-			http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+			s.oauthLog.Info(err.Error())
+			http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 			return
 		}
-		s.l.Debug("logging in %v", user.Name)
+		s.oauthLog.Debug("logging in %v", user.Name)
 		err = s.login(user, models.AUTH_PATREON, w, r)
 		if err != nil {
-			s.l.Info(err.Error())
-			http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
+			s.oauthLog.Info(err.Error())
+			http.Redirect(w, r, s.sitePath("/user/login"), http.StatusTemporaryRedirect)
 			return
 		}
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.sitePath("/"), http.StatusTemporaryRedirect)
 	} else if strings.HasPrefix(state, "add_") {
 		user := s.getSessionUser(w, r)
 
@@ -1044,38 +1131,56 @@ func (s *webServer) handlerPatreonOAuthCallback(w http.ResponseWriter, r *http.R
 				_, err = s.backend.AddAuthMethodToUser(auth, user)
 
 				if err != nil {
-					s.l.Info(err.Error())
-					http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+					s.oauthLog.Info(err.Error())
+					http.Redirect(w, r, s.sitePath("/user"), http.StatusTemporaryRedirect)
 					return
 				}
 
 				err = s.backend.UpdateUser(user)
 
 				if err != nil {
-					s.l.Info(err.Error())
-					http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+					s.oauthLog.Info(err.Error())
+					http.Redirect(w, r, s.sitePath("/user"), http.StatusTemporaryRedirect)
 					return
 				}
 			} else {
-				s.l.Info("User %s already has %s Oauth associated", user.Name, auth.Type)
-				http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+				s.oauthLog.Info("User %s already has %s Oauth associated", user.Name, auth.Type)
+				http.Redirect(w, r, s.sitePath("/user"), http.StatusTemporaryRedirect)
 				return
 			}
 		} else {
-			s.l.Info("The provided Oauth login is already used")
+			s.oauthLog.Info("The provided Oauth login is already used")
 
 			s.callbackError = callbackError{
 				user:    user.Id,
 				message: "The provided Oauth login is already used",
 			}
 		}
-		http.Redirect(w, r, "/user", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.sitePath("/user"), http.StatusTemporaryRedirect)
 		return
 	}
 }
 
 var re_auth = regexp.MustCompile(`^/auth/([^/#?]+)$`)
 
+// Renders an error page and returns true if the external ID is banned.
+func (s *webServer) rejectOauthBan(extId string, authType models.AuthType, w http.ResponseWriter, r *http.Request) bool {
+	ban, err := s.backend.GetOauthBan(extId, authType)
+	if err != nil {
+		s.oauthLog.Error("Unable to check bans for %s login %s: %v", authType, extId, err)
+		s.doError(http.StatusInternalServerError, "Something went wrong :C", w, r)
+		return true
+	}
+
+	if ban == nil {
+		return false
+	}
+
+	s.oauthLog.Info("Rejected banned %s login %s", authType, extId)
+	s.doError(http.StatusForbidden, ban.Message(), w, r)
+	return true
+}
+
 func (s *webServer) handlerAuth(w http.ResponseWriter, r *http.Request) {
 	user := s.getSessionUser(w, r)
 
@@ -1144,7 +1249,7 @@ func (s *webServer) handlerAuth(w http.ResponseWriter, r *http.Request) {
 
 			s.l.Info("%s has claimed Admin", user.Name)
 			s.backend.DeleteUrlKey(key)
-			http.Redirect(w, r, "/", http.StatusSeeOther)
+			http.Redirect(w, r, s.sitePath("/"), http.StatusSeeOther)
 			return
 		}
 
@@ -1208,7 +1313,7 @@ func (s *webServer) handlerAuth(w http.ResponseWriter, r *http.Request) {
 
 					s.l.Info("User %q has reset their password", user.Name)
 					s.backend.DeleteUrlKey(key)
-					http.Redirect(w, r, "/", http.StatusSeeOther)
+					http.Redirect(w, r, s.sitePath("/"), http.StatusSeeOther)
 					return
 				}
 			} // if POST
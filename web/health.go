@@ -0,0 +1,51 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// readyTimeout is how long /readyz waits for the database.
+const readyTimeout = 2 * time.Second
+
+// handlerHealthz answers as long as the server is running.
+func (s *webServer) handlerHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// handlerReadyz answers with 503 while the server shuts down or if the
+// database doesn't answer, so load balancers stop sending requests.
+func (s *webServer) handlerReadyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if atomic.LoadInt32(&s.shuttingDown) != 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "shutting down")
+		return
+	}
+
+	// A stuck database must not hang the check as well.
+	result := make(chan error, 1)
+	go func() {
+		result <- s.backend.Ping()
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-time.After(readyTimeout):
+		err = fmt.Errorf("no answer after %s", readyTimeout)
+	}
+
+	if err != nil {
+		s.l.Error("Readiness check failed: %v", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "database unavailable")
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
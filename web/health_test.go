@@ -0,0 +1,68 @@
+package web
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReadyzDuringShutdown(t *testing.T) {
+	s := &webServer{shutdownDrain: time.Hour}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/readyz", s.handlerReadyz)
+	s.s = &http.Server{Handler: mux}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.s.Serve(ln)
+	url := "http://" + ln.Addr().String() + "/readyz"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Shutdown(ctx)
+	}()
+
+	// The listener stays open while the drain time runs, and /readyz
+	// tells the load balancer to stop sending requests.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("GET /readyz during the drain returned an error: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GET /readyz returned %d, expected %d", resp.StatusCode, http.StatusServiceUnavailable)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown() returned %v before the drain time was over", err)
+	default:
+	}
+
+	// The drain is cut short once ctx is done.
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Shutdown() didn't return after ctx was done")
+	}
+
+	if resp, err := http.Get(url); err == nil {
+		resp.Body.Close()
+		t.Errorf("The listener is still open after Shutdown()")
+	}
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/metrics"
+)
+
+// activeUserWindow is how long a user counts as active after their last
+// request.
+const activeUserWindow = 15 * time.Minute
+
+var (
+	metricRequests = metrics.NewCounter(
+		"moviepolls_http_requests_total",
+		"HTTP requests by handler, method and status code.",
+		"handler", "method", "code")
+
+	metricRequestDuration = metrics.NewHistogram(
+		"moviepolls_http_request_duration_seconds",
+		"Duration of HTTP requests by handler.",
+		metrics.DefaultBuckets,
+		"handler")
+
+	activeUsers = &userTracker{seen: map[int]time.Time{}}
+
+	_ = metrics.NewGaugeFunc(
+		"moviepolls_active_users",
+		"Logged in users that made a request in the last 15 minutes.",
+		func() float64 { return float64(activeUsers.count(time.Now())) })
+)
+
+// userTracker remembers when each user was last seen.
+type userTracker struct {
+	lock sync.Mutex
+	seen map[int]time.Time
+}
+
+func (t *userTracker) touch(id int, now time.Time) {
+	t.lock.Lock()
+	t.seen[id] = now
+	t.lock.Unlock()
+}
+
+// count returns the number of active users and forgets the others.
+func (t *userTracker) count(now time.Time) int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for id, seen := range t.seen {
+		if now.Sub(seen) > activeUserWindow {
+			delete(t.seen, id)
+		}
+	}
+	return len(t.seen)
+}
+
+// metricMethod keeps made up methods from adding series.
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
+
+// instrument records the requests to a handler, labeled with the path it
+// is registered under.
+func instrument(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := recordResponse(w)
+		finished := false
+
+		defer func() {
+			status := rec.status
+			if !finished {
+				// The handler panicked, recoverPanic answers with a 500.
+				status = http.StatusInternalServerError
+			} else if status == 0 {
+				status = http.StatusOK
+			}
+
+			metricRequests.Inc(pattern, metricMethod(r.Method), strconv.Itoa(status))
+			metricRequestDuration.ObserveSince(start, pattern)
+		}()
+
+		next.ServeHTTP(rec, r)
+		finished = true
+	})
+}
@@ -0,0 +1,180 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+const requestIdHeader = "X-Request-Id"
+
+// The templates use inline scripts and styles, the icons come from the
+// fontawesome and google CDNs, and posters can be redirected to the poster
+// storage.
+const contentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline'; " +
+	"style-src 'self' 'unsafe-inline' https://use.fontawesome.com https://fonts.googleapis.com; " +
+	"font-src 'self' https://use.fontawesome.com https://fonts.gstatic.com; " +
+	"img-src 'self' data: https:; " +
+	"object-src 'none'; " +
+	"base-uri 'self'; " +
+	"frame-ancestors 'none'"
+
+type contextKey int
+
+const (
+	ctxRequestId contextKey = iota
+	ctxForwardedHTTPS
+)
+
+type middleware func(http.Handler) http.Handler
+
+// chain wraps h in the given middlewares.  The first one is the outermost
+// and sees the request first.
+func chain(h http.Handler, middlewares ...middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// statusRecorder keeps track of the status and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// recordResponse returns w if it already records the response, otherwise it
+// wraps it.
+func recordResponse(w http.ResponseWriter) *statusRecorder {
+	if rec, ok := w.(*statusRecorder); ok {
+		return rec
+	}
+	return &statusRecorder{ResponseWriter: w}
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if rec.status != 0 {
+		return
+	}
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// wroteHeader reports whether the status has been sent already.
+func (rec *statusRecorder) wroteHeader() bool {
+	return rec.status != 0
+}
+
+func newRequestId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "-"
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestId returns the ID given to the request by withRequestId.
+func requestId(r *http.Request) string {
+	if id, ok := r.Context().Value(ctxRequestId).(string); ok {
+		return id
+	}
+	return "-"
+}
+
+// withRequestId gives every request an ID that is sent back in the
+// X-Request-Id header and added to the log entries of the request.
+func withRequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := newRequestId()
+		w.Header().Set(requestIdHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxRequestId, id)))
+	})
+}
+
+// securityHeaders sets the headers that keep browsers from framing the
+// pages, sniffing content types and loading things from other sites.  HSTS
+// is only sent over HTTPS.
+func securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Security-Policy", contentSecurityPolicy)
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "same-origin")
+		if isHTTPS(r) {
+			h.Set("Strict-Transport-Security", "max-age=31536000")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// accessLog writes a line for every request once it has been served.
+func (s *webServer) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := recordResponse(w)
+
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			// Nothing was written, net/http sends an empty 200.
+			status = http.StatusOK
+		}
+
+		s.accessLogger.With(
+			"id", requestId(r),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", rec.bytes,
+			"duration", time.Since(start).Round(time.Microsecond),
+			"remote", r.RemoteAddr,
+		).Info("request")
+	})
+}
+
+// recoverPanic logs a panicking handler with its stack and shows the error
+// page instead of dropping the connection.
+func (s *webServer) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := recordResponse(w)
+
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+
+			// Used by net/http to abort a response on purpose.
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			s.l.Error("Panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, requestId(r), err, debug.Stack())
+
+			if rec.wroteHeader() {
+				// Too late for an error page, the client gets a cut off
+				// response.
+				return
+			}
+			s.doError(http.StatusInternalServerError, "Something went wrong :C", rec, r)
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -3,6 +3,7 @@ package web
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/zorchenhimer/MoviePolls/logic"
 )
@@ -12,7 +13,11 @@ func (s *webServer) handlerPageAddMovie(w http.ResponseWriter, r *http.Request)
 	// Get the user which adds a movie
 	user := s.getSessionUser(w, r)
 	if user == nil {
-		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusSeeOther)
+		return
+	}
+
+	if s.rejectBannedUser(user, w, r) {
 		return
 	}
 
@@ -117,6 +122,11 @@ func (s *webServer) handlerPageAddMovie(w http.ResponseWriter, r *http.Request)
 		MaxRemarksLength     int
 
 		FileError error
+
+		// Title search results
+		Query       string
+		Results     []*logic.SearchResult
+		SearchError error
 	}{
 		dataPageBase: s.newPageBase("Add Movie", w, r),
 
@@ -129,6 +139,21 @@ func (s *webServer) handlerPageAddMovie(w http.ResponseWriter, r *http.Request)
 		MaxRemarksLength:     maxRemLen,
 	}
 
+	if r.Method == http.MethodGet && autofillEnabled {
+		// Picking a search result fills in its link and lets autofill do the rest.
+		if link := r.URL.Query().Get("link"); link != "" {
+			data.Fields = map[string]*logic.InputField{
+				"Links":       {Value: link},
+				"AutofillBox": {Value: "on"},
+			}
+		}
+
+		data.Query = strings.TrimSpace(r.URL.Query().Get("search"))
+		if data.Query != "" {
+			data.Results, data.SearchError = s.backend.SearchMetadata(data.Query)
+		}
+	}
+
 	if r.Method == http.MethodPost {
 		err = r.ParseMultipartForm(4096)
 		if err != nil {
@@ -155,7 +180,7 @@ func (s *webServer) handlerPageAddMovie(w http.ResponseWriter, r *http.Request)
 		}
 
 		if !hasError && movieId != -1 {
-			http.Redirect(w, r, fmt.Sprintf("/movie/%d", movieId), http.StatusFound)
+			http.Redirect(w, r, s.sitePath(fmt.Sprintf("/movie/%d", movieId)), http.StatusFound)
 			return
 		} else {
 			data.Fields = fields
@@ -1,13 +1,15 @@
 package web
 
 import (
+	"encoding/csv"
 	"fmt"
+	"io"
 	"net/http"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/zorchenhimer/MoviePolls/logger"
 	"github.com/zorchenhimer/MoviePolls/logic"
 	"github.com/zorchenhimer/MoviePolls/models"
 )
@@ -41,9 +43,9 @@ func (s *webServer) handlerAdminHome(w http.ResponseWriter, r *http.Request) {
 
 func (s *webServer) handlerAdminUsers(w http.ResponseWriter, r *http.Request) {
 	user := s.getSessionUser(w, r)
-	if !s.backend.CheckAdminRights(user) {
+	if !s.backend.CheckPermission(user, models.PERM_MANAGE_USERS) {
 		if s.debug {
-			s.doError(http.StatusUnauthorized, "You are not an admin.", w, r)
+			s.doError(http.StatusUnauthorized, "You do not have the ManageUsers permission.", w, r)
 		}
 		s.doError(http.StatusNotFound, fmt.Sprintf("%q not found", r.URL.Path), w, r)
 		return
@@ -58,13 +60,31 @@ func (s *webServer) handlerAdminUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	bans, err := s.backend.GetBans()
+	if err != nil {
+		s.doError(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Error getting bans: %v", err),
+			w, r)
+		return
+	}
+
+	banned := map[int]bool{}
+	for _, ban := range bans {
+		if ban.Active() {
+			banned[ban.UserId] = true
+		}
+	}
+
 	data := struct {
 		dataPageBase
 
-		Users []*models.User
+		Users  []*models.User
+		Banned map[int]bool
 	}{
 		dataPageBase: s.newPageBase("Admin - Users", w, r),
 		Users:        ulist,
+		Banned:       banned,
 	}
 
 	if err := s.executeTemplate(w, "adminUsers", data); err != nil {
@@ -72,9 +92,135 @@ func (s *webServer) handlerAdminUsers(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *webServer) handlerAdminUserEdit(w http.ResponseWriter, r *http.Request) {
+func (s *webServer) handlerAdminBans(w http.ResponseWriter, r *http.Request) {
 	user := s.getSessionUser(w, r)
-	if !s.backend.CheckAdminRights(user) {
+	if !s.backend.CheckPermission(user, models.PERM_MANAGE_USERS) {
+		if s.debug {
+			s.doError(http.StatusUnauthorized, "You do not have the ManageUsers permission.", w, r)
+		}
+		s.doError(http.StatusNotFound, fmt.Sprintf("%q not found", r.URL.Path), w, r)
+		return
+	}
+
+	bans, err := s.backend.GetBans()
+	if err != nil {
+		s.doError(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Error getting bans: %v", err),
+			w, r)
+		return
+	}
+
+	type banRow struct {
+		*models.Ban
+		User     *models.User
+		BannedBy *models.User
+	}
+
+	rows := []banRow{}
+	for _, ban := range bans {
+		row := banRow{Ban: ban}
+		// Missing users are shown as IDs only
+		row.User, _ = s.backend.GetUser(ban.UserId)
+		if ban.BannedBy != 0 {
+			row.BannedBy, _ = s.backend.GetUser(ban.BannedBy)
+		}
+		rows = append(rows, row)
+	}
+
+	data := struct {
+		dataPageBase
+
+		Bans []banRow
+	}{
+		dataPageBase: s.newPageBase("Admin - Bans", w, r),
+		Bans:         rows,
+	}
+
+	if err := s.executeTemplate(w, "adminBans", data); err != nil {
+		s.l.Error("Error rendering template: %v", err)
+	}
+}
+
+func (s *webServer) handlerAdminRoles(w http.ResponseWriter, r *http.Request) {
+	user := s.getSessionUser(w, r)
+	if user == nil || !user.IsAdmin() {
+		if s.debug {
+			s.doError(http.StatusUnauthorized, "You are not an admin.", w, r)
+		}
+		s.doError(http.StatusNotFound, fmt.Sprintf("%q not found", r.URL.Path), w, r)
+		return
+	}
+
+	data := struct {
+		dataPageBase
+
+		Roles        []*models.Role
+		Permissions  []models.Permission
+		ErrorMessage string
+	}{
+		Permissions: models.Permissions,
+	}
+
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			s.l.Error("Unable to parse form: %v", err)
+			s.doError(http.StatusInternalServerError, "Unable to parse form", w, r)
+			return
+		}
+
+		role := &models.Role{
+			Name:        r.PostFormValue("Name"),
+			Permissions: []models.Permission{},
+		}
+		for _, p := range r.PostForm["Permission"] {
+			role.Permissions = append(role.Permissions, models.Permission(p))
+		}
+
+		var err error
+		switch r.PostFormValue("Action") {
+		case "add":
+			_, err = s.backend.AddRole(user, role)
+		case "update", "delete":
+			role.Id, err = strconv.Atoi(r.PostFormValue("RoleId"))
+			if err != nil {
+				err = fmt.Errorf("Invalid role ID")
+				break
+			}
+
+			if r.PostFormValue("Action") == "update" {
+				err = s.backend.UpdateRole(user, role)
+			} else {
+				err = s.backend.DeleteRole(user, role)
+			}
+		default:
+			err = fmt.Errorf("Unknown action")
+		}
+
+		if err != nil {
+			data.ErrorMessage = err.Error()
+		}
+	}
+
+	roles, err := s.backend.GetRoles()
+	if err != nil {
+		s.doError(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Error getting roles: %v", err),
+			w, r)
+		return
+	}
+	data.Roles = roles
+	data.dataPageBase = s.newPageBase("Admin - Roles", w, r)
+
+	if err := s.executeTemplate(w, "adminRoles", data); err != nil {
+		s.l.Error("Error rendering template: %v", err)
+	}
+}
+
+func (s *webServer) handlerAdminAudit(w http.ResponseWriter, r *http.Request) {
+	user := s.getSessionUser(w, r)
+	if user == nil || !user.IsAdmin() {
 		if s.debug {
 			s.doError(http.StatusUnauthorized, "You are not an admin.", w, r)
 		}
@@ -82,6 +228,299 @@ func (s *webServer) handlerAdminUserEdit(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		Actor:  strings.TrimSpace(query.Get("actor")),
+		Action: models.AuditAction(query.Get("action")),
+		Target: strings.TrimSpace(query.Get("target")),
+	}
+
+	errorMessage := []string{}
+	if val := query.Get("since"); val != "" {
+		t, err := time.ParseInLocation("2006-01-02", val, time.Local)
+		if err != nil {
+			errorMessage = append(errorMessage, "Invalid start date")
+		} else {
+			filter.Since = &t
+		}
+	}
+
+	if val := query.Get("until"); val != "" {
+		t, err := time.ParseInLocation("2006-01-02", val, time.Local)
+		if err != nil {
+			errorMessage = append(errorMessage, "Invalid end date")
+		} else {
+			// Include the whole day
+			t = t.AddDate(0, 0, 1)
+			filter.Until = &t
+		}
+	}
+
+	entries, err := s.backend.GetAuditLog(filter)
+	if err != nil {
+		s.doError(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Error getting audit log: %v", err),
+			w, r)
+		return
+	}
+
+	if query.Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"audit-%s.csv\"", time.Now().Format("20060102-150405")))
+
+		if err := writeAuditCsv(w, entries); err != nil {
+			s.l.Error("Unable to write audit CSV: %v", err)
+		}
+		return
+	}
+
+	csvQuery := r.URL.Query()
+	csvQuery.Set("format", "csv")
+
+	data := struct {
+		dataPageBase
+
+		Entries      []*models.AuditEntry
+		Actions      []models.AuditAction
+		ErrorMessage []string
+		CsvLink      string
+
+		ValActor  string
+		ValAction string
+		ValTarget string
+		ValSince  string
+		ValUntil  string
+	}{
+		dataPageBase: s.newPageBase("Admin - Audit Log", w, r),
+
+		Entries:      entries,
+		Actions:      models.AuditActions,
+		ErrorMessage: errorMessage,
+		CsvLink:      "/admin/audit?" + csvQuery.Encode(),
+
+		ValActor:  filter.Actor,
+		ValAction: string(filter.Action),
+		ValTarget: filter.Target,
+		ValSince:  query.Get("since"),
+		ValUntil:  query.Get("until"),
+	}
+
+	if err := s.executeTemplate(w, "adminAudit", data); err != nil {
+		s.l.Error("Error rendering template: %v", err)
+	}
+}
+
+// writeAuditCsv writes the audit entries as CSV.
+func writeAuditCsv(w io.Writer, entries []*models.AuditEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Id", "Timestamp", "ActorId", "Actor", "Action", "TargetType", "TargetId", "Target", "Before", "After"})
+	for _, e := range entries {
+		cw.Write([]string{
+			strconv.Itoa(e.Id),
+			e.Timestamp.Format(time.RFC3339),
+			strconv.Itoa(e.ActorId),
+			csvCell(e.ActorName),
+			string(e.Action),
+			csvCell(e.TargetType),
+			strconv.Itoa(e.TargetId),
+			csvCell(e.TargetName),
+			csvCell(e.Before),
+			csvCell(e.After),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell keeps spreadsheets from running user input as a formula, eg a
+// movie titled "=HYPERLINK(...)".  Cells that would start one are prefixed
+// with a quote.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsAny(value[:1], "=+-@\t\r") {
+		return "'" + value
+	}
+	return value
+}
+
+func (s *webServer) handlerAdminTags(w http.ResponseWriter, r *http.Request) {
+	user := s.getSessionUser(w, r)
+	if !s.backend.CheckPermission(user, models.PERM_MANAGE_TAGS) {
+		if s.debug {
+			s.doError(http.StatusUnauthorized, "You do not have the ManageTags permission.", w, r)
+		}
+		s.doError(http.StatusNotFound, fmt.Sprintf("%q not found", r.URL.Path), w, r)
+		return
+	}
+
+	errorMessage := ""
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			s.doError(http.StatusBadRequest, "Invalid form", w, r)
+			return
+		}
+
+		id, _ := strconv.Atoi(r.PostFormValue("id"))
+
+		var err error
+		switch r.PostFormValue("action") {
+		case "rename":
+			err = s.backend.AdminRenameTag(user, id, r.PostFormValue("name"))
+		case "merge":
+			ids := []int{}
+			for _, val := range r.PostForm["tag"] {
+				if tid, e := strconv.Atoi(val); e == nil {
+					ids = append(ids, tid)
+				}
+			}
+			into, _ := strconv.Atoi(r.PostFormValue("into"))
+			err = s.backend.AdminMergeTags(user, into, ids)
+		case "delete":
+			err = s.backend.AdminDeleteTag(user, id)
+		default:
+			err = fmt.Errorf("Unknown action")
+		}
+
+		if err == nil {
+			http.Redirect(w, r, s.sitePath("/admin/tags"), http.StatusSeeOther)
+			return
+		}
+		errorMessage = err.Error()
+	}
+
+	usage, err := s.backend.GetTagUsage()
+	if err != nil {
+		s.doError(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Unable to get tags: %v", err),
+			w, r)
+		return
+	}
+
+	dupes, err := s.backend.GetDuplicateTags()
+	if err != nil {
+		s.doError(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Unable to get tags: %v", err),
+			w, r)
+		return
+	}
+
+	data := struct {
+		dataPageBase
+
+		Tags         []*logic.TagUsage
+		Duplicates   [][]*logic.TagUsage
+		ErrorMessage string
+	}{
+		dataPageBase: s.newPageBase("Admin - Tags", w, r),
+
+		Tags:         usage,
+		Duplicates:   dupes,
+		ErrorMessage: errorMessage,
+	}
+
+	if err := s.executeTemplate(w, "adminTags", data); err != nil {
+		s.l.Error("Error rendering template: %v", err)
+	}
+}
+
+// handlerAdminCleanup shows the unused posters, links and tags on GET and
+// deletes them on POST.
+func (s *webServer) handlerAdminCleanup(w http.ResponseWriter, r *http.Request) {
+	user := s.getSessionUser(w, r)
+	if user == nil || !user.IsAdmin() {
+		if s.debug {
+			s.doError(http.StatusUnauthorized, "You are not an admin.", w, r)
+		}
+		s.doError(http.StatusNotFound, fmt.Sprintf("%q not found", r.URL.Path), w, r)
+		return
+	}
+
+	report, err := s.backend.Cleanup(user, r.Method != http.MethodPost)
+	if err != nil {
+		s.doError(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Cleanup failed: %v", err),
+			w, r)
+		return
+	}
+
+	data := struct {
+		dataPageBase
+
+		Report       *logic.CleanupReport
+		ErrorMessage []string
+	}{
+		dataPageBase: s.newPageBase("Admin - Cleanup", w, r),
+
+		Report:       report,
+		ErrorMessage: report.Errors,
+	}
+
+	if err := s.executeTemplate(w, "adminCleanup", data); err != nil {
+		s.l.Error("Error rendering template: %v", err)
+	}
+}
+
+func (s *webServer) handlerAdminLogging(w http.ResponseWriter, r *http.Request) {
+	user := s.getSessionUser(w, r)
+	if user == nil || !user.IsAdmin() {
+		if s.debug {
+			s.doError(http.StatusUnauthorized, "You are not an admin.", w, r)
+		}
+		s.doError(http.StatusNotFound, fmt.Sprintf("%q not found", r.URL.Path), w, r)
+		return
+	}
+
+	errText := []string{}
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			s.doError(http.StatusBadRequest, fmt.Sprintf("Unable to parse form: %v", err), w, r)
+			return
+		}
+
+		for subsystem := range s.backend.GetLogLevels() {
+			level := r.PostFormValue(subsystem)
+			if level == "" {
+				continue
+			}
+
+			if err := s.backend.AdminSetLogLevel(user, subsystem, logger.LogLevel(level)); err != nil {
+				errText = append(errText, fmt.Sprintf("Unable to set the level of %s: %v", subsystem, err))
+			}
+		}
+	}
+
+	data := struct {
+		dataPageBase
+
+		Levels       map[string]logger.LogLevel
+		AllLevels    []logger.LogLevel
+		ErrorMessage []string
+	}{
+		dataPageBase: s.newPageBase("Admin - Logging", w, r),
+
+		Levels:       s.backend.GetLogLevels(),
+		AllLevels:    []logger.LogLevel{logger.LLDebug, logger.LLInfo, logger.LLError, logger.LLSilent},
+		ErrorMessage: errText,
+	}
+
+	if err := s.executeTemplate(w, "adminLogging", data); err != nil {
+		s.l.Error("Error rendering template: %v", err)
+	}
+}
+
+func (s *webServer) handlerAdminUserEdit(w http.ResponseWriter, r *http.Request) {
+	user := s.getSessionUser(w, r)
+	if !s.backend.CheckPermission(user, models.PERM_MANAGE_USERS) {
+		if s.debug {
+			s.doError(http.StatusUnauthorized, "You do not have the ManageUsers permission.", w, r)
+		}
+		s.doError(http.StatusNotFound, fmt.Sprintf("%q not found", r.URL.Path), w, r)
+		return
+	}
+
 	var uid int
 	_, err := fmt.Sscanf(r.URL.Path, "/admin/user/%d", &uid)
 	if err != nil {
@@ -92,6 +531,7 @@ func (s *webServer) handlerAdminUserEdit(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	sessionUser := user
 	user, err = s.backend.GetUser(uid)
 	if err != nil {
 		s.doError(
@@ -101,6 +541,12 @@ func (s *webServer) handlerAdminUserEdit(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Only admins can make changes to other admins
+	if user.IsAdmin() && !sessionUser.IsAdmin() {
+		s.doError(http.StatusForbidden, "Only admins can edit other admins.", w, r)
+		return
+	}
+
 	action := r.URL.Query().Get("action")
 	var urlKey *models.UrlKey
 	switch action {
@@ -108,11 +554,11 @@ func (s *webServer) handlerAdminUserEdit(w http.ResponseWriter, r *http.Request)
 	//	// current function
 	case "delete":
 
-		confirm := r.URL.Query().Get("confirm")
-		if confirm == "yes" {
+		// The confirmation page posts back to the same URL.
+		if r.Method == http.MethodPost {
 
 			origName := user.Name
-			err = s.backend.AdminDeleteUser(user)
+			err = s.backend.AdminDeleteUser(sessionUser, user)
 			if err != nil {
 				s.doError(
 					http.StatusBadRequest,
@@ -155,7 +601,7 @@ func (s *webServer) handlerAdminUserEdit(w http.ResponseWriter, r *http.Request)
 			Message:      fmt.Sprintf("Are you sure you want to remove the account of %q?  Its votes will stay intact, but everything else will be cleared.", user.Name),
 			TrueMessage:  "Delete",
 			FalseMessage: "Cancel",
-			TrueLink:     fmt.Sprintf("/admin/user/%d?action=delete&confirm=yes", user.Id),
+			TrueLink:     fmt.Sprintf("/admin/user/%d?action=delete", user.Id),
 			FalseLink:    "/admin/users",
 		}
 
@@ -165,20 +611,128 @@ func (s *webServer) handlerAdminUserEdit(w http.ResponseWriter, r *http.Request)
 
 		return
 	case "ban":
-		err = s.backend.AdminBanUser(user)
-		if err != nil {
-			s.doError(
-				http.StatusBadRequest,
-				fmt.Sprintf("Could not ban user: %v", err),
-				w, r)
+		data := struct {
+			dataPageBase
+
+			Target       *models.User
+			ErrorMessage string
+			ValReason    string
+			ValExpires   string
+		}{
+			dataPageBase: s.newPageBase("Admin - Ban User", w, r),
+			Target:       user,
+		}
+
+		if r.Method == http.MethodPost {
+			if err = r.ParseForm(); err != nil {
+				s.l.Error("Unable to parse form: %v", err)
+				s.doError(http.StatusInternalServerError, "Unable to parse form", w, r)
+				return
+			}
+
+			data.ValReason = strings.TrimSpace(r.PostFormValue("Reason"))
+			data.ValExpires = strings.TrimSpace(r.PostFormValue("Expires"))
+
+			var expires *time.Time
+			if data.ValExpires != "" {
+				t, err := time.ParseInLocation("2006-01-02", data.ValExpires, time.Local)
+				if err != nil {
+					data.ErrorMessage = "Invalid expiry date"
+				} else {
+					expires = &t
+				}
+			}
+
+			if data.ErrorMessage == "" {
+				origName := user.Name
+				err = s.backend.AdminBanUser(sessionUser, user, data.ValReason, expires)
+				if err != nil {
+					data.ErrorMessage = fmt.Sprintf("Could not ban user: %v", err)
+				} else {
+					notice := struct {
+						dataPageBase
+
+						Message  string
+						Link     string
+						LinkText string
+					}{
+						dataPageBase: s.newPageBase("Admin - Ban User", w, r),
+
+						Message:  fmt.Sprintf("The user %q has been banned.", origName),
+						Link:     "/admin/users",
+						LinkText: "Ok",
+					}
+
+					if err := s.executeTemplate(w, "adminNotice", notice); err != nil {
+						s.l.Error("Error rendering template: %v", err)
+					}
+					return
+				}
+			}
+		}
+
+		if err := s.executeTemplate(w, "adminBan", data); err != nil {
+			s.l.Error("Error rendering template: %v", err)
+		}
+		return
+	case "unban":
+		// The confirmation page posts back to the same URL.
+		if r.Method == http.MethodPost {
+			err = s.backend.AdminUnbanUser(sessionUser, user)
+			if err != nil {
+				s.doError(
+					http.StatusBadRequest,
+					fmt.Sprintf("Could not unban user: %v", err),
+					w, r)
+				return
+			}
+
+			data := struct {
+				dataPageBase
+
+				Message  string
+				Link     string
+				LinkText string
+			}{
+				dataPageBase: s.newPageBase("Admin - Unban User", w, r),
+
+				Message:  fmt.Sprintf("The user %q has been unbanned.", user.Name),
+				Link:     "/admin/users",
+				LinkText: "Ok",
+			}
+
+			if err := s.executeTemplate(w, "adminNotice", data); err != nil {
+				s.l.Error("Error rendering template: %v", err)
+			}
 			return
 		}
+
+		data := struct {
+			dataPageBase
+
+			Message      string
+			TrueMessage  string
+			FalseMessage string
+			TrueLink     string
+			FalseLink    string
+		}{
+			dataPageBase: s.newPageBase("Admin - Unban User", w, r),
+			Message:      fmt.Sprintf("Are you sure you want to unban %q?", user.Name),
+			TrueMessage:  "Unban",
+			FalseMessage: "Cancel",
+			TrueLink:     fmt.Sprintf("/admin/user/%d?action=unban", user.Id),
+			FalseLink:    "/admin/users",
+		}
+
+		if err := s.executeTemplate(w, "adminConfirm", data); err != nil {
+			s.l.Error("Error rendering template: %v", err)
+		}
 		return
 	case "purge":
-		confirm := r.URL.Query().Get("confirm")
-		if confirm == "yes" {
+		// The confirmation page posts back to the same URL.
+		if r.Method == http.MethodPost {
 			origName := user.Name
-			err := s.backend.AdminPurgeUser(user)
+			err := s.backend.AdminPurgeUser(sessionUser, user)
 			if err != nil {
 				s.doError(
 					http.StatusBadRequest,
@@ -219,7 +773,7 @@ func (s *webServer) handlerAdminUserEdit(w http.ResponseWriter, r *http.Request)
 			Message:      fmt.Sprintf("Are you sure you want to PURGE the account of %q?  Votes will be deleted.", user.Name),
 			TrueMessage:  "PURGE",
 			FalseMessage: "Cancel",
-			TrueLink:     fmt.Sprintf("/admin/user/%d?action=purge&confirm=yes", user.Id),
+			TrueLink:     fmt.Sprintf("/admin/user/%d?action=purge", user.Id),
 			FalseLink:    "/admin/users",
 		}
 
@@ -229,7 +783,11 @@ func (s *webServer) handlerAdminUserEdit(w http.ResponseWriter, r *http.Request)
 
 		return
 	case "password":
-		urlKey, err = s.backend.NewPasswordResetKey(user.Id)
+		if r.Method != http.MethodPost {
+			break
+		}
+
+		urlKey, err = s.backend.AdminPasswordReset(sessionUser, user)
 		if err != nil {
 			s.l.Error("Unable to generate UrlKey pair for user password reset: %v", err)
 			s.doError(
@@ -241,6 +799,22 @@ func (s *webServer) handlerAdminUserEdit(w http.ResponseWriter, r *http.Request)
 
 		s.l.Debug("Saving new urlKey with URL %s", urlKey.Url)
 		s.backend.SetUrlKey(urlKey.Url, urlKey)
+	case "sessions":
+		if r.Method != http.MethodPost {
+			break
+		}
+
+		if err := s.backend.AdminRevokeSessions(sessionUser, user); err != nil {
+			s.l.Error("Unable to revoke sessions of user %d: %v", user.Id, err)
+			s.doError(
+				http.StatusInternalServerError,
+				fmt.Sprintf("Unable to revoke sessions: %v", err),
+				w, r)
+			return
+		}
+
+		http.Redirect(w, r, s.sitePath(fmt.Sprintf("/admin/user/%d", user.Id)), http.StatusSeeOther)
+		return
 	}
 
 	totalVotes, err := s.backend.GetMaxUserVotes()
@@ -265,7 +839,7 @@ func (s *webServer) handlerAdminUserEdit(w http.ResponseWriter, r *http.Request)
 	data := struct {
 		dataPageBase
 
-		User         *models.User
+		Target       *models.User
 		CurrentVotes []*models.Movie
 		//PastVotes      []*common.Movie
 		AvailableVotes int
@@ -274,15 +848,56 @@ func (s *webServer) handlerAdminUserEdit(w http.ResponseWriter, r *http.Request)
 		NotifyError []string
 		UrlKey      *models.UrlKey
 		Host        string
+		Ban         *models.Ban
+
+		CanEditRole bool
+		Roles       []*models.Role
+		RoleError   string
+
+		Sessions []*models.Session
 	}{
 		dataPageBase: s.newPageBase("Admin - User Edit", w, r),
 
-		User:         user,
+		Target:       user,
 		CurrentVotes: activeVotes,
 		//PastVotes:      watchedVotes,
 		AvailableVotes: totalVotes - len(activeVotes),
 		UrlKey:         urlKey,
-		Host:           host,
+		Host:           strings.TrimSuffix(host, "/") + s.basePath,
+	}
+
+	data.Ban, err = s.backend.GetUserBan(user)
+	if err != nil {
+		s.l.Error("Unable to get ban for user %d: %v", user.Id, err)
+	}
+
+	data.Sessions, err = s.backend.GetUserSessions(user)
+	if err != nil {
+		s.l.Error("Unable to get sessions for user %d: %v", user.Id, err)
+	}
+
+	// Roles can grant any permission, so only admins get to assign them
+	if sessionUser.IsAdmin() {
+		data.CanEditRole = true
+		data.Roles, err = s.backend.GetRoles()
+		if err != nil {
+			s.l.Error("Unable to get roles: %v", err)
+		}
+	}
+
+	if r.Method == http.MethodPost && r.PostFormValue("Form") == "Role" {
+		if !data.CanEditRole {
+			s.doError(http.StatusForbidden, "Only admins can assign roles.", w, r)
+			return
+		}
+
+		roleId, err := strconv.Atoi(r.PostFormValue("Role"))
+		if err != nil {
+			data.RoleError = "Invalid role"
+		} else if err = s.backend.AdminSetUserRole(sessionUser, user, roleId); err != nil {
+			s.l.Error("Unable to set role for user %d: %v", user.Id, err)
+			data.RoleError = fmt.Sprintf("Unable to set role: %v", err)
+		}
 	}
 
 	// TODO: handle post requests
@@ -294,9 +909,9 @@ func (s *webServer) handlerAdminUserEdit(w http.ResponseWriter, r *http.Request)
 
 func (s *webServer) handlerAdminConfig(w http.ResponseWriter, r *http.Request) {
 	user := s.getSessionUser(w, r)
-	if !s.backend.CheckAdminRights(user) {
+	if !s.backend.CheckPermission(user, models.PERM_EDIT_CONFIG) {
 		if s.debug {
-			s.doError(http.StatusUnauthorized, "You are not an admin.", w, r)
+			s.doError(http.StatusUnauthorized, "You do not have the EditConfig permission.", w, r)
 		}
 		s.doError(http.StatusNotFound, fmt.Sprintf("%q not found", r.URL.Path), w, r)
 		return
@@ -313,10 +928,17 @@ func (s *webServer) handlerAdminConfig(w http.ResponseWriter, r *http.Request) {
 		TypeStringPriv logic.ConfigValueType
 		TypeBool       logic.ConfigValueType
 		TypeInt        logic.ConfigValueType
+
+		// Private values (OAuth secrets, tokens) are only shown to admins
+		ShowPrivate bool
+		// So is rotating the session keys
+		RotateKeys bool
 	}{
 		ErrorMessage: []string{},
 		Values:       logic.ConfigValues,
 		Sections:     logic.ConfigSections,
+		ShowPrivate:  user.IsAdmin(),
+		RotateKeys:   user.IsAdmin(),
 
 		TypeString:     logic.ConfigString,
 		TypeStringPriv: logic.ConfigStringPriv,
@@ -337,10 +959,14 @@ func (s *webServer) handlerAdminConfig(w http.ResponseWriter, r *http.Request) {
 		}
 
 		for key, val := range data.Values {
+			if val.Type == logic.ConfigStringPriv && !data.ShowPrivate {
+				continue
+			}
+
 			str := r.PostFormValue(key)
 			switch val.Type {
 			case logic.ConfigString, logic.ConfigStringPriv:
-				err = s.backend.SetCfgString(key, str)
+				err = s.backend.AdminSetConfigValue(user, key, str)
 				if err != nil {
 					data.ErrorMessage = append(
 						data.ErrorMessage,
@@ -354,7 +980,7 @@ func (s *webServer) handlerAdminConfig(w http.ResponseWriter, r *http.Request) {
 						data.ErrorMessage,
 						fmt.Sprintf("Value for %q is invalid: %v", key, err))
 				} else {
-					err = s.backend.SetCfgInt(key, int(intVal))
+					err = s.backend.AdminSetConfigValue(user, key, int(intVal))
 					if err != nil {
 						data.ErrorMessage = append(
 							data.ErrorMessage,
@@ -367,7 +993,7 @@ func (s *webServer) handlerAdminConfig(w http.ResponseWriter, r *http.Request) {
 				if str != "" {
 					boolVal = true
 				}
-				err = s.backend.SetCfgBool(key, boolVal)
+				err = s.backend.AdminSetConfigValue(user, key, boolVal)
 				if err != nil {
 					data.ErrorMessage = append(
 						data.ErrorMessage,
@@ -381,15 +1007,17 @@ func (s *webServer) handlerAdminConfig(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		// Don't enable this stuff for now
-		//if clearPassSalt := r.PostFormValue("ClearPassSalt"); clearPassSalt != "" {
-		//	s.data.DeleteCfgKey("PassSalt")
-		//}
-
-		//if clearCookies := r.PostFormValue("ClearCookies"); clearCookies != "" {
-		//	s.data.DeleteCfgKey("SessionAuth")
-		//	s.data.DeleteCfgKey("SessionEncrypt")
-		//}
+		// The password salt can't be changed, every password is hashed
+		// with it.  The session keys can, the old ones stay valid for a
+		// while so nobody is logged out.
+		if r.PostFormValue("RotateSessionKeys") != "" && data.RotateKeys {
+			if err := s.backend.AdminRotateSessionKeys(user); err != nil {
+				s.l.Error("Unable to rotate session keys: %v", err)
+				data.ErrorMessage = append(
+					data.ErrorMessage,
+					fmt.Sprintf("Unable to rotate the session keys: %v", err))
+			}
+		}
 	}
 
 	newValues := map[string]logic.ConfigValue{}
@@ -409,6 +1037,8 @@ func (s *webServer) handlerAdminConfig(w http.ResponseWriter, r *http.Request) {
 			data.ErrorMessage = append(
 				data.ErrorMessage,
 				fmt.Sprintf("Unable to get config value for %s: %v", key, err))
+		} else if val.Type == logic.ConfigStringPriv && !data.ShowPrivate {
+			val.Value = ""
 		} else {
 			val.Value = v
 		}
@@ -500,9 +1130,9 @@ func (s *webServer) handlerAdminConfig(w http.ResponseWriter, r *http.Request) {
 
 func (s *webServer) handlerAdminMovieEdit(w http.ResponseWriter, r *http.Request) {
 	user := s.getSessionUser(w, r)
-	if !s.backend.CheckAdminRights(user) {
+	if !s.canModerateMovies(user) {
 		if s.debug {
-			s.doError(http.StatusUnauthorized, "You are not an admin.", w, r)
+			s.doError(http.StatusUnauthorized, "You do not have permission to moderate movies.", w, r)
 		}
 		s.doError(http.StatusNotFound, fmt.Sprintf("%q not found", r.URL.Path), w, r)
 		return
@@ -519,12 +1149,109 @@ func (s *webServer) handlerAdminMovieEdit(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// TODO: Approve and Deny actions
 	action := r.URL.Query().Get("action")
 	switch action {
+	case "approve", "deny":
+		if !s.backend.CheckPermission(user, models.PERM_APPROVE_MOVIES) {
+			s.doError(http.StatusForbidden, "You do not have the ApproveMovies permission.", w, r)
+			return
+		}
+
+		movie := s.backend.GetMovie(mid)
+		if movie == nil {
+			s.doError(http.StatusNotFound, fmt.Sprintf("Movie with ID %d not found", mid), w, r)
+			return
+		}
+
+		if action == "approve" {
+			if r.Method != http.MethodPost {
+				http.Redirect(w, r, s.sitePath("/admin/movies"), http.StatusSeeOther)
+				return
+			}
+
+			if err = s.backend.AdminApproveMovie(user, movie); err != nil {
+				s.l.Error("Unable to approve movie with ID %d: %v", mid, err)
+				s.doError(
+					http.StatusBadRequest,
+					fmt.Sprintf("Unable to approve movie: %v", err),
+					w, r)
+				return
+			}
+
+			http.Redirect(w, r, s.sitePath("/admin/movies"), http.StatusSeeOther)
+			return
+		}
+
+		data := struct {
+			dataPageBase
+
+			Movie        *models.Movie
+			ErrorMessage string
+			ValReason    string
+		}{
+			dataPageBase: s.newPageBase("Admin - Deny Movie", w, r),
+			Movie:        movie,
+		}
+
+		if r.Method == http.MethodPost {
+			if err = r.ParseForm(); err != nil {
+				s.l.Error("Unable to parse form: %v", err)
+				s.doError(http.StatusInternalServerError, "Unable to parse form", w, r)
+				return
+			}
+
+			data.ValReason = strings.TrimSpace(r.PostFormValue("Reason"))
+			if data.ValReason == "" {
+				data.ErrorMessage = "A reason is required"
+			} else if err = s.backend.AdminDenyMovie(user, movie, data.ValReason); err != nil {
+				data.ErrorMessage = fmt.Sprintf("Could not deny movie: %v", err)
+			} else {
+				http.Redirect(w, r, s.sitePath("/admin/movies"), http.StatusSeeOther)
+				return
+			}
+		}
+
+		if err := s.executeTemplate(w, "adminMovieDeny", data); err != nil {
+			s.l.Error("Error rendering template: %v", err)
+		}
+		return
 	case "remove":
-		// TODO: Confirmation before removing
-		err = s.backend.DeleteMovie(mid)
+		if !s.backend.CheckPermission(user, models.PERM_REMOVE_MOVIES) {
+			s.doError(http.StatusForbidden, "You do not have the RemoveMovies permission.", w, r)
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			movie := s.backend.GetMovie(mid)
+			if movie == nil {
+				s.doError(http.StatusNotFound, fmt.Sprintf("Movie with ID %d not found", mid), w, r)
+				return
+			}
+
+			data := struct {
+				dataPageBase
+
+				Message      string
+				TrueMessage  string
+				FalseMessage string
+				TrueLink     string
+				FalseLink    string
+			}{
+				dataPageBase: s.newPageBase("Admin - Remove Movie", w, r),
+				Message:      fmt.Sprintf("Are you sure you want to remove %q?  Its votes will be deleted.", movie.Name),
+				TrueMessage:  "Remove",
+				FalseMessage: "Cancel",
+				TrueLink:     fmt.Sprintf("/admin/movie/%d?action=remove", mid),
+				FalseLink:    "/admin/movies",
+			}
+
+			if err := s.executeTemplate(w, "adminConfirm", data); err != nil {
+				s.l.Error("Error rendering template: %v", err)
+			}
+			return
+		}
+
+		err = s.backend.DeleteMovie(user, mid)
 		if err != nil {
 			s.l.Error("Unable to remove movie with ID %d: %v", mid, err)
 			s.doError(
@@ -534,7 +1261,60 @@ func (s *webServer) handlerAdminMovieEdit(w http.ResponseWriter, r *http.Request
 			return
 		}
 
-		http.Redirect(w, r, "/admin/movies", http.StatusSeeOther)
+		http.Redirect(w, r, s.sitePath("/admin/movies"), http.StatusSeeOther)
+		return
+	case "refresh":
+		movie := s.backend.GetMovie(mid)
+		if movie == nil {
+			s.doError(http.StatusNotFound, fmt.Sprintf("Movie with ID %d not found", mid), w, r)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			if err = r.ParseForm(); err != nil {
+				s.l.Error("Unable to parse form: %v", err)
+				s.doError(http.StatusInternalServerError, "Unable to parse form", w, r)
+				return
+			}
+
+			// Only the fields that were ticked in the diff are applied.
+			fields := r.PostForm["Field"]
+			if fields == nil {
+				fields = []string{}
+			}
+
+			if err = s.backend.AdminRefreshMetadata(user, movie, fields); err != nil {
+				s.l.Error("Unable to refresh metadata of movie with ID %d: %v", mid, err)
+				s.doError(
+					http.StatusBadRequest,
+					fmt.Sprintf("Unable to refresh metadata: %v", err),
+					w, r)
+				return
+			}
+
+			http.Redirect(w, r, s.sitePath(fmt.Sprintf("/admin/movie/%d", mid)), http.StatusSeeOther)
+			return
+		}
+
+		data := struct {
+			dataPageBase
+
+			Movie        *models.Movie
+			Changes      []*logic.MetadataChange
+			ErrorMessage string
+		}{
+			dataPageBase: s.newPageBase("Admin - Refresh Metadata", w, r),
+			Movie:        movie,
+		}
+
+		data.Changes, err = s.backend.GetMetadataChanges(movie)
+		if err != nil {
+			data.ErrorMessage = err.Error()
+		}
+
+		if err := s.executeTemplate(w, "adminMovieRefresh", data); err != nil {
+			s.l.Error("Error rendering template: %v", err)
+		}
 		return
 	}
 
@@ -552,6 +1332,7 @@ func (s *webServer) handlerAdminMovieEdit(w http.ResponseWriter, r *http.Request
 		movie := s.backend.GetMovie(mid)
 
 		movie.Name = r.PostFormValue("MovieName")
+		movie.OriginalTitle = r.PostFormValue("MovieOriginalTitle")
 		movie.Description = r.PostFormValue("MovieDescr")
 
 		linktext := strings.ReplaceAll(r.FormValue("MovieLinks"), "\r", "")
@@ -583,18 +1364,18 @@ func (s *webServer) handlerAdminMovieEdit(w http.ResponseWriter, r *http.Request
 		}
 		movie.Links = linkstructs
 
-		posterFileName := strings.TrimSpace(r.FormValue("MovieName"))
 		file, header, _ := r.FormFile("PosterFile")
 
 		if header != nil && file != nil {
-			file, err := s.backend.UploadFile(file, header, posterFileName)
+			file, err := s.backend.UploadFile(file, header)
 
 			if err != nil {
 				//data.ErrPoster = true
 				//errText = append(errText, err.Error())
 				s.l.Error("Unable to upload file: %v", err)
 			} else {
-				movie.Poster = filepath.Base(file)
+				movie.Poster = file
+				movie.PosterSource = ""
 			}
 		}
 
@@ -628,9 +1409,9 @@ func (s *webServer) handlerAdminMovieEdit(w http.ResponseWriter, r *http.Request
 
 func (s *webServer) handlerAdminMovies(w http.ResponseWriter, r *http.Request) {
 	user := s.getSessionUser(w, r)
-	if !s.backend.CheckAdminRights(user) {
+	if !s.canModerateMovies(user) {
 		if s.debug {
-			s.doError(http.StatusUnauthorized, "You are not an admin.", w, r)
+			s.doError(http.StatusUnauthorized, "You do not have permission to moderate movies.", w, r)
 		}
 		s.doError(http.StatusNotFound, fmt.Sprintf("%q not found", r.URL.Path), w, r)
 		return
@@ -645,6 +1426,15 @@ func (s *webServer) handlerAdminMovies(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	pending, err := s.backend.GetPendingMovies()
+	if err != nil {
+		s.doError(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Unable to get pending movies: %v", err),
+			w, r)
+		return
+	}
+
 	approval, err := s.backend.GetEntriesRequireApproval()
 	if err != nil {
 		s.doError(
@@ -661,12 +1451,16 @@ func (s *webServer) handlerAdminMovies(w http.ResponseWriter, r *http.Request) {
 		Pending []*models.Movie
 
 		RequireApproval bool
+		CanApprove      bool
+		CanRemove       bool
 	}{
 		dataPageBase: s.newPageBase("Admin - Movies", w, r),
 		Active:       models.SortMoviesByName(active),
-		//Pending:      common.SortMoviesByName(active),
+		Pending:      pending,
 
 		RequireApproval: approval,
+		CanApprove:      s.backend.CheckPermission(user, models.PERM_APPROVE_MOVIES),
+		CanRemove:       s.backend.CheckPermission(user, models.PERM_REMOVE_MOVIES),
 	}
 
 	if err := s.executeTemplate(w, "adminMovies", data); err != nil {
@@ -676,16 +1470,16 @@ func (s *webServer) handlerAdminMovies(w http.ResponseWriter, r *http.Request) {
 
 func (s *webServer) handlerAdminCycles_Post(w http.ResponseWriter, r *http.Request) {
 	user := s.getSessionUser(w, r)
-	if !s.backend.CheckAdminRights(user) {
+	if !s.backend.CheckPermission(user, models.PERM_END_CYCLES) {
 		if s.debug {
-			s.doError(http.StatusUnauthorized, "You are not an admin.", w, r)
+			s.doError(http.StatusUnauthorized, "You do not have the EndCycles permission.", w, r)
 		}
 		s.doError(http.StatusNotFound, fmt.Sprintf("%q not found", r.URL.Path), w, r)
 		return
 	}
 
 	if r.Method != http.MethodPost {
-		http.Redirect(w, r, "/admin/cycles", http.StatusSeeOther)
+		http.Redirect(w, r, s.sitePath("/admin/cycles"), http.StatusSeeOther)
 		return
 	}
 
@@ -707,7 +1501,7 @@ func (s *webServer) handlerAdminCycles_Post(w http.ResponseWriter, r *http.Reque
 		dateStr := strings.TrimSpace(r.PostFormValue("modEndDate"))
 		if dateStr == "" {
 			s.l.Debug("No date given in update")
-			http.Redirect(w, r, "/admin/cycles", http.StatusSeeOther)
+			http.Redirect(w, r, s.sitePath("/admin/cycles"), http.StatusSeeOther)
 			return
 		}
 
@@ -719,14 +1513,15 @@ func (s *webServer) handlerAdminCycles_Post(w http.ResponseWriter, r *http.Reque
 		}
 
 		end, err := time.Parse("2005-01-02", dateStr)
+		plannedEnd = cycle.PlannedEnd
 		if err != nil {
 			s.l.Error(err.Error())
 		} else {
 			t := (&end).Round(time.Second)
-			cycle.PlannedEnd = &t
+			plannedEnd = &t
 		}
 
-		err = s.backend.UpdateCycle(cycle)
+		err = s.backend.AdminSetCyclePlannedEnd(user, cycle, plannedEnd)
 		if err != nil {
 			s.l.Error(err.Error())
 			s.doError(http.StatusInternalServerError, fmt.Sprintf("Unable to get current cycle: %v", err), w, r)
@@ -742,29 +1537,23 @@ func (s *webServer) handlerAdminCycles_Post(w http.ResponseWriter, r *http.Reque
 			plannedEnd = &t
 		}
 
-		_, err = s.backend.AddCycle(plannedEnd)
+		// This also re-enables voting after successfully starting the new cycle
+		_, err = s.backend.AdminStartCycle(user, plannedEnd)
 		if err != nil {
 			s.l.Error("Unable to add cycle: %v", err)
 			s.doError(http.StatusInternalServerError, fmt.Sprintf("Unable to add cycle: %v", err), w, r)
 			return
 		}
-
-		// Re-enable voting after successfully starting a new cycle
-		err = s.backend.EnableVoting()
-		if err != nil {
-			s.doError(http.StatusInternalServerError, fmt.Sprintf("Unable to enable voting: %v", err), w, r)
-			return
-		}
 	}
 
-	http.Redirect(w, r, "/admin/cycles", http.StatusSeeOther)
+	http.Redirect(w, r, s.sitePath("/admin/cycles"), http.StatusSeeOther)
 }
 
 func (s *webServer) handlerAdminCycles(w http.ResponseWriter, r *http.Request) {
 	user := s.getSessionUser(w, r)
-	if !s.backend.CheckAdminRights(user) {
+	if !s.backend.CheckPermission(user, models.PERM_END_CYCLES) {
 		if s.debug {
-			s.doError(http.StatusUnauthorized, "You are not an admin.", w, r)
+			s.doError(http.StatusUnauthorized, "You do not have the EndCycles permission.", w, r)
 		}
 		s.doError(http.StatusNotFound, fmt.Sprintf("%q not found", r.URL.Path), w, r)
 		return
@@ -781,12 +1570,8 @@ func (s *webServer) handlerAdminCycles(w http.ResponseWriter, r *http.Request) {
 		s.l.Debug("POSTed values: %s", r.PostForm)
 	}
 
-	// URL parameters override POST
-	if val := r.URL.Query().Get("action"); val != "" {
-		action = val
-	}
-
-	s.l.Debug("action: %q", r.URL.Query().Get("action"))
+	// Every action changes something, so they are only taken from POST.
+	s.l.Debug("action: %q", action)
 	switch action {
 	case "end":
 		//adminEndCycle(w, r)
@@ -802,7 +1587,7 @@ func (s *webServer) handlerAdminCycles(w http.ResponseWriter, r *http.Request) {
 		}
 
 		r.Method = "GET"
-		http.Redirect(w, r, "/admin/cycles", http.StatusSeeOther)
+		http.Redirect(w, r, s.sitePath("/admin/cycles"), http.StatusSeeOther)
 		return
 
 	case "select":
@@ -966,18 +1751,7 @@ func (s *webServer) cycleStage2(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	for _, movie := range movies {
-		s.l.Debug("> setting watched on %s", movie.Name)
-		movie.CycleWatched = cycle
-		err = s.backend.UpdateMovie(movie)
-		if err != nil {
-			s.l.Error("Unable to update movie with ID %d: %v", movie.Id, err)
-			continue
-		}
-	}
-
-	cycle.Ended = &watched
-	if err = s.backend.UpdateCycle(cycle); err != nil {
+	if err = s.backend.AdminEndCycle(s.getSessionUser(w, r), cycle, movies, watched); err != nil {
 		s.doError(http.StatusInternalServerError, fmt.Sprintf("Unable to update cycle: %v", err), w, r)
 		return
 	}
@@ -990,5 +1764,10 @@ func (s *webServer) cycleStage2(w http.ResponseWriter, r *http.Request) {
 	//}
 
 	// Redirect to admin page
-	http.Redirect(w, r, "/admin/cycles", http.StatusSeeOther)
+	http.Redirect(w, r, s.sitePath("/admin/cycles"), http.StatusSeeOther)
+}
+
+func (s *webServer) canModerateMovies(user *models.User) bool {
+	return s.backend.CheckPermission(user, models.PERM_APPROVE_MOVIES) ||
+		s.backend.CheckPermission(user, models.PERM_REMOVE_MOVIES)
 }
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+func TestCsvCell(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"Movie":                    "Movie",
+		"=HYPERLINK(\"http://x\")": "'=HYPERLINK(\"http://x\")",
+		"+1":                       "'+1",
+		"-1":                       "'-1",
+		"@SUM(A1)":                 "'@SUM(A1)",
+		"\t=1":                     "'\t=1",
+		"\r=1":                     "'\r=1",
+		"a=b":                      "a=b",
+		"'quoted":                  "'quoted",
+	}
+
+	for value, expected := range tests {
+		if cell := csvCell(value); cell != expected {
+			t.Errorf("csvCell(%q) returned %q, expected %q", value, cell, expected)
+		}
+	}
+}
+
+func TestWriteAuditCsv(t *testing.T) {
+	entries := []*models.AuditEntry{
+		{
+			Id:         2,
+			Timestamp:  time.Date(2020, 10, 19, 8, 0, 0, 0, time.UTC),
+			ActorId:    1,
+			ActorName:  "@admin",
+			Action:     models.AUDIT_MOVIE_REMOVE,
+			TargetType: "Movie",
+			TargetId:   5,
+			TargetName: "=cmd|' /C calc'!A0",
+			Before:     "Active, with a \"quote\"",
+			After:      "-Removed",
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeAuditCsv(buf, entries); err != nil {
+		t.Fatalf("writeAuditCsv() returned an error: %v", err)
+	}
+
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Unable to read the CSV: %v", err)
+	}
+
+	expected := [][]string{
+		{"Id", "Timestamp", "ActorId", "Actor", "Action", "TargetType", "TargetId", "Target", "Before", "After"},
+		{"2", "2020-10-19T08:00:00Z", "1", "'@admin", "MovieRemove", "Movie", "5", "'=cmd|' /C calc'!A0", "Active, with a \"quote\"", "'-Removed"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("writeAuditCsv() wrote %q, expected %q", rows, expected)
+	}
+}
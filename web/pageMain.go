@@ -3,6 +3,8 @@ package web
 import (
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/zorchenhimer/MoviePolls/models"
 )
@@ -13,8 +15,6 @@ func (s *webServer) handlerPageMain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	movieList := []*models.Movie{} //nolint:ineffassign,staticcheck
-
 	data := struct {
 		dataPageBase
 		Movies         []*models.Movie
@@ -22,31 +22,39 @@ func (s *webServer) handlerPageMain(w http.ResponseWriter, r *http.Request) {
 		AvailableVotes int
 		LastCycle      *models.Cycle
 		Cycle          *models.Cycle
+
+		// Tag filter
+		Tags         []*models.Tag
+		SelectedTags map[string]bool
+		MatchAny     bool
 	}{
 		dataPageBase: s.newPageBase("Current Cycle", w, r),
 	}
 
-	if r.Body != http.NoBody {
-		err := r.ParseForm()
-		if err != nil {
-			s.l.Error(err.Error())
-		}
-		searchVal := r.FormValue("search")
+	movieList, err := s.backend.GetActiveMovies()
+	if err != nil {
+		s.l.Error(err.Error())
+		s.doError(
+			http.StatusBadRequest,
+			"Cannot get active movies. Please contact the server admin.",
+			w, r)
+		return
+	}
 
-		movieList, err = s.backend.SearchMovieTitles(searchVal)
-		if err != nil {
-			s.l.Error(err.Error())
+	// Offer the tags of all listed movies, then filter by the selected ones.
+	data.Tags = movieTags(movieList)
+	data.SelectedTags = map[string]bool{}
+	data.MatchAny = r.URL.Query().Get("match") == "any"
+
+	if selected := r.URL.Query()["tag"]; len(selected) > 0 {
+		for _, tag := range selected {
+			data.SelectedTags[tag] = true
 		}
-	} else {
-		var err error = nil //nolint:ineffassign
-		movieList, err = s.backend.GetActiveMovies()
-		if err != nil {
-			s.l.Error(err.Error())
-			s.doError(
-				http.StatusBadRequest,
-				"Cannot get active movies. Please contact the server admin.",
-				w, r)
-			return
+
+		if data.MatchAny {
+			movieList = models.FilterMoviesByAnyTag(movieList, selected)
+		} else {
+			movieList, _ = models.FilterMoviesByTags(movieList, selected)
 		}
 	}
 
@@ -84,3 +92,21 @@ func (s *webServer) handlerPageMain(w http.ResponseWriter, r *http.Request) {
 		s.l.Error("Error rendering template: %v", err)
 	}
 }
+
+// movieTags returns the tags of the given movies without duplicates, sorted
+// by name.
+func movieTags(movies []*models.Movie) []*models.Tag {
+	seen := map[int]bool{}
+	tags := []*models.Tag{}
+	for _, movie := range movies {
+		for _, tag := range movie.Tags {
+			if !seen[tag.Id] {
+				seen[tag.Id] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	sort.Slice(tags, func(i, k int) bool { return strings.ToLower(tags[i].Name) < strings.ToLower(tags[k].Name) })
+	return tags
+}
@@ -24,6 +24,11 @@ func (s *webServer) handlerPageMovie(w http.ResponseWriter, r *http.Request) {
 	}
 
 	movie := s.backend.GetMovie(movieId)
+	if movie != nil && !movie.Visible() && !s.canSeeUnapproved(s.getSessionUser(w, r), movie) {
+		// Pretend pending and denied movies don't exist for everybody else.
+		movie = nil
+	}
+
 	if movie == nil {
 		dataError := dataMovieError{
 			dataPageBase: s.newPageBase("Error", w, r),
@@ -75,3 +80,16 @@ func (s *webServer) handlerPageMovie(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("%v", err), http.StatusInternalServerError)
 	}
 }
+
+// canSeeUnapproved returns true if the user added the movie or can approve it.
+func (s *webServer) canSeeUnapproved(user *models.User, movie *models.Movie) bool {
+	if user == nil {
+		return false
+	}
+
+	if movie.AddedBy != nil && movie.AddedBy.Id == user.Id {
+		return true
+	}
+
+	return s.backend.CheckPermission(user, models.PERM_APPROVE_MOVIES)
+}
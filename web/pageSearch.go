@@ -0,0 +1,61 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/zorchenhimer/MoviePolls/logic"
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+// handlerPageSearch searches the title, description, remarks and tags of
+// every movie, eg /search?q=matrix&status=watched.  Without a status, active
+// and watched movies are searched.
+func (s *webServer) handlerPageSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	statuses := r.URL.Query()["status"]
+	if len(statuses) == 0 {
+		statuses = []string{"active", "watched"}
+	}
+
+	data := struct {
+		dataPageBase
+		Query         string
+		Status        map[string]bool
+		CanSeeRemoved bool
+		Results       []*logic.MovieSearchResult
+	}{
+		dataPageBase: s.newPageBase("Search", w, r),
+		Query:        query,
+		Status:       map[string]bool{},
+		Results:      []*logic.MovieSearchResult{},
+	}
+
+	for _, status := range statuses {
+		data.Status[status] = true
+	}
+
+	// Removed movies are only shown to the people that can remove them.
+	data.CanSeeRemoved = s.backend.CheckPermission(data.User, models.PERM_REMOVE_MOVIES)
+
+	if query != "" {
+		filter := logic.SearchFilter{
+			Active:  data.Status["active"],
+			Watched: data.Status["watched"],
+			Removed: data.Status["removed"] && data.CanSeeRemoved,
+		}
+
+		results, err := s.backend.SearchMovies(query, filter)
+		if err != nil {
+			s.l.Error("Unable to search for %q: %v", query, err)
+			s.doError(http.StatusInternalServerError, "Something went wrong :C", w, r)
+			return
+		}
+		data.Results = results
+	}
+
+	if err := s.executeTemplate(w, "search", data); err != nil {
+		s.l.Error("Error rendering template: %v", err)
+	}
+}
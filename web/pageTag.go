@@ -0,0 +1,50 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zorchenhimer/MoviePolls/models"
+)
+
+// handlerPageTag lists every movie with the tag in the URL, eg /tag/Drama.
+func (s *webServer) handlerPageTag(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/tag/")
+
+	tag, movies, err := s.backend.GetTagMovies(name)
+	if err != nil {
+		s.l.Error("Unable to get movies for tag %q: %v", name, err)
+		s.doError(http.StatusInternalServerError, "Something went wrong :C", w, r)
+		return
+	}
+
+	if tag == nil {
+		s.doError(http.StatusNotFound, fmt.Sprintf("Tag %q not found", name), w, r)
+		return
+	}
+
+	data := struct {
+		dataPageBase
+		Tag     *models.Tag
+		Active  []*models.Movie
+		Watched []*models.Movie
+	}{
+		dataPageBase: s.newPageBase("Tag - "+tag.Name, w, r),
+		Tag:          tag,
+		Active:       []*models.Movie{},
+		Watched:      []*models.Movie{},
+	}
+
+	for _, movie := range movies {
+		if movie.CycleWatched != nil {
+			data.Watched = append(data.Watched, movie)
+		} else if !movie.Removed {
+			data.Active = append(data.Active, movie)
+		}
+	}
+
+	if err := s.executeTemplate(w, "tag", data); err != nil {
+		s.l.Error("Error rendering template: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package web
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,9 +13,9 @@ import (
 // /user/
 
 func (s *webServer) handlerPageUser(w http.ResponseWriter, r *http.Request) {
-	user := s.getSessionUser(w, r)
+	currentSession, user := s.getLoginSession(w, r)
 	if user == nil {
-		http.Redirect(w, r, "/login", http.StatusFound)
+		http.Redirect(w, r, s.sitePath("/login"), http.StatusFound)
 		return
 	}
 
@@ -39,6 +40,16 @@ func (s *webServer) handlerPageUser(w http.ResponseWriter, r *http.Request) {
 		s.l.Error("Unable to get UnlimitedVotes: %v", err)
 	}
 
+	notifications, err := s.backend.GetUserNotifications(user)
+	if err != nil {
+		s.l.Error("Unable to get notifications for user %d: %v", user.Id, err)
+	}
+
+	sessions, err := s.backend.GetUserSessions(user)
+	if err != nil {
+		s.l.Error("Unable to get sessions for user %d: %v", user.Id, err)
+	}
+
 	data := struct {
 		dataPageBase
 
@@ -65,6 +76,11 @@ func (s *webServer) handlerPageUser(w http.ResponseWriter, r *http.Request) {
 		AddedMovies    []*models.Movie
 		SuccessMessage string
 
+		NotificationList []*models.Notification
+
+		Sessions       []*models.Session
+		CurrentSession *models.Session
+
 		PassError   []string
 		NotifyError []string
 		EmailError  []string
@@ -84,6 +100,11 @@ func (s *webServer) handlerPageUser(w http.ResponseWriter, r *http.Request) {
 		ActiveVotes:  activeVotes,
 		WatchedVotes: watchedVotes,
 		AddedMovies:  addedMovies,
+
+		NotificationList: notifications,
+
+		Sessions:       sessions,
+		CurrentSession: currentSession,
 	}
 
 	if s.callbackError.message != "" {
@@ -130,6 +151,10 @@ func (s *webServer) handlerPageUser(w http.ResponseWriter, r *http.Request) {
 	_, err = user.GetAuthMethod(models.AUTH_PATREON)
 	data.HasPatreon = err == nil
 
+	// Set when the user is logged in again below, which replaces the session
+	// and its CSRF token.
+	loggedIn := false
+
 	if r.Method == http.MethodPost {
 		err := r.ParseForm()
 		if err != nil {
@@ -184,6 +209,7 @@ func (s *webServer) handlerPageUser(w http.ResponseWriter, r *http.Request) {
 						s.doError(http.StatusInternalServerError, "Unable to update password", w, r)
 						return
 					}
+					loggedIn = true
 				}
 			}
 		} else if formVal == "Notifications" {
@@ -222,6 +248,7 @@ func (s *webServer) handlerPageUser(w http.ResponseWriter, r *http.Request) {
 					if err != nil {
 						s.l.Error("Unable to add AuthMethod %s to user %s", localAuth.Type, user.Name)
 						s.doError(http.StatusInternalServerError, "Unable to link password to user", w, r)
+						return
 					}
 
 					err = s.backend.UpdateUser(user)
@@ -229,22 +256,79 @@ func (s *webServer) handlerPageUser(w http.ResponseWriter, r *http.Request) {
 					if err != nil {
 						s.l.Error("Unable to update user %s", user.Name)
 						s.doError(http.StatusInternalServerError, "Unable to update user", w, r)
+						return
 					}
 
 					err = s.login(user, models.AUTH_LOCAL, w, r)
 					if err != nil {
 						s.l.Error("Unable to login to session:", err)
 						s.doError(http.StatusInternalServerError, "Unable to update password", w, r)
+						return
 					}
-
-					http.Redirect(w, r, "/user", http.StatusFound)
+					loggedIn = true
 				}
 			}
 		}
 	}
+
+	// The page has to use the new CSRF token, the old one isn't valid
+	// anymore.
+	if loggedIn {
+		data.dataPageBase = s.newPageBase("Account", w, r)
+		data.User = user
+		data.HasLocal = true
+
+		data.CurrentSession, _ = s.getLoginSession(w, r)
+		data.Sessions, err = s.backend.GetUserSessions(user)
+		if err != nil {
+			s.l.Error("Unable to get sessions for user %d: %v", user.Id, err)
+		}
+	}
+
 	if err := s.executeTemplate(w, "account", data); err != nil {
 		s.l.Error("Error rendering template: %v", err)
 	}
+
+	// The notifications have been seen now.
+	if data.Notifications > 0 {
+		if err := s.backend.MarkNotificationsRead(user); err != nil {
+			s.l.Error("Unable to mark notifications read for user %d: %v", user.Id, err)
+		}
+	}
+}
+
+// /user/sessions
+func (s *webServer) handlerUserSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, s.sitePath("/user"), http.StatusFound)
+		return
+	}
+
+	current, user := s.getLoginSession(w, r)
+	if user == nil {
+		http.Redirect(w, r, s.sitePath("/user/login"), http.StatusFound)
+		return
+	}
+
+	var err error
+	if r.PostFormValue("Session") == "others" {
+		err = s.backend.RevokeOtherSessions(user, current)
+	} else {
+		id, convErr := strconv.Atoi(r.PostFormValue("Session"))
+		if convErr != nil {
+			s.doError(http.StatusBadRequest, "Invalid session", w, r)
+			return
+		}
+		err = s.backend.RevokeSession(user, id)
+	}
+
+	if err != nil {
+		s.l.Error("Unable to revoke sessions of user %d: %v", user.Id, err)
+		s.doError(http.StatusBadRequest, "Unable to log out the session", w, r)
+		return
+	}
+
+	http.Redirect(w, r, s.sitePath("/user"), http.StatusSeeOther)
 }
 
 // /user/login
@@ -257,7 +341,7 @@ func (s *webServer) handlerUserLogin(w http.ResponseWriter, r *http.Request) {
 
 	user := s.getSessionUser(w, r)
 	if user != nil {
-		http.Redirect(w, r, "/user", http.StatusFound)
+		http.Redirect(w, r, s.sitePath("/user"), http.StatusFound)
 		return
 	}
 
@@ -298,6 +382,14 @@ func (s *webServer) handlerUserLogin(w http.ResponseWriter, r *http.Request) {
 		user, err = s.backend.UserLocalLogin(un, s.backend.HashPassword(pw))
 		if err != nil {
 			data.ErrorMessage = err.Error()
+		} else if ban, err := s.backend.GetUserBan(user); err != nil || ban != nil {
+			if err != nil {
+				s.l.Error("Unable to check bans for user %d: %v", user.Id, err)
+				data.ErrorMessage = "Something went wrong :C"
+			} else {
+				data.ErrorMessage = ban.Message()
+			}
+			user = nil
 		} else {
 			doRedirect = true
 		}
@@ -317,7 +409,7 @@ func (s *webServer) handlerUserLogin(w http.ResponseWriter, r *http.Request) {
 
 	// Redirect to base page on successful login
 	if doRedirect {
-		http.Redirect(w, r, "/", http.StatusFound)
+		http.Redirect(w, r, s.sitePath("/"), http.StatusFound)
 		return
 	}
 
@@ -331,12 +423,18 @@ func (s *webServer) handlerUserLogin(w http.ResponseWriter, r *http.Request) {
 // user/logout
 
 func (s *webServer) handlerUserLogout(w http.ResponseWriter, r *http.Request) {
+	// Only the logout button logs out, not a link on some other site.
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, s.sitePath("/"), http.StatusFound)
+		return
+	}
+
 	err := s.logout(w, r)
 	if err != nil {
 		s.l.Error("Error logging out: %v", err)
 	}
 
-	http.Redirect(w, r, "/", http.StatusFound)
+	http.Redirect(w, r, s.sitePath("/"), http.StatusFound)
 }
 
 // /user/new
@@ -344,7 +442,7 @@ func (s *webServer) handlerUserLogout(w http.ResponseWriter, r *http.Request) {
 func (s *webServer) handlerUserNew(w http.ResponseWriter, r *http.Request) {
 	user := s.getSessionUser(w, r)
 	if user != nil {
-		http.Redirect(w, r, "/account", http.StatusFound)
+		http.Redirect(w, r, s.sitePath("/account"), http.StatusFound)
 		return
 	}
 
@@ -507,6 +605,14 @@ func (s *webServer) handlerUserNew(w http.ResponseWriter, r *http.Request) {
 			data.ErrorMessage = append(data.ErrorMessage, "Email required for notifications")
 		}
 
+		if ban, err := s.backend.GetEmailBan(email); err != nil {
+			s.l.Error("Unable to check bans for new user: %v", err)
+			data.ErrorMessage = append(data.ErrorMessage, "Could not create new User, message the server admin")
+		} else if ban != nil {
+			data.ErrEmail = true
+			data.ErrorMessage = append(data.ErrorMessage, ban.Message())
+		}
+
 		auth := &models.AuthMethod{
 			Type:     models.AUTH_LOCAL,
 			Password: s.backend.HashPassword(pw1),
@@ -547,7 +653,7 @@ func (s *webServer) handlerUserNew(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if doRedirect {
-		http.Redirect(w, r, "/", http.StatusFound)
+		http.Redirect(w, r, s.sitePath("/"), http.StatusFound)
 		return
 	}
 
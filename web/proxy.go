@@ -0,0 +1,153 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseBasePath cleans up the path the site is hosted under, eg "movies/"
+// becomes "/movies".  The site root is an empty string.
+func parseBasePath(p string) (string, error) {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return "", nil
+	}
+
+	if strings.ContainsAny(p, "?#\\ ") || strings.Contains(p, "//") {
+		return "", fmt.Errorf("Invalid base path %q", p)
+	}
+	return "/" + p, nil
+}
+
+// sitePath returns the path p, which starts with a slash, below the base
+// path.  Every local link and redirect has to go through this.
+func (s *webServer) sitePath(p string) string {
+	return s.basePath + p
+}
+
+// handle registers handler for the path p below the base path.  The mux
+// sees the whole path, so its redirects, eg from "/admin" to "/admin/", keep
+// the base path.  The handler gets the path without it.  Requests outside of
+// the base path don't match anything and get a 404.
+func (s *webServer) handle(mux *http.ServeMux, p string, handler http.Handler) {
+	if s.basePath != "" {
+		handler = http.StripPrefix(s.basePath, handler)
+	}
+	mux.Handle(s.sitePath(p), handler)
+}
+
+// parseTrustedProxies parses a list of IP addresses and CIDR ranges.
+func parseTrustedProxies(list []string) ([]*net.IPNet, error) {
+	nets := []*net.IPNet{}
+	for _, item := range list {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid trusted proxy %q", item)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipnet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trusted proxy %q: %v", item, err)
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets, nil
+}
+
+func (s *webServer) trustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipnet := range s.trustedProxies {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedHeaders uses the X-Forwarded-For, -Proto and -Host headers for
+// the client address, scheme and host.  They are only believed if the
+// request comes from a trusted proxy, anyone else could send them too.
+func (s *webServer) forwardedHeaders(next http.Handler) http.Handler {
+	if len(s.trustedProxies) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peer, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil || !s.trustedProxy(peer) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r2 := r.WithContext(r.Context())
+
+		// Every proxy appends the address it got the request from.  The
+		// client is the last one that isn't a trusted proxy, everything
+		// before it could be made up.
+		if fwd := r.Header["X-Forwarded-For"]; len(fwd) > 0 {
+			addrs := strings.Split(strings.Join(fwd, ","), ",")
+			for i := len(addrs) - 1; i >= 0; i-- {
+				addr := strings.TrimSpace(addrs[i])
+				if net.ParseIP(addr) == nil {
+					break
+				}
+
+				r2.RemoteAddr = addr
+				if !s.trustedProxy(addr) {
+					break
+				}
+			}
+		}
+
+		if host := firstValue(r.Header.Get("X-Forwarded-Host")); host != "" {
+			r2.Host = host
+		}
+
+		if strings.EqualFold(firstValue(r.Header.Get("X-Forwarded-Proto")), "https") {
+			r2 = r2.WithContext(context.WithValue(r2.Context(), ctxForwardedHTTPS, true))
+		}
+
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// firstValue returns the first entry of a comma separated header, which is
+// the one set by the proxy closest to the client.
+func firstValue(header string) string {
+	return strings.TrimSpace(strings.Split(header, ",")[0])
+}
+
+// clientAddress returns the IP address of the client, without the port.
+func clientAddress(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// isHTTPS returns true if the client used HTTPS, either to this server or
+// to a trusted proxy in front of it.
+func isHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	https, _ := r.Context().Value(ctxForwardedHTTPS).(bool)
+	return https
+}
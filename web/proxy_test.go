@@ -0,0 +1,49 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleBasePath(t *testing.T) {
+	tests := []struct {
+		basePath string
+		path     string
+		status   int
+		result   string // the path the handler got, or the redirect
+	}{
+		{"", "/admin/", http.StatusOK, "/admin/"},
+		{"", "/admin", http.StatusMovedPermanently, "/admin/"},
+		{"/movies", "/movies/admin/users", http.StatusOK, "/admin/users"},
+		{"/movies", "/movies/", http.StatusOK, "/"},
+		{"/movies", "/movies/movie/1", http.StatusOK, "/movie/1"},
+		{"/movies", "/movies", http.StatusMovedPermanently, "/movies/"},
+		{"/movies", "/movies/admin", http.StatusMovedPermanently, "/movies/admin/"},
+		{"/movies", "/movies//admin/", http.StatusMovedPermanently, "/movies/admin/"},
+		{"/movies", "/admin/", http.StatusNotFound, ""},
+		{"/movies", "/moviesadmin/", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		s := &webServer{basePath: tt.basePath}
+		mux := http.NewServeMux()
+		for _, p := range []string{"/", "/admin/", "/movie/"} {
+			s.handle(mux, p, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.URL.Path))
+			}))
+		}
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+
+		result := rec.Header().Get("Location")
+		if rec.Code == http.StatusOK {
+			result = rec.Body.String()
+		}
+
+		if rec.Code != tt.status || (tt.result != "" && result != tt.result) {
+			t.Errorf("Base path %q, GET %s: got %d %q, expected %d %q", tt.basePath, tt.path, rec.Code, result, tt.status, tt.result)
+		}
+	}
+}
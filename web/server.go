@@ -4,26 +4,44 @@ import (
 	"context"
 	"fmt"
 	"html/template"
-
+	"net"
 	"net/http"
-	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/sessions"
 
 	"github.com/zorchenhimer/MoviePolls/logger"
 	"github.com/zorchenhimer/MoviePolls/logic"
+	"github.com/zorchenhimer/MoviePolls/metrics"
 )
 
 const SessionName string = "moviepoll-session"
 
 type Server interface {
 	ListenAndServe() error
-	Shutdown() error
+	Shutdown(ctx context.Context) error
 }
 
+var _ Server = &webServer{}
+
 type Options struct {
-	Listen string // eg, "127.0.0.1:8080" or ":8080" (defaults to 0.0.0.0:8080)
-	Debug  bool   // debug logging to console
+	Listen       string // eg, "127.0.0.1:8080" or ":8080" (defaults to 0.0.0.0:8080)
+	Debug        bool   // debug logging to console
+	MetricsToken string // bearer token required for /metrics, if not empty
+
+	BasePath       string   // eg, "/movies" to host the site below https://example.org/movies/
+	TrustedProxies []string // IPs and CIDR ranges of proxies whose X-Forwarded-* headers are used
+
+	TLSCert      string   // certificate file, turns on HTTPS together with TLSKey
+	TLSKey       string   // key file of TLSCert
+	ACMEDomains  []string // domains to get certificates for with ACME (Let's Encrypt), turns on HTTPS
+	ACMECache    string   // directory the ACME certificates are kept in (defaults to "certs")
+	ACMEEmail    string   // optional contact address for the ACME account
+	HTTPRedirect string   // eg, ":80", a plain HTTP listener redirecting to HTTPS (and answering ACME challenges)
+
+	ShutdownDrain time.Duration // how long /readyz fails before the listeners close on shutdown
 }
 
 type callbackError struct {
@@ -34,14 +52,32 @@ type callbackError struct {
 type webServer struct {
 	templates map[string]*template.Template
 	s         *http.Server
+	redirect  *http.Server // HTTP to HTTPS redirect, nil if not used
+	tlsCert   string
+	tlsKey    string
+	tls       bool
 	debug     bool // turns on debug things (eg, reloading templates on each page request)
 	backend   logic.Logic
 
-	cookies      *sessions.CookieStore
+	basePath       string // without a trailing slash, empty for the site root
+	trustedProxies []*net.IPNet
+
 	passwordSalt string
 
+	// The cookie store is rebuilt when the session keys change, see
+	// cookieStore().
+	cookieLock       sync.Mutex
+	cookies          *sessions.CookieStore
+	cookieGeneration int
+	cookieKeyId      string // id of the current keys
+	cookieOptions    sessions.Options
+
 	callbackError callbackError
+	shuttingDown  int32         // set once Shutdown is called, read atomically
+	shutdownDrain time.Duration // see Options.ShutdownDrain
 	l             *logger.Logger
+	oauthLog      *logger.Logger
+	accessLogger  *logger.Logger
 }
 
 func New(options Options, backend logic.Logic, log *logger.Logger) (*webServer, error) {
@@ -49,27 +85,51 @@ func New(options Options, backend logic.Logic, log *logger.Logger) (*webServer,
 		options.Listen = ":8090"
 	}
 
-	err := os.MkdirAll("posters", 0755)
-	if err != nil {
-		return nil, fmt.Errorf("Unable to create posters directory: %v", err)
-	}
-
 	hs := &http.Server{
 		Addr: options.Listen,
 	}
 
-	authKey, encryptKey, passwordSalt, err := backend.GetKeys()
+	_, _, passwordSalt, err := backend.GetKeys()
 	if err != nil {
 		return nil, fmt.Errorf("Unable to get keys: %v", err)
 	}
 
+	basePath, err := parseBasePath(options.BasePath)
+	if err != nil {
+		return nil, err
+	}
+
+	trustedProxies, err := parseTrustedProxies(options.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
 	server := &webServer{
 		debug:        options.Debug,
 		passwordSalt: passwordSalt,
 
-		cookies: sessions.NewCookieStore([]byte(authKey), []byte(encryptKey)),
+		basePath:       basePath,
+		trustedProxies: trustedProxies,
+		shutdownDrain:  options.ShutdownDrain,
+
 		l:       log,
 		backend: backend,
+
+		oauthLog:     log.Sub("oauth"),
+		accessLogger: log.Sub("access"),
+	}
+
+	// Forms posted from other sites don't get the session cookie.  The CSRF
+	// token still has to be checked for older browsers.
+	server.cookieOptions = sessions.Options{
+		Path:     server.sitePath("/"),
+		MaxAge:   int(logic.SessionMaxAge / time.Second),
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	err = server.setupTLS(options, hs)
+	if err != nil {
+		return nil, err
 	}
 
 	err = server.initOauth()
@@ -84,12 +144,17 @@ func New(options Options, backend logic.Logic, log *logger.Logger) (*webServer,
 		"/static/":     server.handlerStatic,
 		"/posters/":    server.handlerPosters,
 		"/favicon.ico": server.handlerFavicon,
+		"/metrics":     metrics.Handler(options.MetricsToken).ServeHTTP,
+		"/healthz":     server.handlerHealthz,
+		"/readyz":      server.handlerReadyz,
 
 		// Main Page handlers
 		"/":        server.handlerPageMain,
 		"/add":     server.handlerPageAddMovie,
 		"/movie/":  server.handlerPageMovie,
 		"/history": server.handlerPageHistory,
+		"/search":  server.handlerPageSearch,
+		"/tag/":    server.handlerPageTag,
 		"/user":    server.handlerPageUser,
 
 		// User management
@@ -97,6 +162,7 @@ func New(options Options, backend logic.Logic, log *logger.Logger) (*webServer,
 		"/user/logout":       server.handlerUserLogout,
 		"/user/new":          server.handlerUserNew,
 		"/user/remove/local": server.handlerLocalAuthRemove,
+		"/user/sessions":     server.handlerUserSessions,
 
 		// Functional endpoints (used for page functionality) - not having a page itself
 		"/vote/": server.handlerVote,
@@ -118,17 +184,31 @@ func New(options Options, backend logic.Logic, log *logger.Logger) (*webServer,
 		"/admin/cyclepost": server.handlerAdminCycles_Post,
 		"/admin/user/":     server.handlerAdminUserEdit,
 		"/admin/users":     server.handlerAdminUsers,
+		"/admin/bans":      server.handlerAdminBans,
+		"/admin/roles":     server.handlerAdminRoles,
+		"/admin/audit":     server.handlerAdminAudit,
+		"/admin/cleanup":   server.handlerAdminCleanup,
+		"/admin/logging":   server.handlerAdminLogging,
+		"/admin/tags":      server.handlerAdminTags,
 		"/admin/movies":    server.handlerAdminMovies,
 		"/admin/movie/":    server.handlerAdminMovieEdit,
 
 		// "/admin/nextcycle", server.handlerAdminNextCycle)
 	}
 
+	// The CSRF check is inside instrument, so rejected requests are counted
+	// for the handler they were sent to.
 	for path, handler := range handlers {
-		mux.HandleFunc(path, handler)
+		server.handle(mux, path, instrument(path, server.csrfProtect(http.HandlerFunc(handler))))
 	}
 
-	hs.Handler = mux
+	hs.Handler = chain(mux,
+		server.forwardedHeaders,
+		withRequestId,
+		securityHeaders,
+		server.accessLog,
+		server.recoverPanic,
+	)
 	server.s = hs
 
 	err = server.registerTemplates()
@@ -140,11 +220,42 @@ func New(options Options, backend logic.Logic, log *logger.Logger) (*webServer,
 }
 
 func (s *webServer) ListenAndServe() error {
+	if s.redirect != nil {
+		go func() {
+			s.l.Info("Redirecting HTTP on %s to HTTPS", s.redirect.Addr)
+			if err := s.redirect.ListenAndServe(); err != http.ErrServerClosed {
+				s.l.Error("Error serving the HTTP redirect: %v", err)
+			}
+		}()
+	}
+
+	if s.tls {
+		// The certificate comes from hs.TLSConfig with ACME, and the files
+		// are empty then.
+		return s.s.ListenAndServeTLS(s.tlsCert, s.tlsKey)
+	}
 	return s.s.ListenAndServe()
 }
 
+// Shutdown stops accepting connections and waits for the running requests
+// until ctx is done.  The backend has to be closed by the caller afterwards.
+//
+// /readyz fails for the drain time first, while requests are still served,
+// so load balancers can take the server out before the listeners close.
 func (s *webServer) Shutdown(ctx context.Context) error {
-	//s.backend.Close()
+	atomic.StoreInt32(&s.shuttingDown, 1)
+
+	if s.shutdownDrain > 0 {
+		s.l.Info("Waiting %s for load balancers to notice the shutdown", s.shutdownDrain)
+		select {
+		case <-time.After(s.shutdownDrain):
+		case <-ctx.Done():
+		}
+	}
+
+	if s.redirect != nil {
+		s.redirect.Shutdown(ctx)
+	}
 	return s.s.Shutdown(ctx)
 }
 
@@ -156,7 +267,7 @@ func (s *webServer) doError(code int, message string, w http.ResponseWriter, r *
 		Code:         code,
 	}
 
-	w.WriteHeader(http.StatusNotFound)
+	w.WriteHeader(code)
 	if err := s.executeTemplate(w, "error", dataErr); err != nil {
 		s.l.Error("Error rendering template: %v", err)
 	}
@@ -1,172 +1,195 @@
 package web
 
 import (
-	"crypto/sha256"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/sessions"
+	"github.com/zorchenhimer/MoviePolls/logic"
 	"github.com/zorchenhimer/MoviePolls/models"
 )
 
+// cookieStore returns the store for the session cookies.  It is rebuilt
+// whenever the session keys are rotated.  The old keys are still used to
+// read cookies that haven't been saved with the new ones yet.
+func (s *webServer) cookieStore() *sessions.CookieStore {
+	s.cookieLock.Lock()
+	defer s.cookieLock.Unlock()
+
+	if s.cookies != nil && s.cookieGeneration == s.backend.SessionKeyGeneration() {
+		return s.cookies
+	}
+
+	keys, generation := s.backend.GetSessionKeys()
+	pairs := [][]byte{}
+	for _, key := range keys {
+		pairs = append(pairs, []byte(key.Auth), []byte(key.Encrypt))
+	}
+
+	store := sessions.NewCookieStore(pairs...)
+	options := s.cookieOptions
+	store.Options = &options
+	store.MaxAge(options.MaxAge)
+
+	s.cookies = store
+	s.cookieGeneration = generation
+	s.cookieKeyId = keys[0].Id()
+	return store
+}
+
+// currentKeyId returns the id of the keys new cookies are saved with.
+func (s *webServer) currentKeyId() string {
+	s.cookieStore()
+
+	s.cookieLock.Lock()
+	defer s.cookieLock.Unlock()
+	return s.cookieKeyId
+}
+
+// getSession returns the session of r.  The cookie is marked secure if the
+// request came in over HTTPS, even if a proxy did the TLS.
+func (s *webServer) getSession(r *http.Request) (*sessions.Session, error) {
+	session, err := s.cookieStore().Get(r, SessionName)
+	if session != nil && isHTTPS(r) {
+		session.Options.Secure = true
+	}
+	return session, err
+}
+
+// sessionTokenKey is the cookie value holding the token of the server side
+// session.
+const sessionTokenKey = "SessionToken"
+
+// sessionKeyIdKey is the cookie value holding the id of the keys the cookie
+// was saved with.
+const sessionKeyIdKey = "KeyId"
+
 func (s *webServer) logout(w http.ResponseWriter, r *http.Request) error {
-	session, err := s.cookies.Get(r, SessionName)
+	session, err := s.getSession(r)
 	if err != nil {
 		return fmt.Errorf("Unable to get session from store: %v", err)
 	}
 
+	if token, _ := session.Values[sessionTokenKey].(string); token != "" {
+		if err := s.backend.EndSession(token); err != nil {
+			s.l.Error("Unable to end session: %v", err)
+		}
+	}
+
 	return delSession(session, w, r)
 }
 
 func (s *webServer) login(user *models.User, authType models.AuthType, w http.ResponseWriter, r *http.Request) error {
-	session, err := s.cookies.Get(r, SessionName)
+	session, err := s.getSession(r)
 	if err != nil {
 		return fmt.Errorf("Unable to get session from store: %v", err)
 	}
 
-	auth, err := user.GetAuthMethod(authType)
+	ban, err := s.backend.GetUserBan(user)
 	if err != nil {
 		return err
 	}
+	if ban != nil {
+		return fmt.Errorf("%s", ban.Message())
+	}
 
-	session.Values["UserId"] = user.Id
+	// A session from before, eg the one replaced after changing the
+	// password, is done.
+	if old, _ := session.Values[sessionTokenKey].(string); old != "" {
+		if err := s.backend.EndSession(old); err != nil {
+			s.l.Error("Unable to end the previous session: %v", err)
+		}
+	}
 
-	gobbed, err := auth.Date.GobEncode()
+	token, err := s.backend.NewSession(user, authType, r.UserAgent(), clientAddress(r))
 	if err != nil {
-		return fmt.Errorf("Unable to gob Date")
+		return err
 	}
+	session.Values[sessionTokenKey] = token
+	session.Values[sessionKeyIdKey] = s.currentKeyId()
 
-	session.Values["Date_"+string(auth.Type)] = fmt.Sprintf("%X", sha256.Sum256(gobbed))
+	// A new token for the new user, so one picked up before logging in is
+	// worthless.
+	csrf, err := newCsrfToken()
+	if err != nil {
+		return fmt.Errorf("Unable to generate CSRF token: %v", err)
+	}
+	session.Values[csrfSessionKey] = csrf
 
 	return session.Save(r, w)
 }
 
-func delSession(session *sessions.Session, w http.ResponseWriter, r *http.Request) error {
-	delete(session.Values, "UserId")
-	delete(session.Values, "Date_Local")
-	delete(session.Values, "Date_Discord")
-	delete(session.Values, "Date_Twitch")
-	delete(session.Values, "Date_Patreon")
+// Renders an error page and returns true if the user is banned.  Banned users
+// can browse, but not vote or add movies.
+func (s *webServer) rejectBannedUser(user *models.User, w http.ResponseWriter, r *http.Request) bool {
+	ban, err := s.backend.GetUserBan(user)
+	if err != nil {
+		s.l.Error("Unable to check bans for user %d: %v", user.Id, err)
+		s.doError(http.StatusInternalServerError, "Something went wrong :C", w, r)
+		return true
+	}
+
+	if ban == nil {
+		return false
+	}
 
+	s.doError(http.StatusForbidden, ban.Message(), w, r)
+	return true
+}
+
+func delSession(session *sessions.Session, w http.ResponseWriter, r *http.Request) error {
+	delete(session.Values, sessionTokenKey)
 	return session.Save(r, w)
 }
 
 func (s *webServer) getSessionUser(w http.ResponseWriter, r *http.Request) *models.User {
-	session, err := s.cookies.Get(r, SessionName)
+	_, user := s.getLoginSession(w, r)
+	return user
+}
+
+// getLoginSession returns the server side session of the logged in user
+// along with the user.  Both are nil if nobody is logged in.
+func (s *webServer) getLoginSession(w http.ResponseWriter, r *http.Request) (*models.Session, *models.User) {
+	session, err := s.getSession(r)
 	if err != nil {
 		s.l.Error("Unable to get session from store: %v", err)
 		err = delSession(session, w, r)
 		if err != nil {
 			s.l.Error("Unable to delete cookie: %v", err)
 		}
-		return nil
+		return nil, nil
 	}
 
-	val := session.Values["UserId"]
-	var userId int
-	var ok bool
-
-	if userId, ok = val.(int); !ok {
-		err = delSession(session, w, r)
-		if err != nil {
-			s.l.Error("Unable to delete cookie: %v", err)
-		}
-		return nil
+	token, _ := session.Values[sessionTokenKey].(string)
+	if token == "" {
+		return nil, nil
 	}
 
-	user, err := s.backend.GetUser(userId)
+	loginSession, user, err := s.backend.GetSession(token)
 	if err != nil {
-		s.l.Error("Unable to get user with ID %d: %v", userId, err)
+		if err != logic.ErrInvalidSession {
+			s.l.Error("Unable to get session: %v", err)
+			return nil, nil
+		}
+
+		// Revoked, expired or the password changed.
 		err = delSession(session, w, r)
 		if err != nil {
 			s.l.Error("Unable to delete cookie: %v", err)
 		}
-		return nil
+		return nil, nil
 	}
 
-	// I am sorry - CptPie
-	passDate, _ := session.Values["Date_Local"].(string)
-	refreshTwitch, _ := session.Values["Date_Twitch"].(string)
-	refreshDiscord, _ := session.Values["Date_Discord"].(string)
-	refreshPatreon, _ := session.Values["Date_Patreon"].(string)
-
-	if passDate != "" {
-		localAuth, err := user.GetAuthMethod(models.AUTH_LOCAL)
-
-		if err != nil {
-			s.l.Error(err.Error())
-			return nil
-		}
-
-		gobbed, err := localAuth.Date.GobEncode()
-
-		if err != nil || fmt.Sprintf("%X", sha256.Sum256(gobbed)) != passDate {
-			s.l.Info("User's Date_Local did not match stored value")
-			err = delSession(session, w, r)
-			if err != nil {
-				s.l.Error("Unable to delete cookie: %v", err)
-			}
-			return nil
-		}
-	} else if refreshTwitch != "" {
-		twitchAuth, err := user.GetAuthMethod(models.AUTH_TWITCH)
-
-		if err != nil {
-			s.l.Error(err.Error())
-			return nil
+	// Cookies saved before the keys were rotated are saved again with the
+	// new keys, long before the old ones expire.
+	if id := s.currentKeyId(); session.Values[sessionKeyIdKey] != id {
+		session.Values[sessionKeyIdKey] = id
+		if err := session.Save(r, w); err != nil {
+			s.l.Error("Unable to save cookie with the new keys: %v", err)
 		}
-
-		gobbed, err := twitchAuth.Date.GobEncode()
-
-		if err != nil || fmt.Sprintf("%X", sha256.Sum256(gobbed)) != refreshTwitch {
-			s.l.Info("User's Date_Twitch did not match stored value")
-			err = delSession(session, w, r)
-			if err != nil {
-				s.l.Error("Unable to delete cookie: %v", err)
-			}
-			return nil
-		}
-	} else if refreshDiscord != "" {
-		discordAuth, err := user.GetAuthMethod(models.AUTH_DISCORD)
-
-		if err != nil {
-			s.l.Error(err.Error())
-			return nil
-		}
-
-		gobbed, err := discordAuth.Date.GobEncode()
-
-		if err != nil || fmt.Sprintf("%X", sha256.Sum256(gobbed)) != refreshDiscord {
-			s.l.Info("User's Date_Discord did not match stored value")
-			err = delSession(session, w, r)
-			if err != nil {
-				s.l.Error("Unable to delete cookie: %v", err)
-			}
-			return nil
-		}
-	} else if refreshPatreon != "" {
-		patreonAuth, err := user.GetAuthMethod(models.AUTH_PATREON)
-
-		if err != nil {
-			s.l.Error(err.Error())
-			return nil
-		}
-
-		gobbed, err := patreonAuth.Date.GobEncode()
-
-		if err != nil || fmt.Sprintf("%X", sha256.Sum256(gobbed)) != refreshPatreon {
-			s.l.Info("User's Date_Patreon did not match stored value")
-			err = delSession(session, w, r)
-			if err != nil {
-				s.l.Error("Unable to delete cookie: %v", err)
-			}
-			return nil
-		}
-	} else {
-		//WTF MAN
-		s.l.Error("No valid login method detected")
-		return nil
 	}
 
-	return user
+	activeUsers.touch(user.Id, time.Now())
+	return loginSession, user
 }
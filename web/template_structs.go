@@ -8,8 +8,10 @@ type dataPageBase struct {
 	PageTitle string
 	Notice    string
 
-	User         *models.User
-	CurrentCycle *models.Cycle
+	User          *models.User
+	CurrentCycle  *models.Cycle
+	Notifications int    // Unread notifications for User
+	CsrfToken     string // has to be sent with every POST form
 }
 
 type dataMovieError struct {
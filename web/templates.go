@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"path/filepath"
 )
 
 const TEMPLATE_DIR = "web/templates/"
@@ -22,17 +23,28 @@ var templateDefs map[string][]string = map[string][]string{
 	"history":       []string{"history.html"},
 	"auth":          []string{"auth.html"},
 	"passwordReset": []string{"password.html"},
-
-	"adminHome":      []string{"admin/base.html", "admin/home.html"},
-	"adminConfig":    []string{"admin/base.html", "admin/config.html"},
-	"adminUsers":     []string{"admin/base.html", "admin/users.html"},
-	"adminUserEdit":  []string{"admin/base.html", "admin/user-edit.html"},
-	"adminCycles":    []string{"admin/base.html", "admin/cycles.html"},
-	"adminEndCycle":  []string{"admin/base.html", "admin/endcycle.html"},
-	"adminMovies":    []string{"admin/base.html", "admin/movies.html"},
-	"adminMovieEdit": []string{"admin/base.html", "admin/movie-edit.html"},
-	"adminNotice":    []string{"admin/base.html", "admin/notice.html"},
-	"adminConfirm":   []string{"admin/base.html", "admin/confirmation.html"},
+	"tag":           []string{"tag.html"},
+	"search":        []string{"search.html"},
+
+	"adminHome":         []string{"admin/base.html", "admin/home.html"},
+	"adminConfig":       []string{"admin/base.html", "admin/config.html"},
+	"adminUsers":        []string{"admin/base.html", "admin/users.html"},
+	"adminUserEdit":     []string{"admin/base.html", "admin/user-edit.html"},
+	"adminBan":          []string{"admin/base.html", "admin/ban.html"},
+	"adminBans":         []string{"admin/base.html", "admin/bans.html"},
+	"adminRoles":        []string{"admin/base.html", "admin/roles.html"},
+	"adminAudit":        []string{"admin/base.html", "admin/audit.html"},
+	"adminCleanup":      []string{"admin/base.html", "admin/cleanup.html"},
+	"adminLogging":      []string{"admin/base.html", "admin/logging.html"},
+	"adminTags":         []string{"admin/base.html", "admin/tags.html"},
+	"adminCycles":       []string{"admin/base.html", "admin/cycles.html"},
+	"adminEndCycle":     []string{"admin/base.html", "admin/endcycle.html"},
+	"adminMovies":       []string{"admin/base.html", "admin/movies.html"},
+	"adminMovieEdit":    []string{"admin/base.html", "admin/movie-edit.html"},
+	"adminMovieDeny":    []string{"admin/base.html", "admin/movie-deny.html"},
+	"adminMovieRefresh": []string{"admin/base.html", "admin/movie-refresh.html"},
+	"adminNotice":       []string{"admin/base.html", "admin/notice.html"},
+	"adminConfirm":      []string{"admin/base.html", "admin/confirmation.html"},
 }
 
 func (s *webServer) registerTemplates() error {
@@ -44,7 +56,8 @@ func (s *webServer) registerTemplates() error {
 			fpth = append(fpth, TEMPLATE_DIR+f)
 		}
 
-		t, err := template.ParseFiles(fpth...)
+		// The name has to match the first file, like template.ParseFiles.
+		t, err := template.New(filepath.Base(TEMPLATE_BASE)).Funcs(s.templateFuncs()).ParseFiles(fpth...)
 		if err != nil {
 			return fmt.Errorf("Error parsing template %s: %v", fpth, err)
 		}
@@ -54,6 +67,14 @@ func (s *webServer) registerTemplates() error {
 	return nil
 }
 
+// templateFuncs are the functions available in every template.  Local links
+// start with {{basePath}}, eg href="{{basePath}}/movie/{{.Id}}".
+func (s *webServer) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"basePath": func() string { return s.basePath },
+	}
+}
+
 func (s *webServer) executeTemplate(w http.ResponseWriter, key string, data interface{}) error {
 	// for deugging only
 	if s.debug {
@@ -86,11 +107,15 @@ func (s *webServer) newPageBase(title string, w http.ResponseWriter, r *http.Req
 		s.l.Error("Unable to get notice message from database: %v", err)
 	}
 
+	user := s.getSessionUser(w, r)
+
 	return dataPageBase{
 		PageTitle: title,
 		Notice:    notice,
 
-		User:         s.getSessionUser(w, r),
-		CurrentCycle: cycle,
+		User:          user,
+		CurrentCycle:  cycle,
+		Notifications: s.backend.GetUnreadNotificationCount(user),
+		CsrfToken:     s.csrfToken(w, r),
 	}
 }
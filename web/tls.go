@@ -0,0 +1,89 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsEnabled returns true if the server serves HTTPS itself, either with a
+// certificate from disk or with one from ACME.
+func (o Options) tlsEnabled() bool {
+	return o.TLSCert != "" || len(o.ACMEDomains) > 0
+}
+
+// setupTLS configures hs for HTTPS and creates the plain HTTP listener that
+// redirects to it.  Nothing is done if TLS is off.
+func (s *webServer) setupTLS(options Options, hs *http.Server) error {
+	if !options.tlsEnabled() {
+		return nil
+	}
+
+	if options.TLSCert != "" && len(options.ACMEDomains) > 0 {
+		return fmt.Errorf("Use either a certificate file or ACME, not both")
+	}
+	if options.TLSCert != "" && options.TLSKey == "" {
+		return fmt.Errorf("A TLS certificate needs a key")
+	}
+
+	// Session cookies must never be sent over plain HTTP.  SameSite is
+	// already set for every setup in New().
+	s.cookieOptions.Secure = true
+	s.tls = true
+	s.tlsCert = options.TLSCert
+	s.tlsKey = options.TLSKey
+
+	var redirect http.Handler = http.HandlerFunc(s.httpsRedirect(options.Listen))
+
+	if len(options.ACMEDomains) > 0 {
+		if options.ACMECache == "" {
+			options.ACMECache = "certs"
+		}
+
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(options.ACMEDomains...),
+			Cache:      autocert.DirCache(options.ACMECache),
+			Email:      options.ACMEEmail,
+		}
+		hs.TLSConfig = m.TLSConfig()
+
+		// The manager answers the http-01 challenges and passes
+		// everything else on to the redirect.
+		redirect = m.HTTPHandler(redirect)
+		s.l.Info("Using ACME certificates for %v, cached in %s", options.ACMEDomains, options.ACMECache)
+	}
+
+	if options.HTTPRedirect != "" {
+		s.redirect = &http.Server{
+			Addr:         options.HTTPRedirect,
+			Handler:      redirect,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		}
+	}
+
+	return nil
+}
+
+// httpsRedirect sends plain HTTP requests to the same URL on the HTTPS
+// listener.
+func (s *webServer) httpsRedirect(listen string) func(http.ResponseWriter, *http.Request) {
+	_, port, _ := net.SplitHostPort(listen)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	}
+}